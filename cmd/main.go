@@ -5,10 +5,19 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"e-commerce/config"
 	"e-commerce/controllers"
+	"e-commerce/jobs"
+	"e-commerce/mailer"
+	"e-commerce/money"
+	"e-commerce/openapi"
 	"e-commerce/routes"
+	"e-commerce/services"
+	"e-commerce/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,34 +25,38 @@ import (
 func main() {
 	// Connect to DB
 	config.ConnectDatabase()
+	if err := money.SetDefaultCurrency(config.StoreCurrency()); err != nil {
+		log.Fatal("Invalid STORE_CURRENCY:", err)
+	}
 	config.MigrateAll()
+	mailer.Init()
+
+	// JWT signing keys (reloaded every minute to pick up rotations)
+	if err := utils.InitSigningKeys(config.DB, time.Minute); err != nil {
+		log.Fatal("Failed to load JWT signing keys:", err)
+	}
 
-	// Create router
-	router := gin.Default()
-   
-	// Make DB accessible in handlers via context if desired
-	// router.Use(func(c *gin.Context) {
-	// 	c.Set("db", config.DB)
-	// 	c.Next()
-	// })
+	// Background jobs
+	workers, err := strconv.Atoi(os.Getenv("JOB_WORKERS"))
+	if err != nil {
+		workers = 2
+	}
+	services.RegisterJobs()
+	jobs.Start(config.DB, workers, 2*time.Second)
 
+	router := routes.Router()
 	// Load templates & static
 	//router.Static("/static", "./static")
 	router.LoadHTMLGlob("templates/*")
-	router.Use(controllers.MethodOverride())
-	router.GET("/", func(c *gin.Context) {
-		c.Redirect(http.StatusFound, "/login")
-	})
-   
-	routes.AuthRoutes(router)
-    routes.UserRoutes(router)
-	routes.AdminRoutes(router)
-    routes.AdminViewRoutes(router)
-    routes.ProductRoutes(router)
-    routes.WishlistRoutes(router)
-    routes.CartRoutes(router)
-	routes.OrdeRoutes(router)
-	routes.PaymentRoutes(router)
+
+	// Every route must be in openapi/openapi.json; refuse to start while
+	// developing so the document cannot drift
+	if missing := openapi.Undocumented(router.Routes()); len(missing) > 0 {
+		if gin.Mode() != gin.ReleaseMode {
+			log.Fatalf("❌ routes missing from openapi/openapi.json: %s", strings.Join(missing, ", "))
+		}
+		log.Printf("⚠️ routes missing from openapi/openapi.json: %s", strings.Join(missing, ", "))
+	}
 	
 	// Server port from .env
 	port := os.Getenv("PORT")
@@ -53,5 +66,6 @@ func main() {
 
 	addr := fmt.Sprintf(":%s", port)
 	log.Printf("🚀 Server running at http://localhost%s", addr)
-	router.Run(addr)
+	// forms may override POST with _method before routing
+	log.Fatal(http.ListenAndServe(addr, controllers.MethodOverride(router)))
 }
@@ -3,7 +3,10 @@ package config
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
@@ -41,3 +44,75 @@ func ConnectDatabase() {
 	DB = db
 	fmt.Println("✅ Connected to Database Successfully!")
 }
+
+// AppBaseURL is the public URL used to build links in emails
+func AppBaseURL() string {
+	if url := os.Getenv("APP_BASE_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// StoreCurrency is the ISO 4217 code prices and orders default to
+// (STORE_CURRENCY, INR when unset)
+func StoreCurrency() string {
+	if code := os.Getenv("STORE_CURRENCY"); code != "" {
+		return code
+	}
+	return "INR"
+}
+
+// APIPrefix is where the current version of the JSON API is mounted
+const APIPrefix = "/api/v1"
+
+// APIURL is the public URL of an API path, e.g. APIURL("/products/1")
+func APIURL(path string) string {
+	return AppBaseURL() + APIPrefix + path
+}
+
+// LegacyAPIDeprecated is when the unversioned API paths were deprecated
+var LegacyAPIDeprecated = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+// LegacyAPISunset is when the unversioned API paths stop working
+// (LEGACY_API_SUNSET as YYYY-MM-DD)
+func LegacyAPISunset() time.Time {
+	if t, err := time.Parse("2006-01-02", os.Getenv("LEGACY_API_SUNSET")); err == nil {
+		return t
+	}
+	return time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+}
+
+// CookieDomain is the Domain of the cookies the server sets
+// (COOKIE_DOMAIN, e.g. ".example.com"); unset means the request host only
+func CookieDomain() string {
+	return os.Getenv("COOKIE_DOMAIN")
+}
+
+// CookieSameSite is the SameSite mode of the cookies the server sets
+// (COOKIE_SAMESITE: lax, strict or none; lax when unset)
+func CookieSameSite() http.SameSite {
+	switch strings.ToLower(os.Getenv("COOKIE_SAMESITE")) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// CookieSecure reports whether cookies are sent over HTTPS only
+// (COOKIE_SECURE as true/false). It defaults to whether APP_BASE_URL is
+// https, and is always true with SameSite=None, which browsers require.
+func CookieSecure() bool {
+	if CookieSameSite() == http.SameSiteNoneMode {
+		return true
+	}
+	switch strings.ToLower(os.Getenv("COOKIE_SECURE")) {
+	case "true", "1":
+		return true
+	case "false", "0":
+		return false
+	}
+	return strings.HasPrefix(AppBaseURL(), "https://")
+}
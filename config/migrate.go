@@ -2,22 +2,52 @@ package config
 
 import (
 	"fmt"
+	"math"
+
 	"e-commerce/models"
+	"e-commerce/money"
+
+	"gorm.io/gorm"
 )
 
 // MigrateAll runs GORM auto migrations for all models
 func MigrateAll() {
+	if err := migrateWishlists(); err != nil {
+		fmt.Println("❌ Wishlist migration failed:", err)
+		return
+	}
+	if err := migrateMoney(); err != nil {
+		fmt.Println("❌ Money migration failed:", err)
+		return
+	}
+
 	err := DB.AutoMigrate(
 		&models.User{},
 		&models.OTP{},
 		&models.RefreshToken{},
+		&models.SigningKey{},
+		&models.ActionToken{},
+		&models.UserIdentity{},
 		&models.Product{},
+		&models.ProductPrice{},
 		&models.ProductProduction{},
+		&models.StockSubscription{},
 		&models.CartItem{},
+		&models.AbandonedCart{},
+		&models.GuestCart{},
+		&models.GuestCartItem{},
+		&models.Wishlist{},
 		&models.WishlistItem{},
 		&models.Order{},
 		&models.OrderItem{},
 		&models.Payment{},
+		&models.OrderStatusChange{},
+		&models.Shipment{},
+		&models.Refund{},
+		&models.OrderNote{},
+		&models.Job{},
+		&models.IdempotencyKey{},
+		&models.AuditLog{},
 	)
 
 	if err != nil {
@@ -25,4 +55,137 @@ func MigrateAll() {
 		return
 	}
 	fmt.Println("✅ All models migrated successfully!")
+
+	backfill()
+}
+
+// backfill fills columns added to existing tables; every statement is
+// idempotent so it is safe on each start
+func backfill() {
+	statements := []string{
+		// cart lines from before price tracking start at the current price
+		`UPDATE cart_items SET price_at_add_amount = p.price_amount, price_at_add_currency = p.price_currency
+		 FROM products p WHERE p.id = cart_items.product_id AND cart_items.price_at_add_amount = 0`,
+		`UPDATE wishlist_items SET price_at_add_amount = p.price_amount, price_at_add_currency = p.price_currency
+		 FROM products p WHERE p.id = wishlist_items.product_id AND wishlist_items.price_at_add_amount = 0`,
+		// one price per product and currency
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_product_price_currency ON product_prices (product_id, price_currency)`,
+		// emails are unique per account regardless of case; a guest checkout
+		// record may share its email with an account until the account
+		// verifies it and takes the guest's orders over
+		`DROP INDEX IF EXISTS idx_users_email`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_account_email ON users (LOWER(email)) WHERE role <> 'guest'`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_guest_email ON users (LOWER(email)) WHERE role = 'guest' AND deleted_at IS NULL`,
+		// one cart line per product and section: duplicates left by racing
+		// adds are folded into the oldest line before the index is built
+		`WITH dupes AS (
+			DELETE FROM cart_items WHERE id IN (
+				SELECT id FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id, product_id, saved_for_later ORDER BY id) AS n FROM cart_items) r
+				WHERE n > 1)
+			RETURNING user_id, product_id, saved_for_later, quantity)
+		 UPDATE cart_items c SET quantity = c.quantity + d.quantity
+		 FROM (SELECT user_id, product_id, saved_for_later, SUM(quantity) AS quantity FROM dupes GROUP BY user_id, product_id, saved_for_later) d
+		 WHERE c.user_id = d.user_id AND c.product_id = d.product_id AND c.saved_for_later = d.saved_for_later`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_items_line ON cart_items (user_id, product_id, saved_for_later)`,
+		// one default wishlist per user: extra defaults left by racing first
+		// visits become ordinary lists, keeping the oldest as the default
+		`UPDATE wishlists SET is_default = false WHERE id IN (
+			SELECT id FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY id) AS n FROM wishlists WHERE is_default) r
+			WHERE n > 1)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_wishlists_default ON wishlists (user_id) WHERE is_default`,
+	}
+	for _, stmt := range statements {
+		if err := DB.Exec(stmt).Error; err != nil {
+			fmt.Println("❌ Backfill failed:", err)
+		}
+	}
+}
+
+
+// migrateWishlists moves the old single per-user wishlist into a default
+// named list. It has to run before AutoMigrate adds the NOT NULL
+// wishlist_id column and the (wishlist, product) unique index.
+func migrateWishlists() error {
+	m := DB.Migrator()
+	if !m.HasTable(&models.WishlistItem{}) || m.HasColumn(&models.WishlistItem{}, "WishlistID") {
+		return nil
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&models.Wishlist{}); err != nil {
+			return err
+		}
+		statements := []string{
+			`ALTER TABLE wishlist_items ADD COLUMN wishlist_id bigint`,
+			`INSERT INTO wishlists (user_id, name, is_default, created_at, updated_at)
+			 SELECT DISTINCT user_id, 'My Wishlist', true, NOW(), NOW() FROM wishlist_items`,
+			`UPDATE wishlist_items SET wishlist_id = w.id FROM wishlists w
+			 WHERE w.user_id = wishlist_items.user_id AND w.is_default`,
+			`DROP INDEX IF EXISTS idx_user_product`,
+		}
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// moneyColumns are the float amounts that became money.Money: old is the
+// float column, prefix the embedded Money columns' prefix
+var moneyColumns = []struct{ table, old, prefix string }{
+	{"products", "price", "price_"},
+	{"cart_items", "price_at_add", "price_at_add_"},
+	{"guest_cart_items", "price_at_add", "price_at_add_"},
+	{"wishlist_items", "price_at_add", "price_at_add_"},
+	{"orders", "total_amount", "total_"},
+	{"order_items", "price", "price_"},
+	{"payments", "amount", ""},
+	{"abandoned_carts", "cart_value", "cart_value_"},
+	{"abandoned_carts", "order_total", "order_total_"},
+}
+
+// migrateMoney converts the float amount columns in place: each becomes
+// <prefix>amount in minor units of the store currency, with a
+// <prefix>currency column beside it. It has to run before AutoMigrate
+// adds the NOT NULL Money columns; converted tables are skipped.
+func migrateMoney() error {
+	m := DB.Migrator()
+	currency := money.DefaultCurrency()
+	factor := math.Pow10(money.Exponent(currency))
+
+	for _, col := range moneyColumns {
+		amountCol, currencyCol := col.prefix+"amount", col.prefix+"currency"
+		if !m.HasTable(col.table) || !m.HasColumn(col.table, col.old) || m.HasColumn(col.table, currencyCol) {
+			continue
+		}
+
+		statements := []string{}
+		if col.old != amountCol {
+			statements = append(statements, fmt.Sprintf(`ALTER TABLE %s RENAME COLUMN %s TO %s`, col.table, col.old, amountCol))
+		}
+		statements = append(statements,
+			fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT`, col.table, amountCol),
+			fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s TYPE bigint USING ROUND(%s * %v)`, col.table, amountCol, amountCol, factor),
+			fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN %s SET DEFAULT 0`, col.table, amountCol),
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s varchar(3) NOT NULL DEFAULT '%s'`, col.table, currencyCol, currency),
+		)
+		if col.table == "wishlist_items" && m.HasColumn(col.table, "alerted_price") {
+			statements = append(statements,
+				fmt.Sprintf(`ALTER TABLE wishlist_items ALTER COLUMN alerted_price TYPE bigint USING ROUND(alerted_price * %v)`, factor))
+		}
+
+		if err := DB.Transaction(func(tx *gorm.DB) error {
+			for _, stmt := range statements {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("%s.%s: %w", col.table, col.old, err)
+		}
+	}
+	return nil
 }
@@ -1,26 +1,37 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 
 	"e-commerce/config"
+	"e-commerce/middlewares"
 	"e-commerce/models"
+	"e-commerce/services"
 	"github.com/gin-gonic/gin"
 )
 
 // --------------------------- GET: All Users ---------------------------
 func GetAllUsersHandler(c *gin.Context) {
-	var users []models.User
-
-	if err := config.DB.Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+	q, err := services.ParseListQuery(c.Request.URL.Query())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	users, page, err := services.ListUsers(config.DB, q)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
+	setPaginationHeaders(c, page)
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Users fetched successfully",
-		"users":   users,
+		"message":    "Users fetched successfully",
+		"users":      users,
+		"pagination": page,
 	})
 }
 
@@ -29,13 +40,13 @@ func GetUserByIDHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		respondError(c, invalidID("user"))
 		return
 	}
 
 	var user models.User
 	if err := config.DB.First(&user, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, services.ErrUserNotFound)
 		return
 	}
 
@@ -49,12 +60,12 @@ func GetUserByIDHandler(c *gin.Context) {
 // func UpdateUserHandler(c *gin.Context) {
 // 	id, err := strconv.Atoi(c.Param("id"))
 // 	if err != nil {
-// 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+// 		respondError(c, invalidID("user"))
 // 		return
 // 	}
 // 	var user models.User
 // 	if err := config.DB.First(&user, id).Error; err != nil {
-// 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+// 		respondError(c, services.ErrUserNotFound)
 // 		return
 // 	}
 // 	// Only update fields if not empty
@@ -71,42 +82,53 @@ func GetUserByIDHandler(c *gin.Context) {
 // 		user.AvatarURL = &avatarURL
 // 	}
 // 	if err := config.DB.Save(&user).Error; err != nil {
-// 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+// 		respondError(c, services.Internal("Failed to update user", err))
 // 		return
 // 	}
 // 	// Redirect after success
 // 	c.Redirect(http.StatusSeeOther, "/view/users")
 // }
 
-//---------------------------------------PUT: Update User jSON-------------
+//---------------------------------------PATCH: Update User jSON-------------
+// Only the fields sent are changed; avatar_url null removes the avatar.
+// PUT is kept as an alias and behaves the same.
 func UpdateUserHandler(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := strconv.ParseUint(idParam, 10, 64)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
-	}
-	var user models.User
-	if err := config.DB.First(&user, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, invalidID("user"))
 		return
 	}
 	var input struct {
-		FullName  string  `json:"full_name"`
-		Role      string  `json:"role"`
-		Address   string  `json:"address"`
-		AvatarURL *string `json:"avatar_url"`
+		FullName  *string         `json:"full_name"`
+		Role      *string         `json:"role"`
+		Address   *string         `json:"address"`
+		AvatarURL json.RawMessage `json:"avatar_url"`
+		Reason    string          `json:"reason"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		respondError(c, err)
 		return
 	}
-	user.FullName = input.FullName
-	user.Role = input.Role
-	user.Address = input.Address
-	user.AvatarURL = input.AvatarURL
-	if err := config.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+	patch := services.UserPatch{
+		FullName: input.FullName,
+		Role:     input.Role,
+		Address:  input.Address,
+		Reason:   input.Reason,
+	}
+	// a sent avatar_url decodes to its raw JSON, null included
+	if input.AvatarURL != nil {
+		patch.AvatarSet = true
+		if err := json.Unmarshal(input.AvatarURL, &patch.AvatarURL); err != nil {
+			respondError(c, services.Invalid("invalid_user", "Invalid user update",
+				services.FieldError{Field: "avatar_url", Message: "must be a string or null"}))
+			return
+		}
+	}
+
+	middlewares.SetAuditReason(c, input.Reason)
+	user, err := services.PatchUser(config.DB, uint(id), patch, actorID(c))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
@@ -116,55 +138,39 @@ func UpdateUserHandler(c *gin.Context) {
 }
 
 // --------------------------- POST: Block User ---------------------------
+// The body must give a reason: {"reason": "..."}
 func BlockUserHandler(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := strconv.ParseUint(idParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
-	}
-
-	var user models.User
-	if err := config.DB.First(&user, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
-	user.IsBlocked = true
-	if err := config.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "User blocked successfully",
-		"user":    user,
-	})
+	setUserBlocked(c, true, "User blocked successfully")
 }
 
 // --------------------------- POST: Unblock User ---------------------------
 func UnblockUserHandler(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := strconv.ParseUint(idParam, 10, 64)
+	setUserBlocked(c, false, "User unblocked successfully")
+}
+
+func setUserBlocked(c *gin.Context, blocked bool, message string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		respondError(c, invalidID("user"))
 		return
 	}
-
-	var user models.User
-	if err := config.DB.First(&user, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	// the body is optional when unblocking
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, err)
 		return
 	}
 
-	user.IsBlocked = false
-	if err := config.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock user"})
+	middlewares.SetAuditReason(c, input.Reason)
+	user, err := services.SetUserBlocked(config.DB, uint(id), blocked, input.Reason, actorID(c))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
-
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User unblocked successfully",
+		"message": message,
 		"user":    user,
 	})
 }
@@ -174,17 +180,23 @@ func DeleteUserHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		respondError(c, invalidID("user"))
 		return
 	}
 
-	if err := config.DB.Delete(&models.User{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+	erased, err := services.DeleteUser(config.DB, uint(id), actorID(c))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
+	message := "User deleted successfully"
+	if !erased {
+		message = "User deleted; their data is erased once their open orders are done"
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User deleted successfully",
+		"message": message,
 		"user_id": id,
+		"erased":  erased,
 	})
 }
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"e-commerce/config"
+	"e-commerce/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------------------------- GET: Sales Analytics ---------------------------
+// GET /admin/analytics?from=2026-01-01&to=2026-01-31&interval=week
+func GetAnalyticsHandler(c *gin.Context) {
+	r, err := services.ParseAnalyticsRange(c.Query("from"), c.Query("to"), c.Query("interval"), time.Now())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	report, err := services.GetAnalytics(config.DB, r)
+	if err != nil {
+		respondError(c, services.Internal("Failed to build analytics", err))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"net/http"
+
+	"e-commerce/config"
+	"e-commerce/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------------------------- GET: Audit Log ---------------------------
+func GetAuditLogHandler(c *gin.Context) {
+	q, err := services.ParseListQuery(c.Request.URL.Query())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	logs, page, err := services.ListAuditLogs(config.DB, q)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	setPaginationHeaders(c, page)
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Audit log fetched successfully",
+		"audit_logs": logs,
+		"pagination": page,
+	})
+}
@@ -1,10 +1,16 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
 
 	"e-commerce/config"
-	"e-commerce/models"
+	"e-commerce/cookies"
+	"e-commerce/httperr"
+	"e-commerce/middlewares"
 	"e-commerce/services"
 	"e-commerce/utils"
 
@@ -20,14 +26,15 @@ func SignupHandler(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	if err := services.SignupService(config.DB, body.FullName, body.Email, body.Password); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := services.SignupService(config.DB, body.FullName, body.Email, body.Password, guestCartToken(c)); err != nil {
+		respondError(c, err)
 		return
 	}
+	clearGuestCartCookie(c)
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Signup successful. Please verify your email using OTP."})
 }
@@ -39,17 +46,18 @@ func LoginHandler(c *gin.Context) {
 		Password string `json:"password" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	accessToken, role, err := services.LoginService(config.DB, body.Email, body.Password)
+	accessToken, role, err := services.LoginService(config.DB, body.Email, body.Password, guestCartToken(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
+	clearGuestCartCookie(c)
 
-	c.SetCookie("access_token", accessToken, 30*120, "/", "localhost", false, true) // 30 minutes
+	cookies.Set(c, "access_token", accessToken, 30*120, "/") // 30 minutes
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":       "success",
@@ -59,49 +67,78 @@ func LoginHandler(c *gin.Context) {
 	})
 }
 
-//func LoginHandler(c *gin.Context) {
-// var body struct {
-// 	Email    string `json:"email" binding:"required,email"`
-// 	Password string `json:"password" binding:"required"`
-// }
-// if err := c.ShouldBindJSON(&body); err != nil {
-// 	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-// 	return
-// }
-// accessToken, refreshToken, role, err := services.LoginService(config.DB, body.Email, body.Password)
-// if err != nil {
-// 	c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-// 	return
-// }
-//----------------------------  postform---------------
-// 	email := c.PostForm("email")
-// 	password := c.PostForm("password")
-// 	if email == "" || password == "" {
-// 		c.HTML(http.StatusBadRequest, "login.html", gin.H{
-// 			"title": "Login Page",
-// 			"error": "⚠️ Email and password cannot be empty",
-// 		})
-// 		return
-// 	}
-// 	accessToken, role, err := services.LoginService(config.DB, email, password)
-// 	if err != nil {
-// 		c.HTML(http.StatusUnauthorized, "login.html", gin.H{
-// 			"title": "Login Page",
-// 			"error": "❌ Invalid email or password",
-// 		})
-// 		return
-// 	}
-// 	//Set accessToken in cookie
-// 	c.SetCookie("access_token", accessToken, 30*120, "/", "localhost", false, true) // 30 minutes
-// 	if role == "admin" {
-// 		c.Redirect(http.StatusSeeOther, "/view/dashboard")
-// 		return
-// 	}
-// 	c.HTML(http.StatusOK, "login.html", gin.H{
-// 		"title": "Login Page",
-// 		"error": "❌ Only admin users are allowed",
-// 	})
-// }
+// ------------------ ADMIN PANEL LOGIN ------------------
+// POST /login from login.html; errors are shown on the form
+func AdminLoginForm(c *gin.Context) {
+	email := strings.TrimSpace(c.PostForm("email"))
+	password := c.PostForm("password")
+	returnTo := panelReturnTo(c.PostForm("return_to"))
+
+	fail := func(code int, msg string) {
+		renderHTML(c, code, "login.html", gin.H{
+			"title":     "Login Page",
+			"email":     email,
+			"return_to": returnTo,
+			"error":     msg,
+		})
+	}
+
+	// the form may be posted before any session exists, so the global
+	// check does not cover it
+	if !middlewares.CSRFValid(c) {
+		fail(http.StatusForbidden, "⚠️ The form expired, please try again")
+		return
+	}
+	if email == "" || password == "" {
+		fail(http.StatusBadRequest, "⚠️ Email and password cannot be empty")
+		return
+	}
+
+	accessToken, err := services.AdminLoginService(config.DB, email, password)
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrInvalidCredentials):
+		fail(http.StatusUnauthorized, "❌ Invalid email or password")
+		return
+	case errors.Is(err, services.ErrAdminsOnly):
+		fail(http.StatusForbidden, "❌ Only admin users are allowed")
+		return
+	case err != nil:
+		e := services.AsError(err)
+		if e.Kind == services.KindInternal {
+			log.Printf("❌ admin login: %v", err)
+			fail(http.StatusInternalServerError, "⚠️ Server error. Try again later.")
+			return
+		}
+		fail(httperr.Status(e.Kind), "❌ "+e.Message)
+		return
+	}
+
+	cookies.Set(c, "access_token", accessToken, 30*120, "/")
+	if returnTo == "" {
+		returnTo = "/view/dashboard"
+	}
+	c.Redirect(http.StatusSeeOther, returnTo)
+}
+
+// POST /logout from the panel sidebar
+func AdminLogoutForm(c *gin.Context) {
+	// an expired token still names the user whose refresh token to revoke
+	if accessToken, err := c.Cookie("access_token"); err == nil && accessToken != "" {
+		if userID, _, _ := utils.ValidateJWT(accessToken); userID != 0 {
+			_ = services.LogoutService(config.DB, uint(userID))
+		}
+	}
+	cookies.Clear(c, "access_token", "/")
+	c.Redirect(http.StatusSeeOther, "/login?reason=logged_out")
+}
+
+// panelReturnTo keeps return_to only when it is a panel page
+func panelReturnTo(path string) string {
+	if path = safeReturnTo(path); !strings.HasPrefix(path, "/view/") {
+		return ""
+	}
+	return path
+}
 
 // ------------------ OTP ------------------
 func SendOTPHandler(c *gin.Context) {
@@ -109,17 +146,11 @@ func SendOTPHandler(c *gin.Context) {
 		Email string `json:"email" binding:"required,email"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	var user models.User
-	if err := config.DB.Where("email = ?", body.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		respondError(c, err)
 		return
 	}
-
-	if err := services.SendOTPService(config.DB, user.ID, user.Email, "generic"); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := services.ResendOTPService(config.DB, body.Email); err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -132,12 +163,12 @@ func VerifyOTPHandler(c *gin.Context) {
 		OTP   string `json:"otp" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	if err := services.VerifyOTPService(config.DB, body.Email, body.OTP); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
@@ -148,12 +179,12 @@ func ResendOTPHandler(c *gin.Context) {
 		Email string `json:"email" binding:"required,email"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	if err := services.ResendOTPService(config.DB, body.Email); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "OTP resent successfully"})
@@ -165,41 +196,97 @@ func ForgotPasswordHandler(c *gin.Context) {
 		Email string `json:"email" binding:"required,email"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	if err := services.ForgotPasswordService(config.DB, body.Email); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "OTP sent to your email"})
 }
 
 // -------------------- Reset Password --------------------
+// Accepts either {email, otp} from the mobile flow or {token} from a reset link
 func ResetPasswordHandler(c *gin.Context) {
 	var body struct {
-		Email       string `json:"email" binding:"required,email"`
-		OTP         string `json:"otp" binding:"required"`
+		Email       string `json:"email" binding:"omitempty,email"`
+		OTP         string `json:"otp"`
+		Token       string `json:"token"`
 		NewPassword string `json:"new_password" binding:"required,min=6"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	if err := services.ResetPasswordService(config.DB, body.Email, body.OTP, body.NewPassword); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var err error
+	switch {
+	case body.Token != "":
+		err = services.ResetPasswordWithLinkService(config.DB, body.Token, body.NewPassword)
+	case body.Email != "" && body.OTP != "":
+		err = services.ResetPasswordService(config.DB, body.Email, body.OTP, body.NewPassword)
+	default:
+		respondError(c, services.Invalid("validation_failed", "Either token or email and otp are required",
+			services.FieldError{Field: "token", Message: "is required unless email and otp are given"}))
+		return
+	}
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Password reset successful"})
 }
 
+// -------------------- Verify Link --------------------
+// GET /auth/verify?token=... is the target of emailed one-time links
+func VerifyLinkHandler(c *gin.Context) {
+	token := c.Query("token")
+	wantsJSON := c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON
+
+	fail := func(err error) {
+		if wantsJSON {
+			respondError(c, err)
+			return
+		}
+		renderHTML(c, http.StatusBadRequest, "verify_link.html", gin.H{"title": "Link not valid", "error": services.AsError(err).Message})
+	}
+
+	claims, err := services.PeekActionLink(config.DB, token)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	switch claims.Purpose {
+	case services.PurposeSignup:
+		if err := services.VerifyEmailLinkService(config.DB, token); err != nil {
+			fail(err)
+			return
+		}
+		if wantsJSON {
+			c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+			return
+		}
+		renderHTML(c, http.StatusOK, "verify_link.html", gin.H{"title": "Email verified", "message": "Your email has been verified. You can now log in."})
+	case services.PurposeResetPassword:
+		// consumed only when the new password is submitted
+		if wantsJSON {
+			c.JSON(http.StatusOK, gin.H{"purpose": claims.Purpose, "token": token})
+			return
+		}
+		renderHTML(c, http.StatusOK, "reset_password.html", gin.H{"title": "Reset Password", "token": token})
+	default:
+		fail(services.ErrLinkInvalid)
+	}
+}
+
 // ------------------ REFRESH TOKEN ------------------
 func RefreshTokenHandler(c *gin.Context) {
 	accessToken, err := c.Cookie("access_token")
 	if err != nil || accessToken == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing access token"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 
@@ -210,17 +297,17 @@ func RefreshTokenHandler(c *gin.Context) {
 	}
 
 	if userID == 0 {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
+		respondError(c, services.ErrSessionExpired)
 		return
 	}
 
 	newToken, err := services.RefreshService(config.DB, uint(userID))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
+		respondError(c, services.ErrSessionExpired.Wrap(err))
 		return
 	}
 
-	c.SetCookie("access_token", newToken, 30*60, "/", "localhost", false, true)
+	cookies.Set(c, "access_token", newToken, 30*60, "/")
 	c.JSON(http.StatusOK, gin.H{"access_token": newToken})
 }
 
@@ -228,21 +315,28 @@ func RefreshTokenHandler(c *gin.Context) {
 func LogoutHandler(c *gin.Context) {
 	accessToken, err := c.Cookie("access_token")
 	if err != nil || accessToken == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing access token"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 
 	userID, _, err := utils.ValidateJWT(accessToken)
 	if err != nil || userID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access token"})
+		respondError(c, services.Unauthorized("invalid_token", "Invalid access token"))
 		return
 	}
 
 	if err := services.LogoutService(config.DB, uint(userID)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.SetCookie("access_token", "", -1, "/", "localhost", false, true)
+	cookies.Clear(c, "access_token", "/")
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
+
+// ------------------ JWKS ------------------
+// GET /.well-known/jwks.json - public keys for verifying access tokens
+func JWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(utils.JWKSMaxAge.Seconds())))
+	c.JSON(http.StatusOK, gin.H{"keys": utils.PublicJWKS()})
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -8,24 +9,29 @@ import (
 	"github.com/gin-gonic/gin"
 	"e-commerce/config"
 	"e-commerce/models"
+	"e-commerce/money"
+	"e-commerce/services"
 )
 
 // ---------------- RESPONSE STRUCTS ----------------
 type CartItemResponse struct {
-	ID        uint           `json:"id"`
-	Product   ProductSummary `json:"product"`
-	Quantity  int            `json:"quantity"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
+	ID         uint                    `json:"id"`
+	Product    ProductSummary          `json:"product"`
+	Quantity   int                     `json:"quantity"`
+	PriceAtAdd money.Money             `json:"price_at_add"`
+	LineTotal  money.Money             `json:"line_total"`
+	Flags      *services.CartLineFlags `json:"flags,omitempty"`
+	CreatedAt  time.Time               `json:"created_at"`
+	UpdatedAt  time.Time               `json:"updated_at"`
 }
 
 type ProductSummary struct {
-	ID            uint    `json:"id"`
-	Name          string  `json:"name"`
-	Description   string  `json:"description"`
-	Price         float64 `json:"price"`
-	StockQuantity int     `json:"stock_quantity"`
-	ImageURL      string  `json:"image_url"`
+	ID            uint        `json:"id"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	Price         money.Money `json:"price"`
+	StockQuantity int         `json:"stock_quantity"`
+	ImageURL      string      `json:"image_url"`
 }
 
 // ---------------- ADD TO CART ----------------
@@ -33,7 +39,7 @@ func AddToCart(c *gin.Context) {
 	userIDInt, exists := c.Get("userID")
 	id, ok := userIDInt.(int)
 	if !exists || !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 	userID := uint(id)
@@ -43,69 +49,73 @@ func AddToCart(c *gin.Context) {
 		Quantity  int  `json:"quantity" binding:"required,min=1"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	// Check if product exists
-	var product models.Product
-	if err := config.DB.First(&product, input.ProductID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	cartItem, created, err := services.AddToCart(config.DB, userID, input.ProductID, input.Quantity)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	// Check stock
-	if input.Quantity > product.StockQuantity {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Not enough stock available"})
+	if !created {
+		c.JSON(http.StatusOK, gin.H{"message": "Cart quantity updated", "cart_item": mapCartItem(*cartItem)})
 		return
 	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Product added to cart", "cart_item": mapCartItem(*cartItem)})
+}
 
-	// Check if already in cart
-	var existing models.CartItem
-	if err := config.DB.Where("user_id = ? AND product_id = ?", userID, input.ProductID).First(&existing).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Product already in cart. Use PUT to update quantity."})
+// ---------------- BULK ADD TO CART ----------------
+// POST /cart/bulk - add several products; all or nothing
+func BulkAddToCart(c *gin.Context) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
+	userID := uint(id)
 
-	// Create cart item
-	cartItem := models.CartItem{
-		UserID:    userID,
-		ProductID: input.ProductID,
-		Quantity:  input.Quantity,
+	var input struct {
+		Items []services.CartAddLine `json:"items" binding:"required,min=1,dive"`
 	}
-	if err := config.DB.Create(&cartItem).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to cart"})
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	// Preload product
-	if err := config.DB.Preload("Product").First(&cartItem, cartItem.ID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart item"})
+	items, err := services.BulkAddToCart(config.DB, userID, input.Items)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Product added to cart", "cart_item": mapCartItem(cartItem)})
+	resp := []CartItemResponse{}
+	for _, item := range items {
+		resp = append(resp, mapCartItem(item))
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Products added to cart", "cart_items": resp})
 }
 
 // ---------------- GET CART ITEMS ----------------
+// GET /cart - the cart validated against live prices and stock
 func GetCartItems(c *gin.Context) {
 	userIDInt, exists := c.Get("userID")
 	id, ok := userIDInt.(int)
 	if !exists || !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 	userID := uint(id)
 
-	var cartItems []models.CartItem
-	config.DB.Preload("Product").Where("user_id = ?", userID).Find(&cartItems)
-
-	var resp []CartItemResponse
-	for _, item := range cartItems {
-		resp = append(resp, mapCartItem(item))
+	cart, err := services.ValidateCart(config.DB, userID)
+	if err != nil {
+		respondError(c, services.Internal("Failed to load cart", err))
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"cart_items": resp})
+	c.JSON(http.StatusOK, mapValidatedCart(cart))
 }
 
 // ---------------- UPDATE CART ITEM ----------------
@@ -113,7 +123,7 @@ func UpdateCartItem(c *gin.Context) {
 	userIDInt, exists := c.Get("userID")
 	id, ok := userIDInt.(int)
 	if !exists || !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 	userID := uint(id)
@@ -121,7 +131,7 @@ func UpdateCartItem(c *gin.Context) {
 	cartID := c.Param("id")
 	cartIDUint, err := strconv.ParseUint(cartID, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cart item ID"})
+		respondError(c, invalidID("cart item"))
 		return
 	}
 
@@ -129,18 +139,18 @@ func UpdateCartItem(c *gin.Context) {
 		Quantity int `json:"quantity" binding:"required,min=1"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	var cartItem models.CartItem
 	if err := config.DB.Preload("Product").Where("id = ? AND user_id = ?", cartIDUint, userID).First(&cartItem).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+		respondError(c, services.ErrCartItemNotFound)
 		return
 	}
 
 	if input.Quantity > cartItem.Product.StockQuantity {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Not enough stock available"})
+		respondError(c, services.ErrNotEnoughStock)
 		return
 	}
 
@@ -155,7 +165,7 @@ func DeleteCartItem(c *gin.Context) {
 	userIDInt, exists := c.Get("userID")
 	id, ok := userIDInt.(int)
 	if !exists || !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 	userID := uint(id)
@@ -163,23 +173,108 @@ func DeleteCartItem(c *gin.Context) {
 	cartID := c.Param("id")
 	cartIDUint, err := strconv.ParseUint(cartID, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cart item ID"})
+		respondError(c, invalidID("cart item"))
 		return
 	}
 
 	result := config.DB.Where("id = ? AND user_id = ?", cartIDUint, userID).Delete(&models.CartItem{})
 	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove cart item"})
+		respondError(c, services.Internal("Failed to remove cart item", result.Error))
 		return
 	}
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+		respondError(c, services.ErrCartItemNotFound)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Cart item removed"})
 }
 
+// ---------------- BULK DELETE CART ITEMS ----------------
+// POST /cart/bulk-delete
+func BulkDeleteCartItems(c *gin.Context) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return
+	}
+	userID := uint(id)
+
+	var input struct {
+		IDs []uint `json:"ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	removed, err := services.RemoveCartItems(config.DB, userID, input.IDs)
+	if err != nil {
+		respondError(c, services.Internal("Failed to remove cart items", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Cart items removed", "removed": removed})
+}
+
+// ---------------- SAVE FOR LATER ----------------
+// POST /cart/:id/save-for-later
+func SaveCartItemForLater(c *gin.Context) {
+	setSavedForLater(c, true, "Saved for later")
+}
+
+// POST /cart/:id/restore - back from saved-for-later into the cart
+func RestoreCartItem(c *gin.Context) {
+	setSavedForLater(c, false, "Moved back to cart")
+}
+
+func setSavedForLater(c *gin.Context, saved bool, message string) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return
+	}
+	userID := uint(id)
+
+	cartIDUint, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, invalidID("cart item"))
+		return
+	}
+
+	cartItem, err := services.SetSavedForLater(config.DB, userID, uint(cartIDUint), saved)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "cart_item": mapCartItem(*cartItem)})
+}
+
+// ---------------- MOVE TO WISHLIST ----------------
+// POST /cart/:id/move-to-wishlist
+func MoveCartItemToWishlist(c *gin.Context) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return
+	}
+	userID := uint(id)
+
+	cartIDUint, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, invalidID("cart item"))
+		return
+	}
+
+	if err := services.MoveCartItemToWishlist(config.DB, userID, uint(cartIDUint)); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Moved to wishlist"})
+}
+
 // ---------------- HELPER ----------------
 func mapCartItem(item models.CartItem) CartItemResponse {
 	return CartItemResponse{
@@ -192,8 +287,47 @@ func mapCartItem(item models.CartItem) CartItemResponse {
 			StockQuantity: item.Product.StockQuantity,
 			ImageURL:      item.Product.ImageURL,
 		},
-		Quantity:  item.Quantity,
-		CreatedAt: item.CreatedAt,
-		UpdatedAt: item.UpdatedAt,
+		Quantity:   item.Quantity,
+		PriceAtAdd: item.PriceAtAdd,
+		LineTotal:  item.Product.Price.Times(item.Quantity),
+		CreatedAt:  item.CreatedAt,
+		UpdatedAt:  item.UpdatedAt,
+	}
+}
+
+func mapValidatedCart(cart *services.ValidatedCart) gin.H {
+	items := []CartItemResponse{}
+	for _, line := range cart.Lines {
+		item := mapCartItem(line.Item)
+		item.Product.StockQuantity = line.Available
+		item.LineTotal = line.LineTotal
+		flags := line.Flags
+		item.Flags = &flags
+		items = append(items, item)
+	}
+	saved := []CartItemResponse{}
+	for _, line := range cart.SavedForLater {
+		item := mapCartItem(line.Item)
+		item.Product.StockQuantity = line.Available
+		flags := line.Flags
+		item.Flags = &flags
+		saved = append(saved, item)
 	}
-}
\ No newline at end of file
+	return gin.H{
+		"cart_items":      items,
+		"saved_for_later": saved,
+		"totals":          cart.Totals,
+		"price_changed":   cart.PriceChanged,
+		"unavailable":     cart.Unavailable,
+		"version":         cart.Version,
+	}
+}
+// ---------------- RESUME ABANDONED CART ----------------
+// GET /cart/resume/:token - tracked link from reminder emails; records the
+// click and sends the user to their cart
+func ResumeCart(c *gin.Context) {
+	if err := services.TrackCartResume(config.DB, c.Param("token")); err != nil {
+		log.Println("❌ cart resume tracking failed:", err)
+	}
+	c.Redirect(http.StatusFound, config.APIURL("/cart"))
+}
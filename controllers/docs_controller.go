@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"net/http"
+
+	"e-commerce/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ---------------- OPENAPI ----------------
+// GET /openapi.json
+func OpenAPISpecHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openapi.Spec())
+}
+
+// GET /docs - Swagger UI over /openapi.json
+func ShowAPIDocs(c *gin.Context) {
+	c.HTML(http.StatusOK, "swagger.html", gin.H{
+		"title":   "API Documentation",
+		"specURL": "/openapi.json",
+	})
+}
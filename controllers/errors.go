@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+
+	"e-commerce/httperr"
+	"e-commerce/money"
+	"e-commerce/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError writes err as the API error envelope. A refused checkout
+// also returns the reviewed cart so the client can show what changed.
+func respondError(c *gin.Context, err error) {
+	var stale *services.CartStaleError
+	if errors.As(err, &stale) {
+		err = stale.Err.WithDetails(gin.H{"cart": mapValidatedCart(stale.Cart)})
+	}
+	httperr.Respond(c, err)
+}
+
+// invalidID rejects a malformed path parameter, e.g. invalidID("product")
+func invalidID(what string) error {
+	return services.BadRequest("invalid_id", "Invalid "+what+" ID")
+}
+
+// errorText is err as one line for an HTML page, e.g.
+// "Invalid shipment; carrier is required"
+func errorText(err error) string {
+	e := services.AsError(err)
+	text := e.Message
+	for _, f := range e.Fields {
+		text += "; " + f.Field + " " + f.Message
+	}
+	return text
+}
+
+// orderCurrency checks the optional currency of a checkout request;
+// "" keeps the store currency
+func orderCurrency(code string) (string, error) {
+	if code == "" {
+		return "", nil
+	}
+	cur, err := money.ParseCurrency(code)
+	if err != nil {
+		return "", services.Invalid("invalid_currency", "Unsupported currency",
+			services.FieldError{Field: "currency", Message: "is not a supported currency"})
+	}
+	return cur, nil
+}
+
+// errUserIDType means an auth middleware stored an unexpected userID
+var errUserIDType = services.Internal("Invalid user ID type", nil)
+
+// NoRouteHandler answers unknown paths with the error envelope
+func NoRouteHandler(c *gin.Context) {
+	respondError(c, services.NotFound("route_not_found", "No route for "+c.Request.Method+" "+c.Request.URL.Path))
+}
+
+// RecoveryHandler turns a panic into a logged 500 envelope
+func RecoveryHandler(c *gin.Context, recovered interface{}) {
+	respondError(c, services.Internal("Something went wrong", fmt.Errorf("panic: %v", recovered)))
+}
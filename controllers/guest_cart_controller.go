@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"e-commerce/config"
+	"e-commerce/cookies"
+	"e-commerce/models"
+	"e-commerce/services"
+	"e-commerce/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const guestCartCookie = "guest_cart"
+
+// guestCartClaims is the signed cookie payload; the cart lives server-side
+type guestCartClaims struct {
+	Token string `json:"cart"`
+}
+
+// ---------------- COOKIE HELPERS ----------------
+
+// guestCartToken returns the cart token from a valid signed cookie, or ""
+func guestCartToken(c *gin.Context) string {
+	raw, err := c.Cookie(guestCartCookie)
+	if err != nil || raw == "" {
+		return ""
+	}
+	var claims guestCartClaims
+	if err := utils.ParseSignedJSON(raw, &claims); err != nil {
+		return ""
+	}
+	return claims.Token
+}
+
+func setGuestCartCookie(c *gin.Context, token string) error {
+	value, err := utils.SignJSON(guestCartClaims{Token: token})
+	if err != nil {
+		return err
+	}
+	cookies.Set(c, guestCartCookie, value, int(services.GuestCartTTL.Seconds()), "/")
+	return nil
+}
+
+func clearGuestCartCookie(c *gin.Context) {
+	cookies.Clear(c, guestCartCookie, "/")
+}
+
+// currentGuestCart loads the visitor's cart; with create it starts one when
+// there is none yet
+func currentGuestCart(c *gin.Context, create bool) (*models.GuestCart, error) {
+	cart, err := services.GetGuestCart(config.DB, guestCartToken(c))
+	if err == nil || !create || !errors.Is(err, services.ErrGuestCartNotFound) {
+		return cart, err
+	}
+
+	cart, err = services.CreateGuestCart(config.DB)
+	if err != nil {
+		return nil, err
+	}
+	if err := setGuestCartCookie(c, cart.Token); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+// ---------------- GET GUEST CART ----------------
+// GET /guest/cart
+func GetGuestCart(c *gin.Context) {
+	cart, err := currentGuestCart(c, false)
+	if errors.Is(err, services.ErrGuestCartNotFound) {
+		c.JSON(http.StatusOK, gin.H{"cart_items": []CartItemResponse{}})
+		return
+	}
+	if err != nil {
+		respondError(c, services.Internal("Failed to load cart", err))
+		return
+	}
+
+	resp := []CartItemResponse{}
+	for _, item := range cart.Items {
+		resp = append(resp, mapGuestCartItem(item))
+	}
+	c.JSON(http.StatusOK, gin.H{"cart_items": resp, "expires_at": cart.ExpiresAt, "version": services.GuestCartVersion(cart)})
+}
+
+// ---------------- ADD TO GUEST CART ----------------
+// POST /guest/cart
+func AddToGuestCart(c *gin.Context) {
+	var input struct {
+		ProductID uint `json:"product_id" binding:"required"`
+		Quantity  int  `json:"quantity" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	cart, err := currentGuestCart(c, true)
+	if err != nil {
+		respondError(c, services.Internal("Failed to create cart", err))
+		return
+	}
+
+	item, err := services.AddGuestCartItem(config.DB, cart, input.ProductID, input.Quantity)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Product added to cart", "cart_item": mapGuestCartItem(*item)})
+}
+
+// ---------------- UPDATE GUEST CART ITEM ----------------
+// PUT /guest/cart/:id
+func UpdateGuestCartItem(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, invalidID("cart item"))
+		return
+	}
+	var input struct {
+		Quantity int `json:"quantity" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	cart, err := currentGuestCart(c, false)
+	if err != nil {
+		respondError(c, services.ErrGuestCartNotFound)
+		return
+	}
+	item, err := services.UpdateGuestCartItem(config.DB, cart, uint(itemID), input.Quantity)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Cart item updated", "cart_item": mapGuestCartItem(*item)})
+}
+
+// ---------------- DELETE GUEST CART ITEM ----------------
+// DELETE /guest/cart/:id
+func DeleteGuestCartItem(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, invalidID("cart item"))
+		return
+	}
+
+	cart, err := currentGuestCart(c, false)
+	if err != nil {
+		respondError(c, services.ErrGuestCartNotFound)
+		return
+	}
+	if err := services.RemoveGuestCartItem(config.DB, cart, uint(itemID)); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Cart item removed"})
+}
+
+// ---------------- GUEST CHECKOUT ----------------
+// POST /guest/checkout - place the order and start payment without an account
+func GuestCheckout(c *gin.Context) {
+	var input struct {
+		Email       string `json:"email" binding:"required,email"`
+		FullName    string `json:"full_name"`
+		Address     string `json:"address" binding:"required"`
+		CartVersion string `json:"cart_version"`
+		Currency    string `json:"currency"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		respondError(c, err)
+		return
+	}
+	currency, err := orderCurrency(input.Currency)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	order, err := services.GuestCheckout(config.DB, guestCartToken(c), input.Email, input.FullName, input.Address, currency, input.CartVersion)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	clearGuestCartCookie(c)
+
+	payment, clientSecret, err := startStripePayment(models.Order{ID: order.ID, TotalAmount: order.TotalAmount})
+	if err != nil {
+		// the pending order is kept so it can be followed up
+		respondError(c, services.AsError(err).WithDetails(gin.H{"order": order}))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"order":         order,
+		"payment":       payment,
+		"client_secret": clientSecret,
+	})
+}
+
+// ---------------- HELPER ----------------
+func mapGuestCartItem(item models.GuestCartItem) CartItemResponse {
+	return CartItemResponse{
+		ID: item.ID,
+		Product: ProductSummary{
+			ID:            item.Product.ID,
+			Name:          item.Product.Name,
+			Description:   item.Product.Description,
+			Price:         item.Product.Price,
+			StockQuantity: item.Product.StockQuantity,
+			ImageURL:      item.Product.ImageURL,
+		},
+		Quantity:   item.Quantity,
+		PriceAtAdd: item.PriceAtAdd,
+		LineTotal:  item.Product.Price.Times(item.Quantity),
+		CreatedAt:  item.CreatedAt,
+		UpdatedAt:  item.UpdatedAt,
+	}
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"e-commerce/config"
+	"e-commerce/jobs"
+	"e-commerce/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --------------------------- GET: Jobs (dead-letter by default) ---------------------------
+func GetJobsHandler(c *gin.Context) {
+	status := c.DefaultQuery("status", jobs.StatusDead)
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	list, err := jobs.List(config.DB, status, limit)
+	if err != nil {
+		respondError(c, services.Internal("Failed to fetch jobs", err))
+		return
+	}
+	counts, err := jobs.Counts(config.DB)
+	if err != nil {
+		respondError(c, services.Internal("Failed to count jobs", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": list, "counts": counts})
+}
+
+// --------------------------- POST: Retry Job ---------------------------
+func RetryJobHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, invalidID("job"))
+		return
+	}
+
+	if err := jobs.Retry(config.DB, uint(id)); err != nil {
+		if errors.Is(err, jobs.ErrNotRetryable) {
+			err = services.NotFound("job_not_found", "Job not found or not retryable")
+		}
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Job re-queued"})
+}
+
+// --------------------------- DELETE: Discard Dead Job ---------------------------
+func DiscardJobHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, invalidID("job"))
+		return
+	}
+
+	if err := jobs.Discard(config.DB, uint(id)); err != nil {
+		if errors.Is(err, jobs.ErrNotDead) {
+			err = services.NotFound("job_not_found", "Dead job not found")
+		}
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Job discarded"})
+}
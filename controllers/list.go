@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"e-commerce/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setPaginationHeaders adds X-Total-Count and a Link header with the
+// first, prev, next and last pages of an admin list
+func setPaginationHeaders(c *gin.Context, page *services.Pagination) {
+	c.Header("X-Total-Count", strconv.FormatInt(page.Total, 10))
+
+	view := listView{query: c.Request.URL.Query(), path: c.Request.URL.Path, Pagination: page}
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, view.PageURL(1))}
+	if view.HasPrev() {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, view.PrevURL()))
+	}
+	if view.HasNext() {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, view.NextURL()))
+	}
+	if page.TotalPages > 0 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="last"`, view.LastURL()))
+	}
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+// listView gives list templates their current filters, sort links and pager
+type listView struct {
+	*services.Pagination
+	query url.Values
+	path  string
+	Error string
+}
+
+func newListView(c *gin.Context, page *services.Pagination, err error) listView {
+	view := listView{query: c.Request.URL.Query(), path: c.Request.URL.Path, Pagination: page}
+	if view.Pagination == nil {
+		view.Pagination = &services.Pagination{Page: 1, PerPage: services.DefaultPerPage}
+	}
+	if err != nil {
+		view.Error = errorText(err)
+	}
+	return view
+}
+
+// Get is a current query value, to refill the filter form
+func (v listView) Get(name string) string {
+	return v.query.Get(name)
+}
+
+func (v listView) url(set map[string]string) string {
+	q := url.Values{}
+	for k, vals := range v.query {
+		q[k] = vals
+	}
+	for k, val := range set {
+		q.Set(k, val)
+	}
+	return v.path + "?" + q.Encode()
+}
+
+// PageURL keeps the filters and sort and moves to page n
+func (v listView) PageURL(n int) string {
+	return v.url(map[string]string{"page": strconv.Itoa(n)})
+}
+
+func (v listView) HasPrev() bool { return v.Page > 1 }
+func (v listView) HasNext() bool { return v.Page < v.TotalPages }
+
+func (v listView) PrevURL() string { return v.PageURL(v.Page - 1) }
+func (v listView) NextURL() string { return v.PageURL(v.Page + 1) }
+func (v listView) LastURL() string { return v.PageURL(v.TotalPages) }
+
+// SortURL sorts by key, flipping the direction when already sorted by it,
+// and goes back to the first page
+func (v listView) SortURL(key string) string {
+	sort := key
+	if v.query.Get("sort") == key {
+		sort = "-" + key
+	}
+	return v.url(map[string]string{"sort": sort, "page": "1"})
+}
+
+// SortMark is the arrow shown next to the sorted column
+func (v listView) SortMark(key string) string {
+	switch v.query.Get("sort") {
+	case key:
+		return " ▲"
+	case "-" + key:
+		return " ▼"
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"e-commerce/config"
+	"e-commerce/cookies"
+	"e-commerce/oauth"
+	"e-commerce/services"
+	"e-commerce/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const oauthStateCookie = "oauth_state"
+
+var (
+	errUnknownProvider     = services.NotFound("unknown_provider", "Unknown login provider")
+	errProviderUnavailable = services.Upstream("provider_unavailable", "Login provider unavailable")
+	errLoginNotVerified    = services.Unauthorized("login_not_verified", "Login could not be verified")
+	errLoginStateExpired   = services.BadRequest("login_state_expired", "Login session expired, please try again")
+	errLoginStateInvalid   = services.BadRequest("login_state_invalid", "Invalid login state")
+)
+
+// oauthState survives the round trip to the provider in a signed cookie
+type oauthState struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	ReturnTo string `json:"return_to"`
+	Expiry   int64  `json:"exp"`
+}
+
+// ------------------ OAUTH START ------------------
+// GET /auth/oauth/:provider/start?return_to=/path
+func OAuthStartHandler(c *gin.Context) {
+	client, ok := oauth.Provider(c.Param("provider"))
+	if !ok {
+		respondError(c, errUnknownProvider)
+		return
+	}
+
+	state, err1 := utils.RandomToken(16)
+	nonce, err2 := utils.RandomToken(16)
+	verifier, err3 := utils.RandomToken(32)
+	if err1 != nil || err2 != nil || err3 != nil {
+		respondError(c, services.Internal("Failed to start login", errors.Join(err1, err2, err3)))
+		return
+	}
+
+	cookie, err := utils.SignJSON(oauthState{
+		Provider: client.Config.Name,
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		ReturnTo: safeReturnTo(c.Query("return_to")),
+		Expiry:   time.Now().Add(10 * time.Minute).Unix(),
+	})
+	if err != nil {
+		respondError(c, services.Internal("Failed to start login", err))
+		return
+	}
+
+	authURL, err := client.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		respondError(c, errProviderUnavailable.Wrap(err))
+		return
+	}
+
+	cookies.SetForRedirect(c, oauthStateCookie, cookie, 10*60, oauthCookiePath(client))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// ------------------ OAUTH CALLBACK ------------------
+// GET /auth/oauth/:provider/callback?code=...&state=...
+func OAuthCallbackHandler(c *gin.Context) {
+	providerName := c.Param("provider")
+	client, ok := oauth.Provider(providerName)
+	if !ok {
+		respondError(c, errUnknownProvider)
+		return
+	}
+
+	raw, err := c.Cookie(oauthStateCookie)
+	cookies.SetForRedirect(c, oauthStateCookie, "", -1, oauthCookiePath(client))
+	if err != nil {
+		respondError(c, errLoginStateExpired)
+		return
+	}
+	var st oauthState
+	if err := utils.ParseSignedJSON(raw, &st); err != nil || time.Now().Unix() > st.Expiry {
+		respondError(c, errLoginStateExpired)
+		return
+	}
+	if st.Provider != providerName || st.State == "" || c.Query("state") != st.State {
+		respondError(c, errLoginStateInvalid)
+		return
+	}
+	if providerErr := c.Query("error"); providerErr != "" {
+		respondError(c, services.Unauthorized("login_cancelled", "Login cancelled: "+providerErr))
+		return
+	}
+
+	ctx := c.Request.Context()
+	tokens, err := client.Exchange(ctx, c.Query("code"), st.Verifier)
+	if errors.Is(err, oauth.ErrGrantRejected) {
+		respondError(c, errLoginNotVerified.Wrap(err))
+		return
+	}
+	if err != nil {
+		respondError(c, errProviderUnavailable.Wrap(err))
+		return
+	}
+	ident, err := client.VerifyIDToken(ctx, tokens.IDToken, st.Nonce)
+	if err != nil {
+		respondError(c, errLoginNotVerified.Wrap(err))
+		return
+	}
+	if ident.Email == "" && tokens.AccessToken != "" {
+		if err := client.UserInfo(ctx, tokens.AccessToken, ident); err != nil {
+			respondError(c, errProviderUnavailable.Wrap(err))
+			return
+		}
+	}
+
+	accessToken, role, err := services.OAuthLoginService(config.DB, providerName, ident, guestCartToken(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	clearGuestCartCookie(c)
+
+	cookies.Set(c, "access_token", accessToken, 30*120, "/")
+	if st.ReturnTo != "" {
+		c.Redirect(http.StatusFound, st.ReturnTo)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "success",
+		"message":      "✅ Login successful",
+		"role":         role,
+		"access_token": accessToken,
+	})
+}
+
+// oauthCookiePath scopes the state cookie to the provider's callback, which
+// may be on a different API mount than the login start
+func oauthCookiePath(client *oauth.Client) string {
+	u, err := url.Parse(client.Config.RedirectURL)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return path.Dir(u.Path)
+}
+
+// safeReturnTo only allows same-site relative paths to avoid open redirects
+func safeReturnTo(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, "\\") {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"e-commerce/oauth"
+	"e-commerce/oauth/oauthtest"
+	"e-commerce/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const stubCallback = "http://shop.test/auth/oauth/stub/callback"
+
+// oauthRouter mounts the login routes with the stub registered as "stub"
+func oauthRouter(t *testing.T) (*gin.Engine, *oauthtest.Provider) {
+	t.Helper()
+	t.Setenv("LINK_SIGNING_SECRET", "test-secret")
+	gin.SetMode(gin.TestMode)
+
+	p := oauthtest.NewProvider()
+	t.Cleanup(p.Close)
+	oauth.RegisterProvider(p.Client("stub", stubCallback))
+
+	r := gin.New()
+	r.GET("/auth/oauth/:provider/start", OAuthStartHandler)
+	r.GET("/auth/oauth/:provider/callback", OAuthCallbackHandler)
+	return r, p
+}
+
+// startLogin follows start and the provider's approval and returns the
+// callback URL with the state cookie start set
+func startLogin(t *testing.T, r *gin.Engine, p *oauthtest.Provider) (*url.URL, *http.Cookie) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oauth/stub/start?return_to=/orders", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("start returned %d: %s", w.Code, w.Body)
+	}
+	authURL := w.Header().Get("Location")
+	q, _ := url.Parse(authURL)
+	if q.Query().Get("code_challenge_method") != "S256" || q.Query().Get("nonce") == "" || q.Query().Get("state") == "" {
+		t.Fatalf("authorization URL lacks PKCE, nonce or state: %s", authURL)
+	}
+
+	var state *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == oauthStateCookie {
+			state = c
+		}
+	}
+	if state == nil || !state.HttpOnly {
+		t.Fatal("start did not set an HttpOnly state cookie")
+	}
+
+	callback, err := p.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback, state
+}
+
+func callback(r *gin.Engine, callbackURL *url.URL, state *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, callbackURL.RequestURI(), nil)
+	if state != nil {
+		req.AddCookie(state)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func assertError(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != status || body.Error.Code != code {
+		t.Fatalf("got %d %q, want %d %q: %s", w.Code, body.Error.Code, status, code, w.Body)
+	}
+}
+
+func TestOAuthStartUnknownProvider(t *testing.T) {
+	r, _ := oauthRouter(t)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/auth/oauth/nope/start", nil))
+	assertError(t, w, http.StatusNotFound, "unknown_provider")
+}
+
+func TestOAuthCallbackWithoutStateCookie(t *testing.T) {
+	r, p := oauthRouter(t)
+	cb, _ := startLogin(t, r, p)
+	assertError(t, callback(r, cb, nil), http.StatusBadRequest, "login_state_expired")
+}
+
+func TestOAuthCallbackStateMismatch(t *testing.T) {
+	r, p := oauthRouter(t)
+	cb, state := startLogin(t, r, p)
+	q := cb.Query()
+	q.Set("state", "forged")
+	cb.RawQuery = q.Encode()
+	assertError(t, callback(r, cb, state), http.StatusBadRequest, "login_state_invalid")
+}
+
+func TestOAuthCallbackTamperedStateCookie(t *testing.T) {
+	r, p := oauthRouter(t)
+	cb, state := startLogin(t, r, p)
+	state.Value += "x"
+	assertError(t, callback(r, cb, state), http.StatusBadRequest, "login_state_expired")
+}
+
+func TestOAuthCallbackNonceMismatch(t *testing.T) {
+	r, p := oauthRouter(t)
+	p.Nonce = "replayed-nonce"
+	cb, state := startLogin(t, r, p)
+	assertError(t, callback(r, cb, state), http.StatusUnauthorized, "login_not_verified")
+}
+
+func TestOAuthCallbackPKCEMismatch(t *testing.T) {
+	r, p := oauthRouter(t)
+	cb, _ := startLogin(t, r, p)
+
+	// a validly signed state whose verifier does not match the challenge
+	// the provider saw, as when a code is injected into another login
+	forged, err := utils.SignJSON(oauthState{
+		Provider: "stub",
+		State:    cb.Query().Get("state"),
+		Nonce:    "n",
+		Verifier: "a-different-verifier",
+		Expiry:   time.Now().Add(time.Minute).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := callback(r, cb, &http.Cookie{Name: oauthStateCookie, Value: forged})
+	assertError(t, w, http.StatusUnauthorized, "login_not_verified")
+}
+
+func TestOAuthCallbackExpiredState(t *testing.T) {
+	r, p := oauthRouter(t)
+	cb, _ := startLogin(t, r, p)
+	expired, err := utils.SignJSON(oauthState{
+		Provider: "stub",
+		State:    cb.Query().Get("state"),
+		Expiry:   time.Now().Add(-time.Minute).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := callback(r, cb, &http.Cookie{Name: oauthStateCookie, Value: expired})
+	assertError(t, w, http.StatusBadRequest, "login_state_expired")
+}
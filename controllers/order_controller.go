@@ -13,31 +13,52 @@ import (
 // PlaceOrderRequest for user order creation
 type PlaceOrderRequest struct {
 	Address string `json:"address" binding:"required"`
+	// CartVersion is the version of the cart the customer reviewed
+	CartVersion string `json:"cart_version"`
+	// Currency to pay in; defaults to the store currency
+	Currency string `json:"currency"`
+}
+
+// actorID is the logged-in admin, recorded on what they change
+func actorID(c *gin.Context) *uint {
+	if v, exists := c.Get("userID"); exists {
+		if id, ok := v.(int); ok {
+			uid := uint(id)
+			return &uid
+		}
+	}
+	return nil
 }
 
 // POST /order - Create new order
 func PlaceOrder(c *gin.Context) {
 	var req PlaceOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	uid, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 
 	userIDInt, ok := uid.(int)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID type"})
+		respondError(c, errUserIDType)
 		return
 	}
 
-	order, err := services.CreateOrder(config.DB, uint(userIDInt), req.Address)
+	currency, err := orderCurrency(req.Currency)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
+		return
+	}
+
+	order, err := services.CreateOrder(config.DB, uint(userIDInt), req.Address, currency, req.CartVersion)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -48,19 +69,19 @@ func PlaceOrder(c *gin.Context) {
 func GetUserOrders(c *gin.Context) {
 	uid, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 
 	userIDInt, ok := uid.(int)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID type"})
+		respondError(c, errUserIDType)
 		return
 	}
 
 	orders, err := services.GetUserOrders(config.DB, uint(userIDInt))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -69,14 +90,20 @@ func GetUserOrders(c *gin.Context) {
 
 // GET /admin/orders?status=optional - Admin view all orders
 func GetAllOrders(c *gin.Context) {
-	status := c.Query("status")
-	orders, err := services.GetAllOrders(config.DB, status)
+	q, err := services.ParseListQuery(c.Request.URL.Query())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
+		return
+	}
+	orders, page, err := services.ListOrders(config.DB, q)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, orders)
+	// the body stays a bare array; paging is in the headers
+	setPaginationHeaders(c, page)
+	c.JSON(http.StatusOK, services.OrderResponses(orders))
 }
 
 
@@ -87,12 +114,12 @@ func GetAllOrders(c *gin.Context) {
 // 	status := c.PostForm("status") 
 // 	var order models.Order
 // 	if err := config.DB.First(&order, orderID).Error; err != nil {
-// 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+// 		respondError(c, services.ErrOrderNotFound)
 // 		return
 // 	}
 // 	order.Status = status
 // 	if err := config.DB.Save(&order).Error; err != nil {
-// 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+// 		respondError(c, services.Internal("Failed to update status", err))
 // 		return
 // 	}
 // 	c.JSON(http.StatusOK, gin.H{"message": "Status updated"})
@@ -103,19 +130,21 @@ func UpdateOrderStatusAdmin(c *gin.Context) {
 	idParam := c.Param("id")
 	orderID, err := strconv.Atoi(idParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+		respondError(c, invalidID("order"))
 		return
 	}
 	var req struct {
 		Status string `json:"status"`
+		// Note is kept in the order's status history
+		Note string `json:"note"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
-	updatedOrder, err := services.UpdateOrderStatusAdmin(config.DB, uint(orderID), req.Status)
+	updatedOrder, err := services.UpdateOrderStatusAdmin(config.DB, uint(orderID), req.Status, actorID(c), req.Note)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, updatedOrder)
@@ -126,25 +155,25 @@ func GetOrder(c *gin.Context) {
 	idParam := c.Param("id")
 	orderID, err := strconv.Atoi(idParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+		respondError(c, invalidID("order"))
 		return
 	}
 
 	uid, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 	userIDInt, ok := uid.(int)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID type"})
+		respondError(c, errUserIDType)
 		return
 	}
 	userID := uint(userIDInt)
 
 	order, err := services.GetOrderByID(config.DB, uint(orderID), userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -156,24 +185,24 @@ func DeleteOrder(c *gin.Context) {
 	idParam := c.Param("id")
 	orderID, err := strconv.Atoi(idParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order id"})
+		respondError(c, invalidID("order"))
 		return
 	}
 
 	uid, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 	userIDInt, ok := uid.(int)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID type"})
+		respondError(c, errUserIDType)
 		return
 	}
 	userID := uint(userIDInt)
 
 	if err := services.DeleteOrder(config.DB, uint(orderID), userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
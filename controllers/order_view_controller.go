@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"e-commerce/config"
+	"e-commerce/money"
+	"e-commerce/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The order detail page posts its forms back to /view/orders/:id/... and
+// every handler redirects to the page with a notice or error to show.
+
+// ---------------- ORDER DETAIL ----------------
+func ShowOrderDetailPage(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	detail, err := services.GetOrderDetail(config.DB, uint(id))
+	if err != nil {
+		if errors.Is(err, services.ErrOrderNotFound) {
+			c.String(http.StatusNotFound, "Order not found")
+			return
+		}
+		c.String(http.StatusInternalServerError, "Failed to load order")
+		return
+	}
+
+	renderHTML(c, http.StatusOK, "order_detail.html", gin.H{
+		"title":    fmt.Sprintf("Order #%d", detail.Order.ID),
+		"detail":   detail,
+		"statuses": append([]string{detail.Order.Status}, services.NextOrderStatuses(detail.Order.Status)...),
+		"notice":   c.Query("notice"),
+		"error":    c.Query("error"),
+		"Active":   "orders",
+	})
+}
+
+// ---------------- UPDATE STATUS (PUT via _method) ----------------
+func UpdateOrderStatusForm(c *gin.Context) {
+	id, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+	_, err := services.UpdateOrderStatusAdmin(config.DB, id, c.PostForm("status"), actorID(c), c.PostForm("note"))
+	redirectToOrder(c, id, err, "Status updated")
+}
+
+// ---------------- ADD SHIPMENT ----------------
+func AddShipmentForm(c *gin.Context) {
+	id, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+	_, err := services.AddShipment(config.DB, id, services.ShipmentInput{
+		Carrier:        c.PostForm("carrier"),
+		TrackingNumber: c.PostForm("tracking_number"),
+		TrackingURL:    c.PostForm("tracking_url"),
+	}, actorID(c))
+	redirectToOrder(c, id, err, "Shipment added and customer emailed")
+}
+
+// ---------------- REFUND ----------------
+func RefundOrderForm(c *gin.Context) {
+	id, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	// amount is a decimal in the order currency; empty refunds everything left
+	input := services.RefundInput{
+		Reason:  c.PostForm("reason"),
+		Restock: c.PostForm("restock") != "",
+	}
+	if raw := strings.TrimSpace(c.PostForm("amount")); raw != "" {
+		currency, err := money.ParseCurrency(c.PostForm("currency"))
+		major, perr := strconv.ParseFloat(raw, 64)
+		if err != nil || perr != nil || major <= 0 {
+			redirectToOrder(c, id, services.Invalid("invalid_refund", "Invalid refund",
+				services.FieldError{Field: "amount", Message: "must be a positive amount"}), "")
+			return
+		}
+		input.Amount = money.FromMajor(major, currency)
+	}
+
+	refund, err := services.RefundOrder(config.DB, id, input, actorID(c))
+	notice := ""
+	if err == nil {
+		notice = "Refunded " + refund.Amount.String()
+	}
+	redirectToOrder(c, id, err, notice)
+}
+
+// ---------------- NOTES ----------------
+func AddOrderNoteForm(c *gin.Context) {
+	id, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+	_, err := services.AddOrderNote(config.DB, id, actorID(c), c.PostForm("body"))
+	redirectToOrder(c, id, err, "Note added")
+}
+
+// DELETE via _method
+func DeleteOrderNoteForm(c *gin.Context) {
+	id, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+	noteID, err := strconv.ParseUint(c.Param("note_id"), 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid note ID")
+		return
+	}
+	err = services.DeleteOrderNote(config.DB, id, uint(noteID))
+	redirectToOrder(c, id, err, "Note deleted")
+}
+
+// ---------------- HELPERS ----------------
+func orderIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid order ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// redirectToOrder sends the browser back to the order page (303, so a
+// refresh does not resubmit) with the outcome of the form
+func redirectToOrder(c *gin.Context, id uint, err error, notice string) {
+	q := url.Values{}
+	if err != nil {
+		_ = c.Error(err) // for the audit log; the page shows the message
+		q.Set("error", errorText(err))
+	} else {
+		q.Set("notice", notice)
+	}
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/view/orders/%d?%s", id, q.Encode()))
+}
@@ -3,14 +3,25 @@ package controllers
 import (
 	"e-commerce/config"
 	"e-commerce/models"
+	"e-commerce/money"
 	"e-commerce/services"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stripe/stripe-go/v74"
 	"github.com/stripe/stripe-go/v74/paymentintent"
+	"gorm.io/gorm"
+)
+
+var (
+	errPaymentNotStarted = services.Upstream("payment_unavailable", "Payment could not be started")
+	errPaymentNotFound   = services.NotFound("payment_not_found", "Payment not found")
+	errPaymentProcessed  = services.Conflict("payment_processed", "Payment already processed")
+	errPaymentPending    = services.Conflict("payment_pending", "Pending payment already exists")
+	errOrderNotPending   = services.Conflict("order_not_pending", "Payment already initiated or order not pending")
 )
 
 // PaymentResponse DTO
@@ -18,7 +29,7 @@ type PaymentResponse struct {
 	ID        uint      `json:"id"`
 	PaymentID string    `json:"payment_id"`
 	Status    string    `json:"status"`
-	Amount    float64   `json:"amount"`
+	Amount    money.Money `json:"amount"`
 	Gateway   string    `json:"gateway"`
 	OrderID   uint      `json:"order_id"`
 	CreatedAt string    `json:"created_at"`
@@ -31,39 +42,57 @@ func CreatePaymentIntent(c *gin.Context) {
 		OrderID uint `json:"order_id"`
 	}
 	var body RequestBody
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, err)
 		return
 	}
 
 	var order models.Order
 	if err := config.DB.Preload("OrderItems.Product").Preload("User").First(&order, body.OrderID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		respondError(c, services.ErrOrderNotFound)
 		return
 	}
 
 	if order.Status != "pending" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment already initiated or order not pending"})
+		respondError(c, errOrderNotPending)
+		return
+	}
+	if err := services.CheckOrderingAllowed(config.DB, order.UserID); err != nil {
+		respondError(c, err)
 		return
 	}
 
 	var existingPayment models.Payment
 	if err := config.DB.First(&existingPayment, "order_id = ? AND status = ?", order.ID, "pending").Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Pending payment already exists"})
+		respondError(c, errPaymentPending)
+		return
+	}
+
+	paymentResp, clientSecret, err := startStripePayment(order)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"payment":       paymentResp,
+		"client_secret": clientSecret,
+	})
+}
+
+// startStripePayment creates the PaymentIntent and its pending payment record
+func startStripePayment(order models.Order) (*PaymentResponse, string, error) {
 	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
 	params := &stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(int64(order.TotalAmount * 100)),
-		Currency: stripe.String(string(stripe.CurrencyINR)),
+		// Stripe takes the same minor units
+		Amount:   stripe.Int64(order.TotalAmount.Amount),
+		Currency: stripe.String(strings.ToLower(order.TotalAmount.Currency)),
 	}
 	params.AddMetadata("order_id", strconv.Itoa(int(order.ID)))
 
 	pi, err := paymentintent.New(params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, "", errPaymentNotStarted.Wrap(err)
 	}
 
 	payment := models.Payment{
@@ -74,11 +103,10 @@ func CreatePaymentIntent(c *gin.Context) {
 		Status:    "pending",
 	}
 	if err := config.DB.Create(&payment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment record"})
-		return
+		return nil, "", services.Internal("Failed to create payment record", err)
 	}
 
-	paymentResp := PaymentResponse{
+	return &PaymentResponse{
 		ID:        payment.ID,
 		PaymentID: payment.PaymentID,
 		Status:    payment.Status,
@@ -87,12 +115,7 @@ func CreatePaymentIntent(c *gin.Context) {
 		OrderID:   payment.OrderID,
 		CreatedAt: payment.CreatedAt.Format("2006-01-02 15:04:05"),
 		UpdatedAt: payment.UpdatedAt.Format("2006-01-02 15:04:05"),
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"payment":       paymentResp,
-		"client_secret": pi.ClientSecret,
-	})
+	}, pi.ClientSecret, nil
 }
 
 // PUT /payments/:payment_id/update - Update payment status
@@ -102,47 +125,68 @@ func UpdatePaymentStatus(c *gin.Context) {
 		Status string `json:"status"`
 	}
 	var body Body
-	if err := c.BindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, err)
 		return
 	}
 
 	var payment models.Payment
 	if err := config.DB.Preload("Order.OrderItems.Product").Preload("Order.User").
 		First(&payment, "payment_id = ?", paymentID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		respondError(c, errPaymentNotFound)
 		return
 	}
 
 	if payment.Status != "pending" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment already processed"})
+		respondError(c, errPaymentProcessed)
 		return
 	}
 	if body.Status != "succeeded" && body.Status != "failed" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		respondError(c, services.Invalid("invalid_status", "Invalid status",
+			services.FieldError{Field: "status", Message: "must be one of: succeeded, failed"}))
 		return
 	}
 
 	payment.Status = body.Status
-	if err := config.DB.Save(&payment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment"})
-		return
-	}
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&payment).Error; err != nil {
+			return err
+		}
 
-	switch body.Status {
-	case "succeeded":
-		for _, item := range payment.Order.OrderItems {
-			if item.Product.StockQuantity >= item.Quantity {
-				item.Product.StockQuantity -= item.Quantity
-				config.DB.Save(&item.Product)
+		switch body.Status {
+		case "succeeded":
+			for _, item := range payment.Order.OrderItems {
+				if item.Product.StockQuantity >= item.Quantity {
+					item.Product.StockQuantity -= item.Quantity
+					if err := tx.Save(&item.Product).Error; err != nil {
+						return err
+					}
+				}
+			}
+			previous := payment.Order.Status
+			payment.Order.Status = "processing"
+			if err := tx.Save(&payment.Order).Error; err != nil {
+				return err
+			}
+			if err := services.RecordOrderStatus(tx, payment.Order.ID, previous, "processing", nil, "Payment succeeded"); err != nil {
+				return err
 			}
+			if err := services.EnqueueClearCart(tx, payment.Order.UserID); err != nil {
+				return err
+			}
+			return services.SendOrderConfirmationEmail(tx, payment.Order)
+		case "failed":
+			previous := payment.Order.Status
+			payment.Order.Status = "failed"
+			if err := tx.Save(&payment.Order).Error; err != nil {
+				return err
+			}
+			return services.RecordOrderStatus(tx, payment.Order.ID, previous, "failed", nil, "Payment failed")
 		}
-		config.DB.Where("user_id = ?", payment.Order.UserID).Delete(&models.CartItem{})
-		payment.Order.Status = "processing"
-		config.DB.Save(&payment.Order)
-	case "failed":
-		payment.Order.Status = "failed"
-		config.DB.Save(&payment.Order)
+		return nil
+	}); err != nil {
+		respondError(c, services.Internal("Failed to update payment", err))
+		return
 	}
 
 	orderItemsResp := []services.OrderItemResponse{}
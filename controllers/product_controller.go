@@ -7,18 +7,53 @@ import (
 
 	"e-commerce/config"
 	"e-commerce/models"
+	"e-commerce/money"
+	"e-commerce/services"
+	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
 )
 
 // ---------------- INPUT STRUCT ----------------
 type ProductInput struct {
-	Name          string  `json:"name" binding:"required"`
-	Description   string  `json:"description"`
-	Price         float64 `json:"price" binding:"required"`
-	StockQuantity int     `json:"stock_quantity" binding:"required"`
-	Category      string  `json:"category"`
-	ImageURL      string  `json:"image_url"`
+	Name          string       `json:"name" binding:"required"`
+	Description   string       `json:"description"`
+	Price         *money.Money `json:"price" binding:"required"` // in the store currency
+	StockQuantity int          `json:"stock_quantity" binding:"required"`
+	Category      string       `json:"category"`
+	ImageURL      string       `json:"image_url"`
+	// Prices in other currencies; when sent, replaces the existing ones
+	Prices []money.Money `json:"prices"`
+}
+
+// productPrices checks the input's prices and returns the extra-currency ones
+func productPrices(input ProductInput) ([]models.ProductPrice, error) {
+	var fields []services.FieldError
+	if input.Price.Currency != money.DefaultCurrency() {
+		fields = append(fields, services.FieldError{Field: "price.currency", Message: "must be the store currency (" + money.DefaultCurrency() + ")"})
+	}
+	if input.Price.Amount < 0 {
+		fields = append(fields, services.FieldError{Field: "price.amount", Message: "must not be negative"})
+	}
+
+	prices := []models.ProductPrice{}
+	seen := map[string]bool{input.Price.Currency: true}
+	for i, price := range input.Prices {
+		field := fmt.Sprintf("prices[%d]", i)
+		if seen[price.Currency] {
+			fields = append(fields, services.FieldError{Field: field + ".currency", Message: "is already priced"})
+		}
+		if price.Amount < 0 {
+			fields = append(fields, services.FieldError{Field: field + ".amount", Message: "must not be negative"})
+		}
+		seen[price.Currency] = true
+		prices = append(prices, models.ProductPrice{Price: price})
+	}
+
+	if len(fields) > 0 {
+		return nil, services.Invalid("invalid_price", "Invalid product price", fields...)
+	}
+	return prices, nil
 }
 
 /*---------------------------------------------------- POST FORM BASED-------------------------------------------------*/
@@ -128,20 +163,26 @@ func CreateProductHandler(c *gin.Context) {
 	var input ProductInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		fmt.Println("❌ JSON bind error:", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
+		return
+	}
+	prices, err := productPrices(input)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 	product := models.Product{
 		Name:          input.Name,
 		Description:   input.Description,
-		Price:         input.Price,
+		Price:         *input.Price,
 		StockQuantity: input.StockQuantity,
 		Category:      input.Category,
 		ImageURL:      input.ImageURL,
+		Prices:        prices,
 	}
 	if err := config.DB.Create(&product).Error; err != nil {
 		fmt.Println("❌ DB create error:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+		respondError(c, services.Internal("Failed to create product", err))
 		return
 	}
 	fmt.Println("✅ Product created successfully:", product.Name)
@@ -156,17 +197,22 @@ func UpdateProductHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		respondError(c, invalidID("product"))
 		return
 	}
 	var product models.Product
-	if err := config.DB.First(&product, uint(id)).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	if err := config.DB.Preload("Prices").First(&product, uint(id)).Error; err != nil {
+		respondError(c, services.ErrProductNotFound)
 		return
 	}
 	var input ProductInput
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
+		return
+	}
+	prices, err := productPrices(input)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 	if input.Name != "" {
@@ -175,9 +221,10 @@ func UpdateProductHandler(c *gin.Context) {
 	if input.Description != "" {
 		product.Description = input.Description
 	}
-	if input.Price != 0 {
-		product.Price = input.Price
+	if !input.Price.IsZero() {
+		product.Price = *input.Price
 	}
+	previousStock := product.StockQuantity
 	if input.StockQuantity != 0 {
 		product.StockQuantity = input.StockQuantity
 	}
@@ -187,8 +234,30 @@ func UpdateProductHandler(c *gin.Context) {
 	if input.ImageURL != "" {
 		product.ImageURL = input.ImageURL
 	}
-	if err := config.DB.Save(&product).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Prices").Save(&product).Error; err != nil {
+			return err
+		}
+		if input.Prices != nil {
+			if err := tx.Where("product_id = ?", product.ID).Delete(&models.ProductPrice{}).Error; err != nil {
+				return err
+			}
+			for i := range prices {
+				prices[i].ProductID = product.ID
+			}
+			if len(prices) > 0 {
+				if err := tx.Create(&prices).Error; err != nil {
+					return err
+				}
+			}
+			product.Prices = prices
+		}
+		if product.StockQuantity > previousStock {
+			return services.ProductRestocked(tx, product.ID)
+		}
+		return nil
+	}); err != nil {
+		respondError(c, services.Internal("Failed to update product", err))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Product updated successfully", "product": product})
@@ -199,11 +268,11 @@ func DeleteProductHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		respondError(c, invalidID("product"))
 		return
 	}
 	if err := config.DB.Delete(&models.Product{}, uint(id)).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
+		respondError(c, services.Internal("Failed to delete product", err))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
@@ -212,8 +281,8 @@ func DeleteProductHandler(c *gin.Context) {
 // ---------------- GET ALL PRODUCTS (PUBLIC) ----------------
 func GetProductsHandler(c *gin.Context) {
 	var products []models.Product
-	if err := config.DB.Find(&products).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
+	if err := config.DB.Preload("Prices").Find(&products).Error; err != nil {
+		respondError(c, services.Internal("Failed to fetch products", err))
 		return
 	}
 
@@ -225,15 +294,92 @@ func GetProductByIDHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		respondError(c, invalidID("product"))
 		return
 	}
 
 	var product models.Product
-	if err := config.DB.First(&product, uint(id)).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+	if err := config.DB.Preload("Prices").First(&product, uint(id)).Error; err != nil {
+		respondError(c, services.ErrProductNotFound)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"product": product})
 }
+
+// ---------------- NOTIFY ME (PUBLIC) ----------------
+// POST /products/:id/notify-me - logged-in users are subscribed with their
+// account email, guests send {"email": "..."}
+func NotifyMeHandler(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		respondError(c, invalidID("product"))
+		return
+	}
+
+	var userID *uint
+	if v, exists := c.Get("userID"); exists {
+		if uid, ok := v.(int); ok {
+			u := uint(uid)
+			userID = &u
+		}
+	}
+
+	var input struct {
+		Email string `json:"email" binding:"omitempty,email"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+	if userID == nil && input.Email == "" {
+		respondError(c, services.ErrEmailRequired)
+		return
+	}
+
+	sub, created, err := services.SubscribeBackInStock(config.DB, uint(id), userID, input.Email)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if !created {
+		c.JSON(http.StatusOK, gin.H{"message": "You are already on the list for this product", "subscription": sub})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "We will email you when this product is back in stock", "subscription": sub})
+}
+
+// ---------------- GET ALL PRODUCTS (ADMIN) ----------------
+// GET /admin/products - products with their waiting back-in-stock subscribers
+func GetAdminProductsHandler(c *gin.Context) {
+	q, err := services.ParseListQuery(c.Request.URL.Query())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	products, page, err := services.ListProducts(config.DB, q)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	counts, err := services.SubscriberCounts(config.DB)
+	if err != nil {
+		respondError(c, services.Internal("Failed to fetch subscriber counts", err))
+		return
+	}
+
+	type adminProduct struct {
+		models.Product
+		SubscriberCount int64 `json:"subscriber_count"`
+	}
+	resp := make([]adminProduct, 0, len(products))
+	for _, p := range products {
+		resp = append(resp, adminProduct{Product: p, SubscriberCount: counts[p.ID]})
+	}
+	setPaginationHeaders(c, page)
+	c.JSON(http.StatusOK, gin.H{"products": resp, "pagination": page})
+}
@@ -3,56 +3,36 @@ package controllers
 import (
 	"net/http"
 	"strconv"
-	"time"
 
 	"e-commerce/config"
-	"e-commerce/models"
+	"e-commerce/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Allowed production status values
-var allowedStatuses = map[string]bool{
-	"started":     true,
-	"in_progress": true,
-	"completed":   true,
-}
-
 // ---------------- START PRODUCTION ----------------
 func StartProductionHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	productID, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid product ID"})
-		return
-	}
-
-	var product models.Product
-	if err := config.DB.First(&product, uint(productID)).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Product not found"})
-		return
-	}
-
-	// Prevent multiple active productions
-	var existing models.ProductProduction
-	if err := config.DB.Where("product_id = ? AND status != ?", uint(productID), "completed").First(&existing).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Production already in progress for this product"})
+		respondError(c, invalidID("product"))
 		return
 	}
 
-	production := models.ProductProduction{
-		ProductID: uint(productID),
-		Status:    "started",
+	// Optional number of units the run adds to stock when completed
+	var input struct {
+		Quantity int `json:"quantity" binding:"min=0"`
 	}
-
-	if err := config.DB.Create(&production).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to start production"})
-		return
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			respondError(c, err)
+			return
+		}
 	}
 
-	// Preload Product
-	if err := config.DB.Preload("Product").First(&production, production.ID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to fetch production with product"})
+	production, err := services.StartProduction(config.DB, uint(productID), input.Quantity)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -64,13 +44,7 @@ func UpdateProductionStatusHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	productionID, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid production ID"})
-		return
-	}
-
-	var production models.ProductProduction
-	if err := config.DB.First(&production, uint(productionID)).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Production not found"})
+		respondError(c, invalidID("production"))
 		return
 	}
 
@@ -78,29 +52,13 @@ func UpdateProductionStatusHandler(c *gin.Context) {
 		Status string `json:"status" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
-		return
-	}
-
-	if !allowedStatuses[input.Status] {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid status value"})
+		respondError(c, err)
 		return
 	}
 
-	production.Status = input.Status
-	if input.Status == "completed" {
-		now := time.Now()
-		production.CompletedAt = &now
-	}
-
-	if err := config.DB.Save(&production).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to update production status"})
-		return
-	}
-
-	// Preload Product
-	if err := config.DB.Preload("Product").First(&production, production.ID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to fetch production with product"})
+	production, err := services.UpdateProductionStatus(config.DB, uint(productionID), input.Status)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -112,13 +70,13 @@ func GetProductionDetailsHandler(c *gin.Context) {
 	idParam := c.Param("id")
 	productionID, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid production ID"})
+		respondError(c, invalidID("production"))
 		return
 	}
 
-	var production models.ProductProduction
-	if err := config.DB.Preload("Product").First(&production, uint(productionID)).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Production not found"})
+	production, err := services.GetProduction(config.DB, uint(productionID))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -127,11 +85,11 @@ func GetProductionDetailsHandler(c *gin.Context) {
 
 // ---------------- GET ALL PRODUCTIONS ----------------
 func GetAllProductionsHandler(c *gin.Context) {
-	var productions []models.ProductProduction
-	if err := config.DB.Preload("Product").Find(&productions).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to fetch productions"})
+	productions, err := services.GetAllProductions(config.DB)
+	if err != nil {
+		respondError(c, services.Internal("Failed to fetch productions", err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": productions})
-}
\ No newline at end of file
+}
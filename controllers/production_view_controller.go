@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"e-commerce/config"
+	"e-commerce/models"
+	"e-commerce/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ---------------- PRODUCTION BOARD ----------------
+func ShowProductionBoardPage(c *gin.Context) {
+	board, err := services.ProductionBoard(config.DB)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to load production runs")
+		return
+	}
+
+	renderHTML(c, http.StatusOK, "production_board.html", gin.H{
+		"title":  "Production",
+		"board":  board,
+		"notice": c.Query("notice"),
+		"error":  c.Query("error"),
+		"Active": "production",
+	})
+}
+
+// ---------------- PRODUCTION RUN ----------------
+func ShowProductionRunPage(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid production ID")
+		return
+	}
+
+	production, err := services.GetProduction(config.DB, uint(id))
+	if err != nil {
+		if errors.Is(err, services.ErrProductionNotFound) {
+			c.String(http.StatusNotFound, "Production not found")
+			return
+		}
+		c.String(http.StatusInternalServerError, "Failed to load production")
+		return
+	}
+
+	renderHTML(c, http.StatusOK, "production_run.html", gin.H{
+		"title":      fmt.Sprintf("Production run #%d", production.ID),
+		"production": production,
+		"statuses":   append([]string{production.Status}, services.NextProductionStatuses(production.Status)...),
+		"notice":     c.Query("notice"),
+		"error":      c.Query("error"),
+		"Active":     "production",
+	})
+}
+
+// ---------------- PRODUCT HISTORY ----------------
+func ShowProductionHistoryPage(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var product models.Product
+	if err := config.DB.First(&product, id).Error; err != nil {
+		c.String(http.StatusNotFound, "Product not found")
+		return
+	}
+	runs, err := services.CompletedProductions(config.DB, product.ID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to load production history")
+		return
+	}
+	var produced int
+	for _, r := range runs {
+		produced += r.Quantity
+	}
+	// nil when no run is open
+	active, _ := services.ActiveProduction(config.DB, product.ID)
+
+	renderHTML(c, http.StatusOK, "production_history.html", gin.H{
+		"title":    "Production history: " + product.Name,
+		"product":  product,
+		"runs":     runs,
+		"produced": produced,
+		"active":   active,
+		"Active":   "production",
+	})
+}
+
+// ---------------- START PRODUCTION ----------------
+// Posted from the product page; failures go back there
+func StartProductionForm(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.PostForm("product_id"), 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	quantity := 0
+	if raw := strings.TrimSpace(c.PostForm("quantity")); raw != "" {
+		if quantity, err = strconv.Atoi(raw); err != nil {
+			quantity = -1 // refused by the service as invalid
+		}
+	}
+
+	production, err := services.StartProduction(config.DB, uint(productID), quantity)
+	if err != nil {
+		_ = c.Error(err)
+		q := url.Values{"error": {errorText(err)}}
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/view/products/edit/%d?%s", productID, q.Encode()))
+		return
+	}
+	redirectToProduction(c, production.ID, nil, "Production started")
+}
+
+// ---------------- UPDATE STATUS (PUT via _method) ----------------
+func UpdateProductionStatusForm(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid production ID")
+		return
+	}
+	_, err = services.UpdateProductionStatus(config.DB, uint(id), c.PostForm("status"))
+	redirectToProduction(c, uint(id), err, "Status updated")
+}
+
+// redirectToProduction sends the browser back to the run page (303) with
+// the outcome of the form
+func redirectToProduction(c *gin.Context, id uint, err error, notice string) {
+	q := url.Values{}
+	if err != nil {
+		_ = c.Error(err) // for the audit log; the page shows the message
+		q.Set("error", errorText(err))
+	} else {
+		q.Set("notice", notice)
+	}
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/view/production/%d?%s", id, q.Encode()))
+}
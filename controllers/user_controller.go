@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"bytes"
 	"e-commerce/config"
 	"e-commerce/models"
+	"e-commerce/services"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,33 +14,34 @@ import (
 func GetProfileHandler(c *gin.Context) {
 	userIDInterface, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 	userID := userIDInterface.(int)
 
 	var user models.User
 	if err := config.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, services.ErrUserNotFound)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":          user.ID,
-		"full_name":   user.FullName,
-		"email":       user.Email,
-		"role":        user.Role,
-		"is_blocked":  user.IsBlocked,
-		"is_verified": user.IsVerified,
-		"avatar_url":  user.AvatarURL,
-		"address":     user.Address,
+		"id":                    user.ID,
+		"full_name":             user.FullName,
+		"email":                 user.Email,
+		"role":                  user.Role,
+		"is_blocked":            user.IsBlocked,
+		"is_verified":           user.IsVerified,
+		"avatar_url":            user.AvatarURL,
+		"address":               user.Address,
+		"deletion_scheduled_at": user.DeletionScheduledAt,
 	})
 }
 
 func UpdateProfileHandler(c *gin.Context) {
 	userIDInterface, exists := c.Get("userID")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 	userID := userIDInterface.(int)
@@ -48,13 +52,13 @@ func UpdateProfileHandler(c *gin.Context) {
 		AvatarURL *string `json:"avatar_url"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	var user models.User
 	if err := config.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, services.ErrUserNotFound)
 		return
 	}
 
@@ -68,7 +72,7 @@ func UpdateProfileHandler(c *gin.Context) {
 		user.AvatarURL = body.AvatarURL
 	}
 	if err := config.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		respondError(c, services.Internal("Failed to update profile", err))
 		return
 	}
 
@@ -83,3 +87,91 @@ func UpdateProfileHandler(c *gin.Context) {
 		},
 	})
 }
+
+// GET /user/export?format=json|zip - everything stored about the caller
+func ExportUserDataHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		respondError(c, services.Invalid("invalid_format", "Invalid export format",
+			services.FieldError{Field: "format", Message: "must be json or zip"}))
+		return
+	}
+
+	export, err := services.ExportUserData(config.DB, userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	name := fmt.Sprintf("account-%d-%s", userID, export.ExportedAt.Format("20060102"))
+	c.Header("Cache-Control", "no-store")
+	if format == "json" {
+		c.Header("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		c.JSON(http.StatusOK, export)
+		return
+	}
+	// built in memory so a failure can still be reported as JSON
+	var buf bytes.Buffer
+	if err := export.WriteZip(&buf); err != nil {
+		respondError(c, services.Internal("Failed to build export", err))
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+name+`.zip"`)
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// DELETE /user/account - schedule the caller's account for erasure
+func DeleteAccountHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		ConfirmEmail string `json:"confirm_email"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	user, err := services.RequestAccountDeletion(config.DB, userID, body.ConfirmEmail)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":               "Your account will be deleted after the grace period; sign in and cancel before then to keep it",
+		"deletion_scheduled_at": user.DeletionScheduledAt,
+	})
+}
+
+// POST /user/account/cancel-deletion - keep the account
+func CancelAccountDeletionHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	if _, err := services.CancelAccountDeletion(config.DB, userID); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
+
+func currentUserID(c *gin.Context) (uint, bool) {
+	id, ok := c.Get("userID")
+	if !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return 0, false
+	}
+	userID, ok := id.(int)
+	if !ok {
+		respondError(c, errUserIDType)
+		return 0, false
+	}
+	return uint(userID), true
+}
@@ -2,26 +2,55 @@ package controllers
 
 import (
 	"e-commerce/config"
+	"e-commerce/jobs"
+	"e-commerce/middlewares"
 	"e-commerce/models"
+	"e-commerce/money"
+	"e-commerce/services"
+	"e-commerce/utils"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // ---------------- LOGIN ----------------
 func ShowLoginPage(c *gin.Context) {
-	c.HTML(http.StatusOK, "login.html", gin.H{
-		"title": "Login Page",
-	})
+	returnTo := panelReturnTo(c.Query("return_to"))
+
+	// already signed in as an admin: go straight to the panel
+	if token, err := c.Cookie("access_token"); err == nil && token != "" {
+		if _, role, err := utils.ValidateJWT(token); err == nil && role == "admin" {
+			if returnTo == "" {
+				returnTo = "/view/dashboard"
+			}
+			c.Redirect(http.StatusFound, returnTo)
+			return
+		}
+	}
+
+	data := gin.H{
+		"title":     "Login Page",
+		"return_to": returnTo,
+	}
+	switch c.Query("reason") {
+	case "expired":
+		data["error"] = "⚠️ Your session has expired, please log in again"
+	case "admins_only":
+		data["error"] = "❌ Only admin users can access the dashboard"
+	case "logged_out":
+		data["notice"] = "You have been logged out"
+	}
+	renderHTML(c, http.StatusOK, "login.html", data)
 }
 // ---------------- DASHBOARD ----------------
 func ShowDashboard(c *gin.Context) {
 	var totalUsers, totalProducts, totalOrders int64
 
-	// count total users
-	if err := config.DB.Model(&models.User{}).Count(&totalUsers).Error; err != nil {
+	// count total users; guest checkout records are not accounts
+	if err := config.DB.Model(&models.User{}).Where("role <> ?", services.RoleGuest).Count(&totalUsers).Error; err != nil {
 		totalUsers = 0
 	}
 	// Count total products
@@ -32,13 +61,54 @@ func ShowDashboard(c *gin.Context) {
 	if err := config.DB.Model(&models.Order{}).Count(&totalOrders).Error; err != nil {
 		totalOrders = 0
 	}
+	// sales analytics for the selected range, the last 30 days by default
+	var analyticsError string
+	r, err := services.ParseAnalyticsRange(c.Query("from"), c.Query("to"), c.Query("interval"), time.Now())
+	if err != nil {
+		analyticsError = "Invalid date range, showing the last 30 days"
+		r, _ = services.ParseAnalyticsRange("", "", "", time.Now())
+	}
+	analytics, err := services.GetAnalytics(config.DB, r)
+	if err != nil {
+		analyticsError = "Failed to load analytics"
+	}
+	// bar widths for the revenue chart, as percent of the best period
+	var bars []int64
+	if analytics != nil {
+		var best int64
+		for _, p := range analytics.RevenueSeries {
+			if p.Revenue.Amount > best {
+				best = p.Revenue.Amount
+			}
+		}
+		for _, p := range analytics.RevenueSeries {
+			if best > 0 {
+				bars = append(bars, p.Revenue.Amount*100/best)
+			} else {
+				bars = append(bars, 0)
+			}
+		}
+	}
 
-	c.HTML(http.StatusOK, "dashboard.html", gin.H{
-		"title":          "Admin Dashboard",
-		"total_users":    totalUsers,
-		"total_products": totalProducts,
-		"total_orders":   totalOrders,
-		"Active":         "dashboard", // for sidebar highlighting
+	// abandoned carts since the start of the range
+	abandoned, err := services.GetAbandonedCartStats(config.DB, r.From)
+	if err != nil {
+		abandoned = &services.AbandonedCartStats{RecoveredRevenue: money.Zero(money.DefaultCurrency())}
+	}
+
+	renderHTML(c, http.StatusOK, "dashboard.html", gin.H{
+		"title":           "Admin Dashboard",
+		"total_users":     totalUsers,
+		"total_products":  totalProducts,
+		"total_orders":    totalOrders,
+		"abandoned":       abandoned,
+		"analytics":       analytics,
+		"bars":            bars,
+		"analytics_error": analyticsError,
+		"from":            r.From.Format("2006-01-02"),
+		"to":              r.To.AddDate(0, 0, -1).Format("2006-01-02"),
+		"interval":        r.Interval,
+		"Active":          "dashboard", // for sidebar highlighting
 	})
 }
 
@@ -47,14 +117,16 @@ func ShowDashboard(c *gin.Context) {
 // ---------------- USERS ----------------
 func ShowUsersPage(c *gin.Context) {
 	var users []models.User
-	if err := config.DB.Order("id ASC").Find(&users).Error; err != nil {
-		c.HTML(http.StatusInternalServerError, "users.html", gin.H{"error": "Failed to fetch users"})
-		return
+	var page *services.Pagination
+	q, err := services.ParseListQuery(c.Request.URL.Query())
+	if err == nil {
+		users, page, err = services.ListUsers(config.DB, q)
 	}
 
-	c.HTML(http.StatusOK, "users.html", gin.H{
+	renderHTML(c, http.StatusOK, "users.html", gin.H{
 		"title":  "Manage Users",
 		"users":  users,
+		"list":   newListView(c, page, err),
 		"Active": "users",
 	})
 }
@@ -72,7 +144,7 @@ func ShowEditUserPage(c *gin.Context) {
 		return
 	}
 
-	c.HTML(http.StatusOK, "edit_user.html", gin.H{
+	renderHTML(c, http.StatusOK, "edit_user.html", gin.H{
 		"title": "Edit User",
 		"user":  user,
 	})
@@ -83,19 +155,28 @@ func ShowEditUserPage(c *gin.Context) {
 // ---------------- PRODUCTS ----------------
 func ShowProductsPage(c *gin.Context) {
 	var products []models.Product
-	if err := config.DB.Order("id ASC").Find(&products).Error; err != nil {
-		products = []models.Product{}
+	var page *services.Pagination
+	q, listErr := services.ParseListQuery(c.Request.URL.Query())
+	if listErr == nil {
+		products, page, listErr = services.ListProducts(config.DB, q)
+	}
+	// waiting back-in-stock subscribers per product
+	subscribers, err := services.SubscriberCounts(config.DB)
+	if err != nil {
+		subscribers = map[uint]int64{}
 	}
 
-	c.HTML(http.StatusOK, "products.html", gin.H{
-		"title":    "Manage Products",
-		"products": products,
-		"Active":   "products",
+	renderHTML(c, http.StatusOK, "products.html", gin.H{
+		"title":       "Manage Products",
+		"products":    products,
+		"subscribers": subscribers,
+		"list":        newListView(c, page, listErr),
+		"Active":      "products",
 	})
 }
 // ---------------- CREATE PRODUCT PAGE ----------------
 func ShowCreateProductPage(c *gin.Context) {
-	c.HTML(http.StatusOK, "create_product.html", gin.H{
+	renderHTML(c, http.StatusOK, "create_product.html", gin.H{
 		"title": "Add Product",
 	})
 }
@@ -113,9 +194,14 @@ func ShowEditProductPage(c *gin.Context) {
 		return
 	}
 
-	c.HTML(http.StatusOK, "edit_product.html", gin.H{
-		"title":   "Edit Product",
-		"product": product,
+	// the open production run, if any, for the production section
+	production, _ := services.ActiveProduction(config.DB, product.ID)
+
+	renderHTML(c, http.StatusOK, "edit_product.html", gin.H{
+		"title":      "Edit Product",
+		"product":    product,
+		"production": production,
+		"error":      c.Query("error"),
 	})
 }
 
@@ -123,26 +209,82 @@ func ShowEditProductPage(c *gin.Context) {
 // ---------------- ORDERS ----------------
 func ShowOrdersPage(c *gin.Context) {
 	var orders []models.Order
-	if err := config.DB.Preload("User").Preload("OrderItems").Order("id ASC").Find(&orders).Error; err != nil {
-		orders = []models.Order{}
+	var page *services.Pagination
+	q, err := services.ParseListQuery(c.Request.URL.Query())
+	if err == nil {
+		orders, page, err = services.ListOrders(config.DB, q)
 	}
-	c.HTML(http.StatusOK, "orders.html", gin.H{
+	renderHTML(c, http.StatusOK, "orders.html", gin.H{
 		"title":  "Manage Orders",
 		"orders": orders,
+		"list":   newListView(c, page, err),
 		"Active": "orders",
 	})
 }
 
+// ---------------- AUDIT LOG ----------------
+func ShowAuditLogPage(c *gin.Context) {
+	var logs []models.AuditLog
+	var page *services.Pagination
+	q, err := services.ParseListQuery(c.Request.URL.Query())
+	if err == nil {
+		logs, page, err = services.ListAuditLogs(config.DB, q)
+	}
+	renderHTML(c, http.StatusOK, "audit_log.html", gin.H{
+		"title":        "Audit Log",
+		"logs":         logs,
+		"entity_types": services.AuditEntityTypes(),
+		"list":         newListView(c, page, err),
+		"Active":       "audit",
+	})
+}
+
+// ---------------- JOBS (DEAD-LETTER) ----------------
+func ShowJobsPage(c *gin.Context) {
+	status := c.DefaultQuery("status", jobs.StatusDead)
+	list, err := jobs.List(config.DB, status, 200)
+	if err != nil {
+		list = []models.Job{}
+	}
+	counts, err := jobs.Counts(config.DB)
+	if err != nil {
+		counts = map[string]int64{}
+	}
+	renderHTML(c, http.StatusOK, "jobs.html", gin.H{
+		"title":  "Background Jobs",
+		"jobs":   list,
+		"counts": counts,
+		"status": status,
+		"Active": "jobs",
+	})
+}
+
+// renderHTML is c.HTML for the panel's pages. It adds the CSRF token that
+// the "csrf_field" and "csrf_meta" partials put in forms and script requests.
+func renderHTML(c *gin.Context, code int, name string, data gin.H) {
+	data["csrf_token"] = middlewares.CSRFToken(c)
+	c.HTML(code, name, data)
+}
+
 // -------MIDDLEWARE
-func MethodOverride() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		if c.Request.Method == http.MethodPost {
-			if method := c.PostForm("_method"); method != "" {
-				c.Request.Method = method
+// MethodOverride lets HTML forms send PUT, PATCH and DELETE as a POST with
+// a _method field. It wraps the router because Gin picks the route before
+// any middleware runs.
+func MethodOverride(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && isForm(r) {
+			switch method := strings.ToUpper(r.PostFormValue("_method")); method {
+			case http.MethodPut, http.MethodPatch, http.MethodDelete:
+				r.Method = method
 			}
 		}
-		c.Next()
-	}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isForm(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	return strings.HasPrefix(ct, "application/x-www-form-urlencoded") || strings.HasPrefix(ct, "multipart/form-data")
 }
 
 
@@ -167,7 +309,7 @@ func ShowAdminProfilePage(c *gin.Context) {
 		return
 	}
 
-	c.HTML(http.StatusOK, "admin_profile.html", gin.H{
+	renderHTML(c, http.StatusOK, "admin_profile.html", gin.H{
 		"title":  "Admin Profile",
 		"admin":  admin,
 		"Active": "profile",
@@ -193,7 +335,7 @@ func ShowEditAdminProfilePage(c *gin.Context) {
 		return
 	}
 
-	c.HTML(http.StatusOK, "edit_admin_profile.html", gin.H{
+	renderHTML(c, http.StatusOK, "edit_admin_profile.html", gin.H{
 		"title": "Edit Profile",
 		"admin": admin,
 	})
@@ -224,7 +366,7 @@ func UpdateAdminProfile(c *gin.Context) {
 	avatar := strings.TrimSpace(c.PostForm("avatar_url"))
 
 	if fullName == "" || email == "" {
-		c.HTML(http.StatusBadRequest, "edit_admin_profile.html", gin.H{
+		renderHTML(c, http.StatusBadRequest, "edit_admin_profile.html", gin.H{
 			"title": "Edit Profile",
 			"admin": admin,
 			"error": "Full name and email cannot be empty.",
@@ -233,7 +375,7 @@ func UpdateAdminProfile(c *gin.Context) {
 	}
 
 	if !strings.Contains(email, "@") || !strings.Contains(email, ".") {
-		c.HTML(http.StatusBadRequest, "edit_admin_profile.html", gin.H{
+		renderHTML(c, http.StatusBadRequest, "edit_admin_profile.html", gin.H{
 			"title": "Edit Profile",
 			"admin": admin,
 			"error": "Invalid email format.",
@@ -3,73 +3,146 @@ package controllers
 import (
 	"e-commerce/config"
 	"e-commerce/models"
+	"e-commerce/services"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ---------------- RESPONSE STRUCTS ----------------
+
+// SharedWishlistItem is the read-only view of an item on a shared list
+type SharedWishlistItem struct {
+	Product   ProductSummary `json:"product"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// ---------------- HELPERS ----------------
+
+// wishlistFromParam resolves the list a request works on: /wishlists/:id
+// routes name it, the original /wishlist routes use the default list
+func wishlistFromParam(c *gin.Context, userID uint) (uint, bool) {
+	if idParam := c.Param("id"); idParam != "" {
+		wishlistID, err := strconv.ParseUint(idParam, 10, 64)
+		if err != nil {
+			respondError(c, invalidID("wishlist"))
+			return 0, false
+		}
+		return uint(wishlistID), true
+	}
+
+	list, err := services.DefaultWishlist(config.DB, userID)
+	if err != nil {
+		respondError(c, services.Internal("Failed to load wishlist", err))
+		return 0, false
+	}
+	return list.ID, true
+}
+
 // ---------------- ADD TO WISHLIST ----------------
+// POST /wishlist, POST /wishlists/:id/items
 func AddToWishlist(c *gin.Context) {
 	userIDInt, exists := c.Get("userID")
 	id, ok := userIDInt.(int)
 	if !exists || !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 	userID := uint(id)
 
 	var body struct {
 		ProductID uint `json:"product_id" binding:"required"`
+		services.WishlistItemOptions
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		respondError(c, err)
 		return
 	}
 
-	var existing models.WishlistItem
-	if err := config.DB.Where("user_id = ? AND product_id = ?", userID, body.ProductID).First(&existing).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"message": "Product already in wishlist"})
+	wishlistID, ok := wishlistFromParam(c, userID)
+	if !ok {
 		return
 	}
 
-	wishlist := models.WishlistItem{
-		UserID:    userID,
-		ProductID: body.ProductID,
-	}
-	if err := config.DB.Create(&wishlist).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add product to wishlist"})
+	item, err := services.AddWishlistItem(config.DB, userID, wishlistID, body.ProductID, body.WishlistItemOptions)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Product added to wishlist successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Product added to wishlist successfully", "item": item})
 }
 
 // ---------------- GET WISHLIST ----------------
+// GET /wishlist - items of the default list
 func GetWishlist(c *gin.Context) {
 	userIDInt, exists := c.Get("userID")
 	id, ok := userIDInt.(int)
 	if !exists || !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 	userID := uint(id)
 
+	list, err := services.DefaultWishlist(config.DB, userID)
+	if err != nil {
+		respondError(c, services.Internal("Failed to fetch wishlist", err))
+		return
+	}
+
 	var wishlist []models.WishlistItem
-	if err := config.DB.Preload("Product").Where("user_id = ?", userID).Order("created_at desc").Find(&wishlist).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wishlist"})
+	if err := config.DB.Preload("Product").Where("wishlist_id = ?", list.ID).Order("created_at desc").Find(&wishlist).Error; err != nil {
+		respondError(c, services.Internal("Failed to fetch wishlist", err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"wishlist": wishlist})
 }
 
+// ---------------- UPDATE WISHLIST ITEM ----------------
+// PUT /wishlists/:id/items/:product_id - alert opt-ins
+func UpdateWishlistItem(c *gin.Context) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return
+	}
+	userID := uint(id)
+
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 64)
+	if err != nil {
+		respondError(c, invalidID("product"))
+		return
+	}
+	var body services.WishlistItemOptions
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	wishlistID, ok := wishlistFromParam(c, userID)
+	if !ok {
+		return
+	}
+
+	item, err := services.UpdateWishlistItem(config.DB, userID, wishlistID, uint(productID), body)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Wishlist item updated", "item": item})
+}
+
 // ---------------- REMOVE FROM WISHLIST ----------------
+// DELETE /wishlist/:product_id, DELETE /wishlists/:id/items/:product_id
 func RemoveFromWishlist(c *gin.Context) {
 	userIDInt, exists := c.Get("userID")
 	id, ok := userIDInt.(int)
 	if !exists || !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		respondError(c, services.ErrUnauthenticated)
 		return
 	}
 	userID := uint(id)
@@ -77,20 +150,256 @@ func RemoveFromWishlist(c *gin.Context) {
 	productIDStr := c.Param("product_id")
 	productID, err := strconv.ParseUint(productIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		respondError(c, invalidID("product"))
 		return
 	}
 	pid := uint(productID)
 
-	result := config.DB.Where("user_id = ? AND product_id = ?", userID, pid).Delete(&models.WishlistItem{})
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove product from wishlist"})
+	wishlistID, ok := wishlistFromParam(c, userID)
+	if !ok {
 		return
 	}
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"message": "Product not found in wishlist"})
+
+	if err := services.RemoveWishlistItem(config.DB, userID, wishlistID, pid); err != nil {
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Product removed from wishlist successfully"})
-}
\ No newline at end of file
+}
+
+// ---------------- MOVE TO CART ----------------
+// POST /wishlist/:product_id/move-to-cart, POST /wishlists/:id/items/:product_id/move-to-cart
+// optional body {"quantity": n}
+func MoveWishlistItemToCart(c *gin.Context) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return
+	}
+	userID := uint(id)
+
+	productID, err := strconv.ParseUint(c.Param("product_id"), 10, 64)
+	if err != nil {
+		respondError(c, invalidID("product"))
+		return
+	}
+
+	var body struct {
+		Quantity int `json:"quantity" binding:"omitempty,min=1"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+	if body.Quantity == 0 {
+		body.Quantity = 1
+	}
+
+	wishlistID, ok := wishlistFromParam(c, userID)
+	if !ok {
+		return
+	}
+
+	cartItem, err := services.MoveWishlistItemToCart(config.DB, userID, wishlistID, uint(productID), body.Quantity)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Moved to cart", "cart_item": mapCartItem(*cartItem)})
+}
+
+// ---------------- NAMED LISTS ----------------
+
+// GET /wishlists
+func GetWishlists(c *gin.Context) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return
+	}
+
+	lists, err := services.ListWishlists(config.DB, uint(id))
+	if err != nil {
+		respondError(c, services.Internal("Failed to fetch wishlists", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"wishlists": lists})
+}
+
+// POST /wishlists {"name": "..."}
+func CreateWishlist(c *gin.Context) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return
+	}
+
+	var body struct {
+		Name string `json:"name" binding:"required,max=100"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	list, err := services.CreateWishlist(config.DB, uint(id), body.Name)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Wishlist created", "wishlist": list})
+}
+
+// GET /wishlists/:id
+func GetWishlistByID(c *gin.Context) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return
+	}
+	userID := uint(id)
+
+	wishlistID, ok := wishlistFromParam(c, userID)
+	if !ok {
+		return
+	}
+	list, err := services.GetWishlist(config.DB, userID, wishlistID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"wishlist": list})
+}
+
+// PUT /wishlists/:id {"name": "..."}
+func RenameWishlist(c *gin.Context) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return
+	}
+	userID := uint(id)
+
+	var body struct {
+		Name string `json:"name" binding:"required,max=100"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		respondError(c, err)
+		return
+	}
+	wishlistID, ok := wishlistFromParam(c, userID)
+	if !ok {
+		return
+	}
+
+	list, err := services.RenameWishlist(config.DB, userID, wishlistID, body.Name)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Wishlist renamed", "wishlist": list})
+}
+
+// DELETE /wishlists/:id
+func DeleteWishlist(c *gin.Context) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return
+	}
+	userID := uint(id)
+
+	wishlistID, ok := wishlistFromParam(c, userID)
+	if !ok {
+		return
+	}
+	if err := services.DeleteWishlist(config.DB, userID, wishlistID); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Wishlist deleted"})
+}
+
+// ---------------- SHARING ----------------
+
+// POST /wishlists/:id/share - create (or return) the public link
+func ShareWishlist(c *gin.Context) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return
+	}
+	userID := uint(id)
+
+	wishlistID, ok := wishlistFromParam(c, userID)
+	if !ok {
+		return
+	}
+	list, err := services.ShareWishlist(config.DB, userID, wishlistID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Wishlist shared", "wishlist": list, "share_url": list.ShareURL})
+}
+
+// DELETE /wishlists/:id/share - revoke the public link
+func UnshareWishlist(c *gin.Context) {
+	userIDInt, exists := c.Get("userID")
+	id, ok := userIDInt.(int)
+	if !exists || !ok {
+		respondError(c, services.ErrUnauthenticated)
+		return
+	}
+	userID := uint(id)
+
+	wishlistID, ok := wishlistFromParam(c, userID)
+	if !ok {
+		return
+	}
+	if err := services.UnshareWishlist(config.DB, userID, wishlistID); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Share link revoked"})
+}
+
+// GET /shared/wishlists/:token - public, read-only
+func GetSharedWishlist(c *gin.Context) {
+	list, err := services.GetSharedWishlist(config.DB, c.Param("token"))
+	if err != nil {
+		respondError(c, services.ErrWishlistNotFound)
+		return
+	}
+
+	items := []SharedWishlistItem{}
+	for _, item := range list.Items {
+		if item.Product.ID == 0 {
+			continue
+		}
+		items = append(items, SharedWishlistItem{
+			Product: ProductSummary{
+				ID:            item.Product.ID,
+				Name:          item.Product.Name,
+				Description:   item.Product.Description,
+				Price:         item.Product.Price,
+				StockQuantity: item.Product.StockQuantity,
+				ImageURL:      item.Product.ImageURL,
+			},
+			CreatedAt: item.CreatedAt,
+		})
+	}
+
+	c.Header("X-Robots-Tag", "noindex")
+	c.JSON(http.StatusOK, gin.H{"name": list.Name, "items": items})
+}
@@ -0,0 +1,43 @@
+// Package cookies writes the server's cookies with the domain, Secure
+// flag and SameSite mode from the config, so no handler hard-codes them.
+package cookies
+
+import (
+	"net/http"
+
+	"e-commerce/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Set writes an HttpOnly cookie; a negative maxAge deletes it
+func Set(c *gin.Context, name, value string, maxAge int, path string) {
+	write(c, name, value, maxAge, path, true, config.CookieSameSite())
+}
+
+// Clear deletes a cookie written by Set
+func Clear(c *gin.Context, name, path string) {
+	Set(c, name, "", -1, path)
+}
+
+// SetReadable writes a cookie scripts can read, such as the CSRF token
+// that JavaScript clients echo in a header
+func SetReadable(c *gin.Context, name, value string, maxAge int, path string) {
+	write(c, name, value, maxAge, path, false, config.CookieSameSite())
+}
+
+// SetForRedirect is Set for a cookie that must come back on a top-level
+// redirect from another site, like the OAuth state on the provider's
+// callback. Strict would drop it there, so it is relaxed to Lax.
+func SetForRedirect(c *gin.Context, name, value string, maxAge int, path string) {
+	sameSite := config.CookieSameSite()
+	if sameSite == http.SameSiteStrictMode {
+		sameSite = http.SameSiteLaxMode
+	}
+	write(c, name, value, maxAge, path, true, sameSite)
+}
+
+func write(c *gin.Context, name, value string, maxAge int, path string, httpOnly bool, sameSite http.SameSite) {
+	c.SetSameSite(sameSite)
+	c.SetCookie(name, value, maxAge, path, config.CookieDomain(), config.CookieSecure(), httpOnly)
+}
@@ -0,0 +1,188 @@
+// Package httperr renders errors as the API's single JSON error envelope:
+//
+//	{"error": {"code": "...", "message": "...", "fields": [...], "details": ..., "request_id": "..."}}
+//
+// Services return typed *services.Error values; everything else is mapped
+// here so handlers never pick status codes themselves.
+package httperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"e-commerce/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// RequestIDKey is the context key the request ID middleware stores under
+const RequestIDKey = "requestID"
+
+// Body is the content of the "error" member
+type Body struct {
+	Code      string                `json:"code"`
+	Message   string                `json:"message"`
+	Fields    []services.FieldError `json:"fields,omitempty"`
+	Details   interface{}           `json:"details,omitempty"`
+	RequestID string                `json:"request_id,omitempty"`
+}
+
+// Envelope is every error response
+type Envelope struct {
+	Error Body `json:"error"`
+}
+
+var statuses = map[services.Kind]int{
+	services.KindInternal:        http.StatusInternalServerError,
+	services.KindBadRequest:      http.StatusBadRequest,
+	services.KindUnauthenticated: http.StatusUnauthorized,
+	services.KindForbidden:       http.StatusForbidden,
+	services.KindNotFound:        http.StatusNotFound,
+	services.KindConflict:        http.StatusConflict,
+	services.KindValidation:      http.StatusUnprocessableEntity,
+	services.KindUpstream:        http.StatusBadGateway,
+}
+
+// Status is the HTTP status for an error kind
+func Status(kind services.Kind) int {
+	if status, ok := statuses[kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Respond aborts the request and writes err as the error envelope.
+// Internal and upstream failures are logged with the request ID; clients
+// only see their generic message.
+func Respond(c *gin.Context, err error) {
+	e := From(err)
+	requestID := c.GetString(RequestIDKey)
+	if e.Kind == services.KindInternal || e.Kind == services.KindUpstream {
+		log.Printf("❌ [%s] %s %s: %v", requestID, c.Request.Method, c.Request.URL.Path, err)
+	}
+
+	c.AbortWithStatusJSON(Status(e.Kind), Envelope{Error: Body{
+		Code:      e.Code,
+		Message:   e.Message,
+		Fields:    e.Fields,
+		Details:   e.Details,
+		RequestID: requestID,
+	}})
+}
+
+// From classifies any error: typed service errors pass through, binding
+// and decoding failures become validation errors, the rest are internal
+func From(err error) *services.Error {
+	var e *services.Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		fields := make([]services.FieldError, 0, len(verrs))
+		for _, fe := range verrs {
+			fields = append(fields, services.FieldError{Field: fieldName(fe), Message: fieldMessage(fe)})
+		}
+		return services.Invalid("validation_failed", "Invalid request", fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return services.Invalid("validation_failed", "Invalid request", services.FieldError{
+			Field:   typeErr.Field,
+			Message: "must be a " + typeErr.Type.String(),
+		})
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return services.BadRequest("invalid_body", "Request body must be valid JSON")
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return services.ErrNotFound
+	}
+	return services.Internal("Something went wrong", err)
+}
+
+// ---------- validation messages ----------
+
+func init() {
+	// report fields by their JSON (or form) names rather than Go names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				name := strings.SplitN(f.Tag.Get(tag), ",", 2)[0]
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return f.Name
+		})
+	}
+}
+
+// fieldName drops the top-level struct name from the namespace so nested
+// fields read like "items[0].quantity"
+func fieldName(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		if unit := lengthUnit(fe.Kind()); unit != "" {
+			return fmt.Sprintf("must have at least %s %s", fe.Param(), unit)
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if unit := lengthUnit(fe.Kind()); unit != "" {
+			return fmt.Sprintf("must have at most %s %s", fe.Param(), unit)
+		}
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "lte":
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "len":
+		return "must have length " + fe.Param()
+	}
+	return "is invalid"
+}
+
+func lengthUnit(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "items"
+	}
+	return ""
+}
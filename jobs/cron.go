@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// schedule enqueues jobType whenever the cron spec matches
+type schedule struct {
+	name    string
+	spec    *cronSpec
+	jobType string
+}
+
+var (
+	schedulesMu sync.Mutex
+	schedules   []schedule
+)
+
+// Schedule registers a recurring job using a standard 5-field cron spec
+// ("minute hour day-of-month month day-of-week"), evaluated in local time.
+// Every instance runs the scheduler; a per-minute unique key makes sure
+// only one job is enqueued per tick.
+func Schedule(name, spec, jobType string) error {
+	parsed, err := parseCron(spec)
+	if err != nil {
+		return fmt.Errorf("schedule %s: %w", name, err)
+	}
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+	schedules = append(schedules, schedule{name: name, spec: parsed, jobType: jobType})
+	return nil
+}
+
+func runScheduler(db *gorm.DB) {
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(next.Sub(now))
+
+		releaseStale(db)
+
+		schedulesMu.Lock()
+		due := make([]schedule, 0, len(schedules))
+		for _, s := range schedules {
+			if s.spec.matches(next) {
+				due = append(due, s)
+			}
+		}
+		schedulesMu.Unlock()
+
+		for _, s := range due {
+			key := "cron:" + s.name + ":" + next.Format("200601021504")
+			if err := Enqueue(db, s.jobType, map[string]string{"schedule": s.name}, UniqueKey(key), MaxAttempts(3)); err != nil {
+				log.Printf("❌ cron %s enqueue failed: %v", s.name, err)
+			}
+		}
+	}
+}
+
+// ---------- cron parsing ----------
+
+type cronSpec struct {
+	minute, hour, dom, month, dow map[int]bool
+	domAny, dowAny                bool
+}
+
+func parseCron(spec string) (*cronSpec, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	var err error
+	c := &cronSpec{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	if c.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if c.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if c.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if c.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if c.dow[7] {
+		c.dow[0] = true
+	}
+	return c, nil
+}
+
+// parseField supports "*", "n", "a-b", "a,b,c" and "/step" on any of them
+func parseField(field string, min, max int) (map[int]bool, error) {
+	values := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			step = s
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			a, err1 := strconv.Atoi(bounds[0])
+			b, err2 := strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+			lo, hi = a, b
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			lo, hi = n, n
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+func (c *cronSpec) matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+	domMatch := c.dom[t.Day()]
+	dowMatch := c.dow[int(t.Weekday())]
+	// standard cron: when both day fields are restricted, either may match
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowMatch
+	case c.dowAny:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
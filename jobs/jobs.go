@@ -0,0 +1,163 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"e-commerce/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Job statuses
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusDead      = "dead"
+)
+
+// Handler processes one job payload. Returning an error schedules a retry
+// until MaxAttempts is reached, after which the job is dead-lettered.
+type Handler func(db *gorm.DB, payload []byte) error
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]Handler{}
+)
+
+// Register binds a handler to a job type
+func Register(jobType string, h Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[jobType] = h
+}
+
+func handlerFor(jobType string) (Handler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	h, ok := handlers[jobType]
+	return h, ok
+}
+
+// ---------- Enqueue ----------
+
+type Option func(*models.Job)
+
+// Delay postpones the first run
+func Delay(d time.Duration) Option {
+	return func(j *models.Job) { j.RunAt = time.Now().Add(d) }
+}
+
+// At schedules the first run at a fixed time
+func At(t time.Time) Option {
+	return func(j *models.Job) { j.RunAt = t }
+}
+
+func MaxAttempts(n int) Option {
+	return func(j *models.Job) { j.MaxAttempts = n }
+}
+
+// UniqueKey makes Enqueue a no-op when a job with the same key exists
+func UniqueKey(key string) Option {
+	return func(j *models.Job) { j.UniqueKey = &key }
+}
+
+// Enqueue writes a job using tx, so it commits or rolls back together with
+// the caller's business change. Pass config.DB when there is no transaction.
+func Enqueue(tx *gorm.DB, jobType string, payload interface{}, opts ...Option) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s payload: %w", jobType, err)
+	}
+
+	job := models.Job{
+		Type:        jobType,
+		Payload:     string(raw),
+		Status:      StatusPending,
+		MaxAttempts: 5,
+		RunAt:       time.Now(),
+	}
+	for _, opt := range opts {
+		opt(&job)
+	}
+
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&job).Error
+}
+
+// ---------- Admin helpers ----------
+
+// List returns jobs filtered by status ("" for all), newest first
+func List(db *gorm.DB, status string, limit int) ([]models.Job, error) {
+	var list []models.Job
+	query := db.Order("updated_at DESC").Limit(limit)
+	if status != "" && status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&list).Error
+	return list, err
+}
+
+var (
+	ErrNotRetryable = errors.New("job not found or not retryable")
+	ErrNotDead      = errors.New("dead job not found")
+)
+
+// Retry moves a dead (or completed) job back to the queue with a fresh attempt budget
+func Retry(db *gorm.DB, id uint) error {
+	result := db.Model(&models.Job{}).
+		Where("id = ? AND status IN ?", id, []string{StatusDead, StatusCompleted}).
+		Updates(map[string]interface{}{
+			"status":    StatusPending,
+			"attempts":  0,
+			"run_at":    time.Now(),
+			"locked_at": nil,
+			"locked_by": "",
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotRetryable
+	}
+	return nil
+}
+
+// Discard deletes a dead job
+func Discard(db *gorm.DB, id uint) error {
+	result := db.Where("id = ? AND status = ?", id, StatusDead).Delete(&models.Job{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotDead
+	}
+	return nil
+}
+
+// PurgeCompleted deletes jobs that completed before the cutoff and
+// returns how many were removed
+func PurgeCompleted(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("status = ? AND completed_at < ?", StatusCompleted, before).Delete(&models.Job{})
+	return result.RowsAffected, result.Error
+}
+
+// Counts returns the number of jobs per status
+func Counts(db *gorm.DB) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := db.Model(&models.Job{}).Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{StatusPending: 0, StatusRunning: 0, StatusCompleted: 0, StatusDead: 0}
+	for _, r := range rows {
+		counts[r.Status] = r.Count
+	}
+	return counts, nil
+}
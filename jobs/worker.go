@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"e-commerce/models"
+
+	"gorm.io/gorm"
+)
+
+// stale running jobs (crashed worker) are handed back to the queue after this
+const lockTimeout = 10 * time.Minute
+
+// Start launches the worker pool and the cron scheduler
+func Start(db *gorm.DB, workers int, pollInterval time.Duration) {
+	if workers < 1 {
+		workers = 1
+	}
+	host, _ := os.Hostname()
+	for i := 0; i < workers; i++ {
+		w := &worker{db: db, id: fmt.Sprintf("%s-%d-%d", host, os.Getpid(), i), poll: pollInterval}
+		go w.loop()
+	}
+	go runScheduler(db)
+}
+
+type worker struct {
+	db   *gorm.DB
+	id   string
+	poll time.Duration
+}
+
+func (w *worker) loop() {
+	for {
+		for w.runNext() {
+		}
+		time.Sleep(w.poll)
+	}
+}
+
+// runNext claims and executes one due job; it reports whether a job was found
+func (w *worker) runNext() bool {
+	var job models.Job
+	err := w.db.Raw(`
+		UPDATE jobs SET status = ?, locked_at = NOW(), locked_by = ?, attempts = attempts + 1, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = ? AND run_at <= NOW()
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, StatusRunning, w.id, StatusPending).Scan(&job).Error
+	if err != nil {
+		log.Println("❌ job claim failed:", err)
+		return false
+	}
+	if job.ID == 0 {
+		return false
+	}
+
+	if err := execute(w.db, job); err != nil {
+		w.fail(job, err)
+		return true
+	}
+
+	now := time.Now()
+	w.db.Model(&job).Updates(map[string]interface{}{
+		"status":       StatusCompleted,
+		"completed_at": &now,
+		"locked_at":    nil,
+		"last_error":   "",
+	})
+	return true
+}
+
+func execute(db *gorm.DB, job models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	h, ok := handlerFor(job.Type)
+	if !ok {
+		return fmt.Errorf("no handler registered for %q", job.Type)
+	}
+	return h(db, []byte(job.Payload))
+}
+
+func (w *worker) fail(job models.Job, jobErr error) {
+	updates := map[string]interface{}{
+		"locked_at":  nil,
+		"last_error": jobErr.Error(),
+	}
+	if job.Attempts >= job.MaxAttempts {
+		updates["status"] = StatusDead
+		log.Printf("☠️ job %d (%s) moved to dead-letter after %d attempts: %v", job.ID, job.Type, job.Attempts, jobErr)
+	} else {
+		updates["status"] = StatusPending
+		updates["run_at"] = time.Now().Add(backoff(job.Attempts))
+		log.Printf("⚠️ job %d (%s) failed (attempt %d/%d): %v", job.ID, job.Type, job.Attempts, job.MaxAttempts, jobErr)
+	}
+	w.db.Model(&job).Updates(updates)
+}
+
+// backoff grows exponentially from 10s and is capped at one hour
+func backoff(attempts int) time.Duration {
+	d := 10 * time.Second
+	for i := 1; i < attempts && d < time.Hour; i++ {
+		d *= 2
+	}
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
+
+// releaseStale returns jobs locked by a worker that died mid-run
+func releaseStale(db *gorm.DB) {
+	db.Model(&models.Job{}).
+		Where("status = ? AND locked_at < ?", StatusRunning, time.Now().Add(-lockTimeout)).
+		Updates(map[string]interface{}{"status": StatusPending, "locked_at": nil})
+}
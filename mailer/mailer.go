@@ -0,0 +1,103 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// Message is a single outgoing email with optional HTML alternative
+type Message struct {
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// Mailer delivers a message synchronously
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var (
+	defaultMailer Mailer
+	initOnce      sync.Once
+)
+
+// Init configures the default mailer from env. Delivery and its retries run
+// in the job queue, which calls Default().Send.
+//
+//	MAIL_DRIVER      smtp | file | log (default: smtp when SMTP creds are set, else log)
+//	MAIL_FROM        sender address (default: SMTP_EMAIL)
+//	MAIL_OUTBOX_DIR  directory for the file driver (default: outbox)
+func Init() {
+	initOnce.Do(func() {
+		defaultMailer = fromEnv()
+	})
+}
+
+// SetDefault replaces the default mailer (used to plug in the Recorder)
+func SetDefault(m Mailer) {
+	Init()
+	defaultMailer = m
+}
+
+// Default returns the configured mailer
+func Default() Mailer {
+	Init()
+	return defaultMailer
+}
+
+func fromEnv() Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = os.Getenv("SMTP_EMAIL")
+	}
+
+	driver := os.Getenv("MAIL_DRIVER")
+	if driver == "" {
+		if os.Getenv("SMTP_EMAIL") != "" && os.Getenv("SMTP_PASSWORD") != "" {
+			driver = "smtp"
+		} else {
+			driver = "log"
+		}
+	}
+
+	switch driver {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     envString("SMTP_HOST", "smtp.gmail.com"),
+			Port:     envString("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_EMAIL"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+			TLSMode:  envString("SMTP_TLS", TLSStartTLS),
+		}
+	case "file":
+		return &FileMailer{Dir: envString("MAIL_OUTBOX_DIR", "outbox"), From: from}
+	case "log":
+		return LogMailer{}
+	default:
+		log.Printf("unknown MAIL_DRIVER %q, falling back to log", driver)
+		return LogMailer{}
+	}
+}
+
+// ---------- LogMailer ----------
+
+// LogMailer prints messages to stdout; used when SMTP is not configured
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Message) error {
+	fmt.Printf("SMTP not configured - email to %s: %s\n%s\n", msg.To, msg.Subject, msg.TextBody)
+	return nil
+}
+
+// ---------- helpers ----------
+func envString(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
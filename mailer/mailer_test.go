@@ -0,0 +1,89 @@
+package mailer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderEveryTemplate(t *testing.T) {
+	for name, newData := range dataTypes {
+		raw, err := json.Marshal(newData())
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := RenderJSON(name, "ann@example.com", raw)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if msg.To != "ann@example.com" || msg.Subject == "" || msg.TextBody == "" || msg.HTMLBody == "" {
+			t.Errorf("%s: incomplete message %+v", name, msg)
+		}
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	msg, err := Render(TemplateOTP, "ann@example.com", OTPData{Name: "<b>Ann</b>", Code: "123456", ExpiresIn: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(msg.HTMLBody, "<b>Ann</b>") {
+		t.Error("HTML body does not escape the name")
+	}
+	if !strings.Contains(msg.TextBody, "123456") || !strings.Contains(msg.HTMLBody, "123456") {
+		t.Error("code missing from the message")
+	}
+}
+
+func TestRenderJSONUnknownTemplate(t *testing.T) {
+	if _, err := RenderJSON("nope", "ann@example.com", []byte("{}")); err == nil {
+		t.Error("expected an error for an unknown template")
+	}
+}
+
+func TestSendRecorded(t *testing.T) {
+	rec := &Recorder{}
+	SetDefault(rec)
+	defer SetDefault(LogMailer{})
+
+	msg, err := Render(TemplateOTP, "bob@example.com", OTPData{Name: "Bob", Code: "654321", ExpiresIn: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Default().Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	msgs := rec.SentTo("bob@example.com")
+	if len(msgs) != 1 || msgs[0].Subject != "Your OTP Code" || !strings.Contains(msgs[0].TextBody, "654321") {
+		t.Errorf("unexpected messages %+v", msgs)
+	}
+
+	rec.Reset()
+	if len(rec.Messages()) != 0 {
+		t.Error("Reset kept messages")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := &FileMailer{Dir: dir, From: "shop@example.com"}
+	if err := m.Send(Message{To: "eve@example.com", Subject: "Hello", TextBody: "text", HTMLBody: "<p>html</p>"}); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*_eve@example.com.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("files = %v, %v", files, err)
+	}
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"To: eve@example.com", "Subject: Hello", "text", "<p>html</p>"} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("eml missing %q", want)
+		}
+	}
+}
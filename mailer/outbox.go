@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ---------- FileMailer ----------
+
+// FileMailer writes every message as an .eml file into Dir, so local
+// development can inspect mail without an SMTP server.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@._-]+`)
+
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	return os.WriteFile(filepath.Join(m.Dir, name), buildMIME(m.From, msg), 0o644)
+}
+
+// ---------- Recorder ----------
+
+// Recorder keeps sent messages in memory; plug it in with SetDefault
+// to assert on outgoing mail.
+type Recorder struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (r *Recorder) Send(msg Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of everything sent so far
+func (r *Recorder) Messages() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Message, len(r.messages))
+	copy(out, r.messages)
+	return out
+}
+
+// SentTo returns the messages addressed to the given recipient
+func (r *Recorder) SentTo(to string) []Message {
+	var out []Message
+	for _, msg := range r.Messages() {
+		if msg.To == to {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = nil
+}
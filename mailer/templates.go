@@ -0,0 +1,147 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"e-commerce/money"
+)
+
+// Template names
+const (
+	TemplateOTP               = "otp"
+	TemplatePasswordReset     = "password_reset"
+	TemplateOrderConfirmation = "order_confirmation"
+	TemplateOrderShipped      = "order_shipped"
+	TemplateRefund            = "refund"
+	TemplatePriceDrop         = "price_drop"
+	TemplateBackInStock       = "back_in_stock"
+	TemplateAbandonedCart     = "abandoned_cart"
+	TemplateAccountDeletion   = "account_deletion"
+)
+
+// ---------- template data ----------
+
+type OTPData struct {
+	Name          string
+	Code          string
+	ExpiresIn     int // minutes
+	Link          string
+	LinkExpiresIn int // minutes
+}
+
+type OrderLine struct {
+	Name     string
+	Quantity int
+	Price    money.Money
+}
+
+// OrderData feeds the order confirmation and shipping templates
+type OrderData struct {
+	Name           string
+	OrderID        uint
+	Items          []OrderLine
+	Total          money.Money
+	Address        string
+	Carrier        string
+	TrackingNumber string
+}
+
+type RefundData struct {
+	Name    string
+	OrderID uint
+	Amount  money.Money
+	Reason  string
+}
+
+// ProductAlertData feeds the price drop and back-in-stock alerts
+type ProductAlertData struct {
+	Name        string
+	ProductName string
+	ListName    string
+	OldPrice    money.Money
+	NewPrice    money.Money
+	Link        string
+}
+
+// CartReminderData feeds the abandoned cart reminder
+type CartReminderData struct {
+	Name  string
+	Items []OrderLine
+	Total money.Money
+	Link  string
+}
+
+// AccountDeletionData feeds the notice that an account will be erased
+type AccountDeletionData struct {
+	Name     string
+	DeleteOn string // date the data is erased, e.g. "2 January 2027"
+}
+
+// dataTypes maps each template to its data struct so queued emails can be
+// decoded back into typed values
+var dataTypes = map[string]func() interface{}{
+	TemplateOTP:               func() interface{} { return &OTPData{} },
+	TemplatePasswordReset:     func() interface{} { return &OTPData{} },
+	TemplateOrderConfirmation: func() interface{} { return &OrderData{} },
+	TemplateOrderShipped:      func() interface{} { return &OrderData{} },
+	TemplateRefund:            func() interface{} { return &RefundData{} },
+	TemplatePriceDrop:         func() interface{} { return &ProductAlertData{} },
+	TemplateBackInStock:       func() interface{} { return &ProductAlertData{} },
+	TemplateAbandonedCart:     func() interface{} { return &CartReminderData{} },
+	TemplateAccountDeletion:   func() interface{} { return &AccountDeletionData{} },
+}
+
+// RenderJSON renders a template from JSON-encoded data
+func RenderJSON(name, to string, raw []byte) (Message, error) {
+	newData, ok := dataTypes[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template %q", name)
+	}
+	data := newData()
+	if err := json.Unmarshal(raw, data); err != nil {
+		return Message{}, fmt.Errorf("email template %q: %w", name, err)
+	}
+	return Render(name, to, data)
+}
+
+// Every email has a <name>.txt and <name>.html file. The text template
+// must also define a "subject" block.
+//
+//go:embed templates/*.txt templates/*.html
+var templateFS embed.FS
+
+// Render builds a message from the named template pair
+func Render(name, to string, data interface{}) (Message, error) {
+	textTmpl, err := texttemplate.ParseFS(templateFS, "templates/"+name+".txt")
+	if err != nil {
+		return Message{}, fmt.Errorf("email template %q: %w", name, err)
+	}
+	htmlTmpl, err := htmltemplate.ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html")
+	if err != nil {
+		return Message{}, fmt.Errorf("email template %q: %w", name, err)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTmpl.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:       to,
+		Subject:  strings.TrimSpace(subject.String()),
+		TextBody: strings.TrimSpace(text.String()),
+		HTMLBody: html.String(),
+	}, nil
+}
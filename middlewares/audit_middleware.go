@@ -0,0 +1,159 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"e-commerce/config"
+	"e-commerce/httperr"
+	"e-commerce/models"
+	"e-commerce/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditRoute names what an admin route does and which entity it changes
+type auditRoute struct {
+	action string
+	entity string // services entity type, "" when nothing is diffed
+	param  string // route param holding the entity ID, "" for creates
+	// created is the response member holding a created entity, or
+	// "location" when a form redirects to the new entity's page
+	created string
+	self    bool // the entity is the acting admin
+	erases  bool // the entity's personal data is erased, so no diff is kept
+}
+
+// auditRoutes is keyed by method and route, without the API prefix
+var auditRoutes = map[string]auditRoute{
+	"PATCH /admin/users/:id":        {action: "user.update", entity: "user", param: "id"},
+	"PUT /admin/users/:id":          {action: "user.update", entity: "user", param: "id"},
+	"DELETE /admin/users/:id":       {action: "user.delete", entity: "user", param: "id", erases: true},
+	"POST /admin/users/:id/block":   {action: "user.block", entity: "user", param: "id"},
+	"POST /admin/users/:id/unblock": {action: "user.unblock", entity: "user", param: "id"},
+
+	"POST /admin/products":                      {action: "product.create", entity: "product", created: "product"},
+	"PUT /admin/products/:id":                   {action: "product.update", entity: "product", param: "id"},
+	"DELETE /admin/products/:id":                {action: "product.delete", entity: "product", param: "id"},
+	"POST /admin/products/:id/production":       {action: "production.start", entity: "production", created: "data"},
+	"PUT /admin/products/:id/production/status": {action: "production.update_status", entity: "production", param: "id"}, // :id is the run
+	"PUT /admin/orders/:id":                     {action: "order.update_status", entity: "order", param: "id"},
+	"PUT /admin/payments/:payment_id/update":    {action: "payment.update_status", entity: "payment", param: "payment_id"},
+	"POST /admin/jobs/:id/retry":                {action: "job.retry", entity: "job", param: "id"},
+	"DELETE /admin/jobs/:id":                    {action: "job.discard", entity: "job", param: "id"},
+	"PUT /view/orders/:id/status":               {action: "order.update_status", entity: "order", param: "id"},
+	"POST /view/orders/:id/shipments":           {action: "order.ship", entity: "order", param: "id"},
+	"POST /view/orders/:id/refunds":             {action: "order.refund", entity: "order", param: "id"},
+	"POST /view/orders/:id/notes":               {action: "order.add_note", entity: "order", param: "id"},
+	"DELETE /view/orders/:id/notes/:note_id":    {action: "order_note.delete", entity: "order_note", param: "note_id"},
+	"POST /view/production":                     {action: "production.start", entity: "production", created: "location"},
+	"PUT /view/production/:id/status":           {action: "production.update_status", entity: "production", param: "id"},
+	"POST /view/profile/update":                 {action: "user.update_profile", entity: "user", self: true},
+}
+
+const auditReasonKey = "auditReason"
+
+var locationID = regexp.MustCompile(`/(\d+)(?:[/?]|$)`)
+
+// ---------------- AuditMiddleware
+// Records every mutating admin request in the audit log: the actor, the
+// action, the entity it targets with the fields it changed, the client IP
+// and the request ID. Failed requests are logged too, without changes.
+// Register it after the auth and idempotency middleware so replays are not
+// logged twice. Routes missing from auditRoutes are logged as "METHOD route".
+func AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !mutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		routePath := strings.TrimPrefix(c.FullPath(), config.APIPrefix)
+		route, known := auditRoutes[c.Request.Method+" "+routePath]
+		if !known {
+			route.action = c.Request.Method + " " + routePath
+		}
+
+		entry := models.AuditLog{
+			Action:     route.action,
+			EntityType: route.entity,
+			IP:         c.ClientIP(),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			RequestID:  c.GetString(httperr.RequestIDKey),
+		}
+		if id, ok := c.Get("userID"); ok {
+			if n, ok := id.(int); ok {
+				actor := uint(n)
+				entry.ActorID = &actor
+			}
+		}
+		switch {
+		case route.self && entry.ActorID != nil:
+			entry.EntityID = fmt.Sprint(*entry.ActorID)
+		case route.param != "":
+			entry.EntityID = c.Param(route.param)
+		}
+
+		before, err := services.AuditSnapshot(config.DB, entry.EntityType, entry.EntityID)
+		if err != nil {
+			log.Println("❌ audit snapshot failed:", err)
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		entry.Status = w.Status()
+		entry.Succeeded = entry.Status < http.StatusBadRequest && len(c.Errors) == 0
+		if entry.Succeeded && !route.erases {
+			if entry.EntityID == "" && route.created != "" {
+				entry.EntityID = createdID(w, route.created)
+				before = nil
+			}
+			after, err := services.AuditSnapshot(config.DB, entry.EntityType, entry.EntityID)
+			if err != nil {
+				log.Println("❌ audit snapshot failed:", err)
+			}
+			entry.Changes = services.AuditDiff(before, after)
+		}
+		entry.Reason = strings.TrimSpace(c.GetString(auditReasonKey))
+		if len(c.Errors) > 0 {
+			// forms redirect on failure too and report the error on c.Errors
+			entry.Error = c.Errors.Last().Error()
+		}
+
+		if err := services.RecordAudit(config.DB, &entry); err != nil {
+			log.Println("❌ audit entry not recorded:", err)
+		}
+	}
+}
+
+// SetAuditReason records why the admin made the change, for handlers
+// whose requests carry a reason
+func SetAuditReason(c *gin.Context, reason string) {
+	c.Set(auditReasonKey, reason)
+}
+
+// createdID finds the ID of an entity the request created: in the response
+// member named by created, or in the redirect Location for forms
+func createdID(w *recordingWriter, created string) string {
+	if created == "location" {
+		if m := locationID.FindStringSubmatch(w.Header().Get("Location")); m != nil {
+			return m[1]
+		}
+		return ""
+	}
+	var body map[string]json.RawMessage
+	var entity struct {
+		ID json.Number `json:"id"`
+	}
+	if json.Unmarshal(w.body.Bytes(), &body) != nil || json.Unmarshal(body[created], &entity) != nil {
+		return ""
+	}
+	return entity.ID.String()
+}
@@ -1,69 +1,75 @@
 package middlewares
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"e-commerce/config"
+	"e-commerce/cookies"
+	"e-commerce/httperr"
+	"e-commerce/services"
 	"e-commerce/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+var errUsersOnly = services.Forbidden("users_only", "Access denied: Users only")
+
+// authenticate reads the access token from the Authorization header or the
+// access_token cookie. An expired token is renewed while the user's
+// refresh token is still valid.
+func authenticate(c *gin.Context) (int, string, error) {
+	var accessToken string
+	authHeader := c.GetHeader("Authorization")
+
+	if authHeader != "" {
+		accessToken = strings.TrimPrefix(authHeader, "Bearer ")
+	} else {
+		cookieToken, err := c.Cookie("access_token")
+		if err != nil || cookieToken == "" {
+			return 0, "", services.ErrUnauthenticated
+		}
+		accessToken = cookieToken
+	}
+
+	userID, role, err := utils.ValidateJWT(accessToken)
+	if err != nil {
+		if userID == 0 {
+			return 0, "", services.ErrSessionExpired
+		}
+		rt, err := utils.GetRefreshTokenByUserID(config.DB, uint(userID))
+		if err != nil {
+			return 0, "", services.ErrSessionExpired
+		}
+
+		_, err = utils.ValidateRefreshToken(config.DB, rt.Token)
+		if err != nil {
+			return 0, "", services.ErrSessionExpired
+		}
+
+		newAccessToken, err := utils.GenerateJWT(userID, role)
+		if err != nil {
+			return 0, "", services.ErrSessionExpired
+		}
+
+		cookies.Set(c, "access_token", newAccessToken, 30*60, "/")
+	}
+	return userID, role, nil
+}
+
 // ---------------- AdminAuthMiddleware
 func AdminAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var accessToken string
-		authHeader := c.GetHeader("Authorization")
-
-		if authHeader != "" {
-			accessToken = strings.TrimPrefix(authHeader, "Bearer ")
-		} else {
-			cookieToken, err := c.Cookie("access_token")
-			if err != nil || cookieToken == "" {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing access token"})
-				c.Abort()
-				return
-			}
-			accessToken = cookieToken
-		}
-
-		userID, role, err := utils.ValidateJWT(accessToken)
+		userID, role, err := authenticate(c)
 		if err != nil {
-			if userID != 0 {
-				rt, err := utils.GetRefreshTokenByUserID(config.DB, uint(userID))
-				if err != nil {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
-					c.Abort()
-					return
-				}
-
-				_, err = utils.ValidateRefreshToken(config.DB, rt.Token)
-				if err != nil {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
-					c.Abort()
-					return
-				}
-
-				newAccessToken, err := utils.GenerateJWT(userID, role)
-				if err != nil {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
-					c.Abort()
-					return
-				}
-
-				c.SetCookie("access_token", newAccessToken, 30*60, "/", "localhost", false, true)
-				accessToken = newAccessToken
-			} else {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
-				c.Abort()
-				return
-			}
+			httperr.Respond(c, err)
+			return
 		}
 
 		if role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Admins only"})
-			c.Abort()
+			httperr.Respond(c, services.ErrAdminsOnly)
 			return
 		}
 
@@ -73,61 +79,49 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
-
-//---------------- UserAuthMiddleware
-func UserAuthMiddleware() gin.HandlerFunc {
+// ---------------- AdminViewAuthMiddleware
+// AdminAuthMiddleware for the HTML panel: browsers without an admin session
+// are sent to /login, which brings them back to the page after signing in.
+func AdminViewAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var accessToken string
-		authHeader := c.GetHeader("Authorization")
-
-		if authHeader != "" {
-			accessToken = strings.TrimPrefix(authHeader, "Bearer ")
-		} else {
-			cookieToken, err := c.Cookie("access_token")
-			if err != nil || cookieToken == "" {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing access token"})
-				c.Abort()
-				return
+		userID, role, err := authenticate(c)
+		if err == nil && role != "admin" {
+			err = services.ErrAdminsOnly
+		}
+		if err != nil {
+			q := url.Values{}
+			// a form post cannot be replayed after login, so only pages come back
+			if c.Request.Method == http.MethodGet {
+				q.Set("return_to", c.Request.URL.RequestURI())
+			}
+			switch {
+			case errors.Is(err, services.ErrSessionExpired):
+				q.Set("reason", "expired")
+			case errors.Is(err, services.ErrAdminsOnly):
+				q.Set("reason", "admins_only")
 			}
-			accessToken = cookieToken
+			c.Redirect(http.StatusSeeOther, "/login?"+q.Encode())
+			c.Abort()
+			return
 		}
 
-		userID, role, err := utils.ValidateJWT(accessToken)
+		c.Set("userID", userID)
+		c.Set("role", role)
+		c.Next()
+	}
+}
+
+//---------------- UserAuthMiddleware
+func UserAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, role, err := authenticate(c)
 		if err != nil {
-			if userID != 0 {
-				rt, err := utils.GetRefreshTokenByUserID(config.DB, uint(userID))
-				if err != nil {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
-					c.Abort()
-					return
-				}
-
-				_, err = utils.ValidateRefreshToken(config.DB, rt.Token)
-				if err != nil {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
-					c.Abort()
-					return
-				}
-
-				newAccessToken, err := utils.GenerateJWT(userID, role)
-				if err != nil {
-					c.JSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
-					c.Abort()
-					return
-				}
-
-				c.SetCookie("access_token", newAccessToken, 30*60, "/", "localhost", false, true)
-				accessToken = newAccessToken
-			} else {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Please login again"})
-				c.Abort()
-				return
-			}
+			httperr.Respond(c, err)
+			return
 		}
 
 		if role != "user" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied: Users only"})
-			c.Abort()
+			httperr.Respond(c, errUsersOnly)
 			return
 		}
 
@@ -135,4 +129,24 @@ func UserAuthMiddleware() gin.HandlerFunc {
 		c.Set("role", role)
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+//---------------- OptionalUserAuthMiddleware
+// Sets userID and role when a valid user access token is present and lets
+// anonymous requests through, for endpoints open to guests.
+func OptionalUserAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accessToken := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if accessToken == "" {
+			accessToken, _ = c.Cookie("access_token")
+		}
+
+		if accessToken != "" {
+			if userID, role, err := utils.ValidateJWT(accessToken); err == nil && role == "user" {
+				c.Set("userID", userID)
+				c.Set("role", role)
+			}
+		}
+		c.Next()
+	}
+}
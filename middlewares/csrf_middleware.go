@@ -0,0 +1,92 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"regexp"
+
+	"e-commerce/cookies"
+	"e-commerce/httperr"
+	"e-commerce/services"
+	"e-commerce/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CSRFCookie holds the token; it is readable so scripts can echo it
+	CSRFCookie = "csrf_token"
+	// CSRFHeader carries the token on script requests
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFField carries the token in HTML forms
+	CSRFField = "_csrf"
+
+	csrfTokenKey = "csrfToken"
+)
+
+var (
+	errCSRFInvalid     = services.Forbidden("csrf_invalid", "Missing or invalid CSRF token")
+	validCSRFToken     = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	csrfSessionCookies = []string{"access_token", "guest_cart"}
+)
+
+// ---------------- CSRFMiddleware
+// Double-submit protection: every client gets a random token cookie, and a
+// state-changing request that a cookie authenticates must repeat the token
+// in the X-CSRF-Token header or the _csrf form field. Another site can make
+// the browser send the cookie but cannot read it to repeat it. Requests with
+// an Authorization header, or without session cookies, are not checked.
+func CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, _ := c.Cookie(CSRFCookie)
+		if !validCSRFToken.MatchString(token) {
+			token, _ = utils.RandomToken(32)
+			cookies.SetReadable(c, CSRFCookie, token, 0, "/")
+		}
+		c.Set(csrfTokenKey, token)
+
+		if safeMethod(c.Request.Method) || c.GetHeader("Authorization") != "" || !hasSessionCookie(c) {
+			c.Next()
+			return
+		}
+
+		if !CSRFValid(c) {
+			httperr.Respond(c, errCSRFInvalid)
+			return
+		}
+		c.Next()
+	}
+}
+
+// CSRFToken is the request's token for pages to embed
+func CSRFToken(c *gin.Context) string {
+	return c.GetString(csrfTokenKey)
+}
+
+// CSRFValid reports whether the request repeats its token. Handlers call it
+// for forms that are posted before a session exists, like the admin login.
+func CSRFValid(c *gin.Context) bool {
+	token := CSRFToken(c)
+	sent := c.GetHeader(CSRFHeader)
+	if sent == "" {
+		sent = c.PostForm(CSRFField)
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(sent), []byte(token)) == 1
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func hasSessionCookie(c *gin.Context) bool {
+	for _, name := range csrfSessionCookies {
+		if v, err := c.Cookie(name); err == nil && v != "" {
+			return true
+		}
+	}
+	return false
+}
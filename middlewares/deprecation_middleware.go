@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ---------------- DeprecationMiddleware
+// Marks responses from deprecated route aliases with Deprecation (RFC 9745)
+// and Sunset (RFC 8594) headers and links the same request under successor,
+// e.g. "/api/v1".
+func DeprecationMiddleware(successor string, deprecated, sunset time.Time) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(deprecated.Unix(), 10)
+	sunsetAt := sunset.UTC().Format(http.TimeFormat)
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecation)
+		c.Header("Sunset", sunsetAt)
+		c.Header("Link", "<"+successor+c.Request.URL.RequestURI()+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
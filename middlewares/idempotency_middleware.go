@@ -0,0 +1,147 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"e-commerce/config"
+	"e-commerce/httperr"
+	"e-commerce/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyHeader is the client-chosen key of a mutating request
+	IdempotencyHeader = "Idempotency-Key"
+	// ReplayedHeader marks a response replayed from an earlier request
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// recordingWriter keeps a copy of the response body for replays
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// ---------------- IdempotencyMiddleware
+// Mutating requests sent with an Idempotency-Key run once: a retry with the
+// same key and body gets the stored response, a different body gets 422.
+// Server errors are not stored so the request can be retried. Register it
+// after the auth middleware so keys are scoped to the caller.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" || !mutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey(key) {
+			httperr.Respond(c, services.ErrIdempotencyKeyInvalid)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			httperr.Respond(c, services.BadRequest("invalid_body", "Request body could not be read"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		rec, replay, err := services.BeginIdempotentRequest(config.DB, idempotencyScope(c), key, idempotencyFingerprint(c, body))
+		if err != nil {
+			httperr.Respond(c, err)
+			return
+		}
+		if replay {
+			c.Header(ReplayedHeader, "true")
+			c.Data(rec.StatusCode, rec.ContentType, []byte(rec.ResponseBody))
+			c.Abort()
+			return
+		}
+
+		// a panicking handler must not leave the key claimed forever
+		defer func() {
+			if r := recover(); r != nil {
+				if err := services.ReleaseIdempotentRequest(config.DB, rec); err != nil {
+					log.Println("❌ idempotency key release failed:", err)
+				}
+				panic(r)
+			}
+		}()
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		if w.Status() >= http.StatusInternalServerError {
+			err = services.ReleaseIdempotentRequest(config.DB, rec)
+		} else {
+			err = services.CompleteIdempotentRequest(config.DB, rec, w.Status(), w.Header().Get("Content-Type"), w.body.String())
+		}
+		if err != nil {
+			log.Println("❌ idempotency key update failed:", err)
+		}
+	}
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > 255 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// idempotencyFingerprint identifies a request whichever API prefix it came
+// in on: the method, the route without the prefix, its path parameters and
+// the body
+func idempotencyFingerprint(c *gin.Context, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", c.Request.Method, strings.TrimPrefix(c.FullPath(), config.APIPrefix))
+	for _, p := range c.Params {
+		fmt.Fprintf(h, "%s=%s\n", p.Key, p.Value)
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyScope keeps one caller's keys apart from another's: the user
+// when signed in, else the guest cart cookie, else the client address
+func idempotencyScope(c *gin.Context) string {
+	if userID, ok := c.Get("userID"); ok {
+		return fmt.Sprintf("user:%v", userID)
+	}
+	if cart, err := c.Cookie("guest_cart"); err == nil && cart != "" {
+		sum := sha256.Sum256([]byte(cart))
+		return "guest:" + hex.EncodeToString(sum[:16])
+	}
+	return "anon:" + c.ClientIP()
+}
@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"e-commerce/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fingerprints routes the same handler under the API prefix and the
+// unprefixed alias, as main does, and returns each request's fingerprint
+func fingerprints(t *testing.T, requests ...[2]string) []string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	var got []string
+	r := gin.New()
+	handler := func(c *gin.Context) {
+		got = append(got, idempotencyFingerprint(c, []byte(`{"quantity":2}`)))
+	}
+	for _, g := range []*gin.RouterGroup{r.Group(config.APIPrefix), r.Group("")} {
+		g.PUT("/cart/:id", handler)
+	}
+	for _, req := range requests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(req[0], req[1], strings.NewReader(`{"quantity":2}`)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: %d", req[0], req[1], w.Code)
+		}
+	}
+	return got
+}
+
+func TestIdempotencyFingerprintIgnoresPrefix(t *testing.T) {
+	got := fingerprints(t,
+		[2]string{http.MethodPut, config.APIPrefix + "/cart/5"},
+		[2]string{http.MethodPut, "/cart/5"},
+	)
+	if got[0] != got[1] {
+		t.Error("the prefixed and unprefixed paths fingerprint differently")
+	}
+}
+
+func TestIdempotencyFingerprintKeepsParams(t *testing.T) {
+	got := fingerprints(t,
+		[2]string{http.MethodPut, config.APIPrefix + "/cart/5"},
+		[2]string{http.MethodPut, config.APIPrefix + "/cart/6"},
+	)
+	if got[0] == got[1] {
+		t.Error("different path parameters fingerprint the same")
+	}
+}
@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"regexp"
+
+	"e-commerce/httperr"
+	"e-commerce/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ---------------- RequestIDMiddleware
+// Reuses a sane X-Request-ID from the client or proxy, otherwise makes one.
+// The ID is echoed in the response and included in error bodies and logs.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id, _ = utils.RandomToken(12)
+		}
+		c.Set(httperr.RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
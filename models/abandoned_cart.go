@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"e-commerce/money"
+)
+
+// AbandonedCart tracks one idle cart from detection until the user orders
+// or empties it. Only one row per user is open (ClosedAt unset) at a time.
+type AbandonedCart struct {
+	ID             uint        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID         uint        `gorm:"not null;uniqueIndex:idx_abandoned_cart_open,where:closed_at IS NULL" json:"user_id"`
+	Token          string      `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"` // identifies the resume link
+	CartValue      money.Money `gorm:"embedded;embeddedPrefix:cart_value_" json:"cart_value"`
+	RemindersSent  int         `gorm:"not null;default:0" json:"reminders_sent"`
+	LastRemindedAt *time.Time  `json:"last_reminded_at"`
+	ClickedAt      *time.Time  `json:"clicked_at"`
+	OrderID        *uint       `json:"order_id"`
+	OrderTotal     money.Money `gorm:"embedded;embeddedPrefix:order_total_" json:"order_total"`
+	ConvertedAt    *time.Time  `json:"converted_at"`
+	ClosedAt       *time.Time  `gorm:"index" json:"closed_at"`
+	CreatedAt      time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
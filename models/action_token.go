@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ActionToken records a signed one-time link (email verification, password
+// reset). The link itself carries the claims; this row enforces single use.
+type ActionToken struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Purpose   string     `gorm:"type:varchar(50);not null" json:"purpose"`
+	JTI       string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditLog records one admin mutation: who did what to which entity, and
+// the fields it changed. EntityType and EntityID are empty for actions that
+// do not target a known entity.
+type AuditLog struct {
+	ID         uint                   `gorm:"primaryKey;autoIncrement" json:"id"`
+	ActorID    *uint                  `gorm:"index" json:"actor_id"`
+	Action     string                 `gorm:"type:varchar(100);not null;index" json:"action"`
+	EntityType string                 `gorm:"type:varchar(50);index:idx_audit_entity" json:"entity_type"`
+	EntityID   string                 `gorm:"type:varchar(100);index:idx_audit_entity" json:"entity_id"`
+	Changes    map[string]AuditChange `gorm:"type:jsonb;serializer:json" json:"changes"`
+	Succeeded  bool                   `gorm:"not null;default:false;index" json:"succeeded"`
+	Status     int                    `gorm:"not null;default:0" json:"status"` // HTTP status of the request
+	Error      string                 `gorm:"type:text" json:"error,omitempty"`
+	Reason     string                 `gorm:"type:text" json:"reason,omitempty"` // given by the admin, e.g. for a block
+	IP         string                 `gorm:"type:varchar(64)" json:"ip"`
+	Method     string                 `gorm:"type:varchar(10)" json:"method"`
+	Path       string                 `gorm:"type:varchar(255)" json:"path"`
+	RequestID  string                 `gorm:"type:varchar(64)" json:"request_id"`
+	CreatedAt  time.Time              `gorm:"autoCreateTime;index" json:"created_at"`
+
+	Actor *User `gorm:"foreignKey:ActorID" json:"-"`
+}
+
+// AuditChange is a field's value before and after; From is null for a
+// created entity and To is null for a deleted one
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// FromText and ToText show the values as JSON for the admin page
+func (c AuditChange) FromText() string { return auditText(c.From) }
+func (c AuditChange) ToText() string   { return auditText(c.To) }
+
+func auditText(v interface{}) string {
+	if v == nil {
+		return "—"
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "?"
+	}
+	return string(b)
+}
@@ -1,14 +1,22 @@
 package models
 
-import "time"
+import (
+	"time"
 
+	"e-commerce/money"
+)
+
+// CartItem is one line of a user's cart; (user, product, saved_for_later)
+// is unique through idx_cart_items_line, created in the migration backfill
 type CartItem struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID    uint      `gorm:"not null" json:"user_id"`
-	ProductID uint      `gorm:"not null" json:"product_id"`
-	Quantity  int       `gorm:"not null;default:1" json:"quantity"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID            uint        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID        uint        `gorm:"not null" json:"user_id"`
+	ProductID     uint        `gorm:"not null" json:"product_id"`
+	Quantity      int         `gorm:"not null;default:1" json:"quantity"`
+	PriceAtAdd    money.Money `gorm:"embedded;embeddedPrefix:price_at_add_" json:"price_at_add"` // price when added, to flag changes
+	SavedForLater bool        `gorm:"not null;default:false" json:"saved_for_later"`
+	CreatedAt     time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
 
 	Product Product `gorm:"foreignKey:ProductID" json:"product"`
 }
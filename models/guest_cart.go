@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"e-commerce/money"
+)
+
+// GuestCart is an anonymous visitor's cart. The browser only holds a signed
+// cookie with Token; the cart expires unless it is touched again.
+type GuestCart struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Token     string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Items []GuestCartItem `gorm:"foreignKey:GuestCartID;constraint:OnDelete:CASCADE" json:"items"`
+}
+
+type GuestCartItem struct {
+	ID          uint        `gorm:"primaryKey;autoIncrement" json:"id"`
+	GuestCartID uint        `gorm:"not null;index:idx_guest_cart_product,unique" json:"-"`
+	ProductID   uint        `gorm:"not null;index:idx_guest_cart_product,unique" json:"product_id"`
+	Quantity    int         `gorm:"not null;default:1" json:"quantity"`
+	PriceAtAdd  money.Money `gorm:"embedded;embeddedPrefix:price_at_add_" json:"price_at_add"`
+	CreatedAt   time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"product"`
+}
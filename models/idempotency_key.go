@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers a mutating request sent with an Idempotency-Key
+// header so a retry can replay its response. Keys are unique per Scope
+// (the user, guest cart or anonymous caller). CompletedAt stays unset while
+// the original request is still running; once LockedUntil passes, such a
+// key is taken to be abandoned and a retry may claim it.
+type IdempotencyKey struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Scope        string     `gorm:"type:varchar(100);not null;uniqueIndex:idx_idempotency_scope_key" json:"scope"`
+	Key          string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_scope_key" json:"key"`
+	Fingerprint  string     `gorm:"type:varchar(64);not null" json:"-"` // sha256 of method, path and body
+	StatusCode   int        `gorm:"not null;default:0" json:"status_code"`
+	ContentType  string     `gorm:"type:varchar(100)" json:"-"`
+	ResponseBody string     `gorm:"type:text" json:"-"`
+	CompletedAt  *time.Time `json:"completed_at"`
+	LockedUntil  time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"locked_until"`
+	ExpiresAt    time.Time  `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
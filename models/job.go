@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Job is a unit of background work. Rows are inserted in the same
+// transaction as the business change that triggers them (outbox pattern).
+type Job struct {
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Type        string     `gorm:"type:varchar(100);not null;index" json:"type"`
+	Payload     string     `gorm:"type:jsonb;not null;default:'{}'" json:"payload"`
+	Status      string     `gorm:"type:varchar(20);not null;default:'pending';index:idx_jobs_status_run_at,priority:1" json:"status"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"not null;default:5" json:"max_attempts"`
+	RunAt       time.Time  `gorm:"not null;index:idx_jobs_status_run_at,priority:2" json:"run_at"`
+	UniqueKey   *string    `gorm:"type:varchar(255);uniqueIndex" json:"unique_key,omitempty"`
+	LockedAt    *time.Time `json:"locked_at"`
+	LockedBy    string     `gorm:"type:varchar(100)" json:"locked_by"`
+	LastError   string     `gorm:"type:text" json:"last_error"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
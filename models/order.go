@@ -1,17 +1,18 @@
 package models
 
 import (
-	"time"
+	"e-commerce/money"
 	"gorm.io/gorm"
+	"time"
 )
 
 type Order struct {
 	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID      uint           `gorm:"not null" json:"user_id"`
-	TotalAmount float64        `gorm:"not null" json:"total_amount"`
+	UserID      uint           `gorm:"not null;index:idx_orders_user_created,priority:1" json:"user_id"`
+	TotalAmount money.Money    `gorm:"embedded;embeddedPrefix:total_" json:"total_amount"`
 	Address     string         `gorm:"type:varchar(255);not null" json:"address"`
 	Status      string         `gorm:"type:varchar(50);default:'pending';not null" json:"status"`
-	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime;index;index:idx_orders_user_created,priority:2" json:"created_at"`
 	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 
@@ -1,16 +1,17 @@
 package models
 
 import (
-	"time"
+	"e-commerce/money"
 	"gorm.io/gorm"
+	"time"
 )
 
 type OrderItem struct {
 	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	OrderID   uint           `gorm:"not null" json:"order_id"`
+	OrderID   uint           `gorm:"not null;index" json:"order_id"`
 	ProductID uint           `gorm:"not null" json:"product_id"`
 	Quantity  int            `gorm:"not null" json:"quantity"`
-	Price     float64        `gorm:"not null" json:"price"`
+	Price     money.Money    `gorm:"embedded;embeddedPrefix:price_" json:"price"` // unit price in the order currency
 	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 
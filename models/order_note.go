@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrderNote is an internal remark on an order, never shown to the customer
+type OrderNote struct {
+	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID   uint           `gorm:"not null;index" json:"order_id"`
+	AuthorID  *uint          `json:"author_id"`
+	Body      string         `gorm:"type:text;not null" json:"body"`
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Author *User `gorm:"foreignKey:AuthorID" json:"-"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// OrderStatusChange is one entry of an order's status history. ActorID is
+// the admin who made the change; it is nil for changes made by the system,
+// such as a payment succeeding.
+type OrderStatusChange struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID    uint      `gorm:"not null;index" json:"order_id"`
+	FromStatus string    `gorm:"type:varchar(50);not null" json:"from_status"`
+	ToStatus   string    `gorm:"type:varchar(50);not null" json:"to_status"`
+	ActorID    *uint     `json:"actor_id"`
+	Note       string    `gorm:"type:text" json:"note"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	Actor *User `gorm:"foreignKey:ActorID" json:"-"`
+}
@@ -1,8 +1,9 @@
 package models
 
 import (
-	"time"
+	"e-commerce/money"
 	"gorm.io/gorm"
+	"time"
 )
 
 type Payment struct {
@@ -10,12 +11,12 @@ type Payment struct {
 	OrderID   uint           `gorm:"not null" json:"order_id"`
 	Gateway   string         `gorm:"type:varchar(50);not null" json:"gateway"`
 	PaymentID string         `gorm:"type:varchar(100);not null" json:"payment_id"`
-	Amount    float64        `gorm:"not null" json:"amount"`
+	Amount    money.Money    `gorm:"embedded" json:"amount"`
 	Status    string         `gorm:"type:varchar(50);not null" json:"status"`
-	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	CreatedAt time.Time      `gorm:"autoCreateTime;index" json:"created_at"`
 	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 
 	// Relation
 	Order Order `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE" json:"order"`
-}
\ No newline at end of file
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"e-commerce/money"
 	"gorm.io/gorm"
 	"time"
 )
@@ -9,11 +10,34 @@ type Product struct {
 	ID            uint           `gorm:"primaryKey;autoIncrement" json:"id"`
 	Name          string         `gorm:"type:varchar(255);not null" json:"name" binding:"required"`
 	Description   string         `gorm:"type:text" json:"description"`
-	Price         float64        `gorm:"type:decimal(10,2);not null" json:"price" binding:"required"`
+	Price         money.Money    `gorm:"embedded;embeddedPrefix:price_" json:"price"` // in the store currency
 	StockQuantity int            `gorm:"not null;default:0" json:"stock_quantity" binding:"required"`
 	Category      string         `gorm:"type:varchar(100)" json:"category"`
 	ImageURL      string         `gorm:"type:text" json:"image_url"`
 	CreatedAt     time.Time      `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt     time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Prices sells the product in other currencies
+	Prices []ProductPrice `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"prices"`
+}
+
+// ProductPrice is a product's price in one extra currency
+type ProductPrice struct {
+	ID        uint        `gorm:"primaryKey;autoIncrement" json:"-"`
+	ProductID uint        `gorm:"not null;index" json:"-"` // unique with the currency, see config.backfill
+	Price     money.Money `gorm:"embedded;embeddedPrefix:price_" json:"price"`
+}
+
+// PriceIn is the product's price in currency, if it is sold in it
+func (p Product) PriceIn(currency string) (money.Money, bool) {
+	if currency == "" || currency == p.Price.Currency {
+		return p.Price, true
+	}
+	for _, pp := range p.Prices {
+		if pp.Price.Currency == currency {
+			return pp.Price, true
+		}
+	}
+	return money.Money{}, false
 }
@@ -9,6 +9,7 @@ type ProductProduction struct {
 	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
 	ProductID   uint           `gorm:"not null" json:"product_id"`
 	Status      string         `gorm:"type:varchar(50);not null" json:"status"`
+	Quantity    int            `gorm:"not null;default:0" json:"quantity"` // added to stock on completion
 	StartedAt   time.Time      `gorm:"autoCreateTime" json:"started_at"`
 	CompletedAt *time.Time     `json:"completed_at"`
 	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
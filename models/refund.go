@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"e-commerce/money"
+)
+
+// Refund is money returned on a payment. It is saved as "pending" before
+// the gateway is called and becomes "succeeded" or "failed" with its answer.
+type Refund struct {
+	ID              uint        `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID         uint        `gorm:"not null;index" json:"order_id"`
+	PaymentID       uint        `gorm:"not null;index" json:"payment_id"`
+	GatewayRefundID string      `gorm:"type:varchar(100)" json:"gateway_refund_id"`
+	Amount          money.Money `gorm:"embedded" json:"amount"`
+	Reason          string      `gorm:"type:text" json:"reason"`
+	Restocked       bool        `gorm:"not null;default:false" json:"restocked"`
+	Status          string      `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	CreatedByID     *uint       `json:"created_by_id"`
+	CreatedAt       time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
+}
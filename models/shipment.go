@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Shipment is a parcel sent for an order; an order may ship in several
+type Shipment struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID        uint      `gorm:"not null;index" json:"order_id"`
+	Carrier        string    `gorm:"type:varchar(100);not null" json:"carrier"`
+	TrackingNumber string    `gorm:"type:varchar(100);not null" json:"tracking_number"`
+	TrackingURL    string    `gorm:"type:text" json:"tracking_url"`
+	CreatedByID    *uint     `json:"created_by_id"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SigningKey is an asymmetric JWT signing key. Keys are shared through the
+// database so every instance signs and verifies with the same set.
+type SigningKey struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	KID         string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"kid"`
+	Algorithm   string    `gorm:"type:varchar(20);not null" json:"algorithm"`
+	PrivateKey  string    `gorm:"type:text;not null" json:"-"`
+	PublicKey   string    `gorm:"type:text;not null" json:"public_key"`
+	ActivatesAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"activates_at"` // starts signing; published in the JWKS from creation
+	RetiresAt   time.Time `gorm:"not null" json:"retires_at"`                             // stops signing
+	ExpiresAt   time.Time `gorm:"not null" json:"expires_at"`                             // stops verifying
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// StockSubscription asks to be emailed once when a product is back in
+// stock. Email is always set; UserID only for logged-in subscribers. A
+// subscription is closed by setting NotifiedAt.
+type StockSubscription struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProductID  uint       `gorm:"not null;index;uniqueIndex:idx_stock_subscription_open,where:notified_at IS NULL" json:"product_id"`
+	UserID     *uint      `gorm:"index" json:"user_id,omitempty"`
+	Email      string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_stock_subscription_open,where:notified_at IS NULL" json:"email"`
+	NotifiedAt *time.Time `json:"notified_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+}
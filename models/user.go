@@ -8,7 +8,7 @@ import (
 type User struct {
 	ID           uint           `gorm:"primaryKey;autoIncrement" json:"id"`
 	FullName     string         `gorm:"type:varchar(255);not null" json:"full_name"`
-	Email        string         `gorm:"type:varchar(255);uniqueIndex;not null" json:"email"`
+	Email        string         `gorm:"type:varchar(255);not null" json:"email"`
 	PasswordHash string         `gorm:"type:varchar(255);not null" json:"-"`
 	Role         string         `gorm:"type:varchar(50);default:user;not null" json:"role"`
 	IsBlocked    bool           `gorm:"default:false;not null" json:"is_blocked"`
@@ -18,4 +18,9 @@ type User struct {
 	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt    time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// DeletionScheduledAt is set while a requested account deletion waits
+	// out its grace period; ErasedAt once the personal data is gone
+	DeletionScheduledAt *time.Time `gorm:"index" json:"deletion_scheduled_at,omitempty"`
+	ErasedAt            *time.Time `json:"-"`
 }
\ No newline at end of file
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Wishlist is a named list of products. Every user has one default list;
+// ShareToken, when set, makes the list readable at a public link.
+type Wishlist struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     uint      `gorm:"not null;index" json:"-"`
+	Name       string    `gorm:"type:varchar(100);not null" json:"name"`
+	IsDefault  bool      `gorm:"not null;default:false" json:"is_default"`
+	ShareToken *string   `gorm:"type:varchar(64);uniqueIndex" json:"-"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Items []WishlistItem `gorm:"foreignKey:WishlistID;constraint:OnDelete:CASCADE" json:"items,omitempty"`
+}
@@ -1,11 +1,24 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"e-commerce/money"
+)
 
 type WishlistItem struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID    uint      `gorm:"not null;index:idx_user_product,unique" json:"-"`
-	ProductID uint      `gorm:"not null;index:idx_user_product,unique" json:"-"`
-	Product   Product   `gorm:"foreignKey:ProductID" json:"product"`
+	ID         uint        `gorm:"primaryKey;autoIncrement" json:"id"`
+	WishlistID uint        `gorm:"not null;index:idx_wishlist_product,unique" json:"wishlist_id"`
+	UserID     uint        `gorm:"not null;index" json:"-"`
+	ProductID  uint        `gorm:"not null;index:idx_wishlist_product,unique" json:"-"`
+	Product    Product     `gorm:"foreignKey:ProductID" json:"product"`
+	PriceAtAdd money.Money `gorm:"embedded;embeddedPrefix:price_at_add_" json:"price_at_add"`
+
+	// opt-in alerts, checked by a background job
+	NotifyPriceDrop   bool   `gorm:"not null;default:false" json:"notify_price_drop"`
+	NotifyBackInStock bool   `gorm:"not null;default:false" json:"notify_back_in_stock"`
+	AlertedPrice      *int64 `json:"-"` // lowest price already alerted, in PriceAtAdd minor units
+	WasOutOfStock     bool   `gorm:"not null;default:false" json:"-"`
+
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,224 @@
+// Package money represents amounts as integer minor units (paise, cents)
+// with an ISO 4217 currency, so prices and totals never go through floats.
+//
+// Stored in the database as two columns through GORM's embedded structs:
+//
+//	Price money.Money `gorm:"embedded;embeddedPrefix:price_"` // price_amount, price_currency
+//
+// and in JSON as {"amount": 129900, "currency": "INR"}.
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in the currency's minor unit
+type Money struct {
+	Amount   int64  `gorm:"not null;default:0" json:"amount"`
+	Currency string `gorm:"type:varchar(3);not null;default:''" json:"currency"`
+}
+
+// currency describes how a currency is written
+type currency struct {
+	exponent int // digits after the decimal point
+	symbol   string
+}
+
+var currencies = map[string]currency{
+	"INR": {2, "₹"},
+	"USD": {2, "$"},
+	"EUR": {2, "€"},
+	"GBP": {2, "£"},
+	"AUD": {2, "A$"},
+	"CAD": {2, "C$"},
+	"SGD": {2, "S$"},
+	"AED": {2, "AED "},
+	"JPY": {0, "¥"},
+	"KWD": {3, "KWD "},
+}
+
+var (
+	ErrUnknownCurrency  = errors.New("money: unknown currency")
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+)
+
+var defaultCurrency = "INR"
+
+// DefaultCurrency is the store currency, used when an amount names none
+func DefaultCurrency() string {
+	return defaultCurrency
+}
+
+// SetDefaultCurrency changes the store currency; call once at startup
+func SetDefaultCurrency(code string) error {
+	code, err := ParseCurrency(code)
+	if err != nil {
+		return err
+	}
+	defaultCurrency = code
+	return nil
+}
+
+// ParseCurrency normalises an ISO code and checks that it is supported
+func ParseCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, ok := currencies[code]; !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownCurrency, code)
+	}
+	return code, nil
+}
+
+// Exponent is the number of minor-unit digits of a currency (2 for INR)
+func Exponent(code string) int {
+	if c, ok := currencies[code]; ok {
+		return c.exponent
+	}
+	return 2
+}
+
+// New is amount minor units of code
+func New(amount int64, code string) Money {
+	return Money{Amount: amount, Currency: code}
+}
+
+// Zero is nothing in code
+func Zero(code string) Money {
+	return Money{Currency: code}
+}
+
+// FromMajor converts a decimal amount such as 1299.5 to minor units,
+// rounding half away from zero. Only for input; never compute in floats.
+func FromMajor(major float64, code string) Money {
+	return Money{Amount: int64(math.Round(major * math.Pow10(Exponent(code)))), Currency: code}
+}
+
+// Major is the amount as a decimal, for display and legacy integrations
+func (m Money) Major() float64 {
+	return float64(m.Amount) / math.Pow10(Exponent(m.Currency))
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Times multiplies by a quantity
+func (m Money) Times(n int) Money {
+	return Money{Amount: m.Amount * int64(n), Currency: m.Currency}
+}
+
+// Add sums two amounts of the same currency. A zero value without a
+// currency takes the other's, so totals can start from Money{}.
+func (m Money) Add(o Money) (Money, error) {
+	cur, err := common(m, o)
+	if err != nil {
+		return m, err
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: cur}, nil
+}
+
+// Sub is m - o in the same currency
+func (m Money) Sub(o Money) (Money, error) {
+	cur, err := common(m, o)
+	if err != nil {
+		return m, err
+	}
+	return Money{Amount: m.Amount - o.Amount, Currency: cur}, nil
+}
+
+// Cmp compares amounts of the same currency: -1, 0 or 1
+func (m Money) Cmp(o Money) (int, error) {
+	if _, err := common(m, o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func common(a, b Money) (string, error) {
+	switch {
+	case a.Currency == b.Currency:
+		return a.Currency, nil
+	case a.Currency == "" && a.Amount == 0:
+		return b.Currency, nil
+	case b.Currency == "" && b.Amount == 0:
+		return a.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, a.Currency, b.Currency)
+}
+
+// Decimal is the plain amount with its minor digits, e.g. "1299.50"
+func (m Money) Decimal() string {
+	exp := Exponent(m.Currency)
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	if exp == 0 {
+		return sign + strconv.FormatInt(amount, 10)
+	}
+	unit := int64(math.Pow10(exp))
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/unit, exp, amount%unit)
+}
+
+// String is the amount for people, e.g. "₹1,299.50"
+func (m Money) String() string {
+	symbol := m.Currency + " "
+	if c, ok := currencies[m.Currency]; ok {
+		symbol = c.symbol
+	}
+	dec := m.Decimal()
+	sign := ""
+	if strings.HasPrefix(dec, "-") {
+		sign, dec = "-", dec[1:]
+	}
+	whole, frac := dec, ""
+	if i := strings.IndexByte(dec, '.'); i >= 0 {
+		whole, frac = dec[:i], dec[i:]
+	}
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	return sign + symbol + whole + frac
+}
+
+// UnmarshalJSON accepts {"amount": 129900, "currency": "INR"} and, for
+// older clients and queued data, a bare decimal in the store currency
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '{' {
+		var major float64
+		if err := json.Unmarshal(data, &major); err != nil {
+			return err
+		}
+		*m = FromMajor(major, defaultCurrency)
+		return nil
+	}
+
+	var raw struct {
+		Amount   int64  `json:"amount"`
+		Currency string `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Currency == "" {
+		raw.Currency = defaultCurrency
+	}
+	code, err := ParseCurrency(raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = Money{Amount: raw.Amount, Currency: code}
+	return nil
+}
@@ -0,0 +1,301 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Client talks to one OpenID Connect provider. HTTPClient can be swapped to
+// point at a local stub identity provider.
+type Client struct {
+	Config     ProviderConfig
+	HTTPClient *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDoc
+	keys      map[string]interface{}
+	keysAt    time.Time
+}
+
+type discoveryDoc struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// TokenResponse is the token endpoint reply
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// ErrGrantRejected means the token endpoint refused the code, e.g. a
+// reused code or a PKCE verifier that does not match the challenge
+var ErrGrantRejected = errors.New("authorization grant rejected")
+
+// Identity is the verified subset of ID token claims we rely on
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+func NewClient(cfg ProviderConfig, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	return &Client{Config: cfg, HTTPClient: httpClient}
+}
+
+// ---------- Discovery ----------
+func (c *Client) discover(ctx context.Context) (*discoveryDoc, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.discovery != nil {
+		return c.discovery, nil
+	}
+
+	var doc discoveryDoc
+	if err := c.getJSON(ctx, c.Config.Issuer+"/.well-known/openid-configuration", "", &doc); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != c.Config.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch %q", doc.Issuer)
+	}
+	c.discovery = &doc
+	return c.discovery, nil
+}
+
+// ---------- Authorization ----------
+
+// AuthCodeURL builds the provider login URL with state, nonce and a PKCE S256 challenge
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	doc, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.Config.ClientID},
+		"redirect_uri":          {c.Config.RedirectURL},
+		"scope":                 {strings.Join(c.Config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return doc.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens
+func (c *Client) Exchange(ctx context.Context, code, codeVerifier string) (*TokenResponse, error) {
+	doc, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.Config.RedirectURL},
+		"client_id":     {c.Config.ClientID},
+		"client_secret": {c.Config.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tok TokenResponse
+	if err := c.do(req, &tok); err != nil {
+		var status *statusError
+		if errors.As(err, &status) && status.code >= 400 && status.code < 500 {
+			return nil, fmt.Errorf("token exchange: %w: %v", ErrGrantRejected, err)
+		}
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+	if tok.IDToken == "" {
+		return nil, errors.New("token exchange: no id_token returned")
+	}
+	return &tok, nil
+}
+
+// ---------- ID token ----------
+
+// VerifyIDToken checks signature (via JWKS), issuer, audience, expiry and nonce
+func (c *Client) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Identity, error) {
+	doc, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(rawIDToken, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return c.key(ctx, doc.JWKSURI, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("id token: %w", err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("id token: invalid claims")
+	}
+
+	if iss, _ := claims["iss"].(string); strings.TrimRight(iss, "/") != c.Config.Issuer {
+		return nil, errors.New("id token: issuer mismatch")
+	}
+	if !audienceContains(claims["aud"], c.Config.ClientID) {
+		return nil, errors.New("id token: audience mismatch")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("id token: missing exp")
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, errors.New("id token: nonce mismatch")
+	}
+
+	ident := &Identity{}
+	ident.Subject, _ = claims["sub"].(string)
+	ident.Email, _ = claims["email"].(string)
+	ident.Name, _ = claims["name"].(string)
+	ident.Picture, _ = claims["picture"].(string)
+	ident.EmailVerified = boolClaim(claims["email_verified"])
+	if ident.Subject == "" {
+		return nil, errors.New("id token: missing sub")
+	}
+	return ident, nil
+}
+
+// UserInfo fills in email details some providers leave out of the ID token
+func (c *Client) UserInfo(ctx context.Context, accessToken string, ident *Identity) error {
+	doc, err := c.discover(ctx)
+	if err != nil {
+		return err
+	}
+	if doc.UserinfoEndpoint == "" {
+		return nil
+	}
+
+	var info map[string]interface{}
+	if err := c.getJSON(ctx, doc.UserinfoEndpoint, accessToken, &info); err != nil {
+		return fmt.Errorf("userinfo: %w", err)
+	}
+	if sub, _ := info["sub"].(string); sub != ident.Subject {
+		return errors.New("userinfo: subject mismatch")
+	}
+	if email, _ := info["email"].(string); email != "" {
+		ident.Email = email
+		ident.EmailVerified = boolClaim(info["email_verified"])
+	}
+	if ident.Name == "" {
+		ident.Name, _ = info["name"].(string)
+	}
+	return nil
+}
+
+// ---------- PKCE ----------
+
+// CodeChallenge derives the S256 challenge for a verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ---------- helpers ----------
+func (c *Client) getJSON(ctx context.Context, endpoint, bearer string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	return c.do(req, out)
+}
+
+func (c *Client) do(req *http.Request, out interface{}) error {
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{path: req.URL.Path, code: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+	return json.Unmarshal(body, out)
+}
+
+// statusError is a provider endpoint answering with something other than 200
+type statusError struct {
+	path string
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.path, e.code, e.body)
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if s, _ := a.(string); s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// some providers send email_verified as the string "true"
+func boolClaim(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		return b == "true"
+	}
+	return false
+}
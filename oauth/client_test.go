@@ -0,0 +1,121 @@
+package oauth_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"e-commerce/oauth"
+	"e-commerce/oauth/oauthtest"
+)
+
+const (
+	redirectURL = "http://shop.test/auth/oauth/stub/callback"
+	verifier    = "a-code-verifier-that-is-long-enough-for-pkce"
+)
+
+// login runs the authorization step against the stub and returns the code
+func login(t *testing.T, p *oauthtest.Provider, c *oauth.Client, state, nonce string) string {
+	t.Helper()
+	authURL, err := c.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback, err := p.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := callback.Query().Get("state"); got != state {
+		t.Fatalf("state = %q, want %q", got, state)
+	}
+	if !strings.HasPrefix(callback.String(), redirectURL) {
+		t.Fatalf("redirected to %s", callback)
+	}
+	return callback.Query().Get("code")
+}
+
+func TestLoginFlow(t *testing.T) {
+	p := oauthtest.NewProvider()
+	defer p.Close()
+	c := p.Client("stub", redirectURL)
+	ctx := context.Background()
+
+	code := login(t, p, c, "state-1", "nonce-1")
+	tokens, err := c.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ident, err := c.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ident.Subject != p.Subject || ident.Email != p.Email || !ident.EmailVerified || ident.Name != p.Name {
+		t.Errorf("identity = %+v", ident)
+	}
+	if err := c.UserInfo(ctx, tokens.AccessToken, ident); err != nil {
+		t.Errorf("userinfo: %v", err)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	p := oauthtest.NewProvider()
+	defer p.Close()
+	c := p.Client("stub", redirectURL)
+
+	code := login(t, p, c, "state-1", "nonce-1")
+	if _, err := c.Exchange(context.Background(), code, "someone-elses-verifier"); !errors.Is(err, oauth.ErrGrantRejected) {
+		t.Fatalf("err = %v, want ErrGrantRejected", err)
+	}
+}
+
+func TestExchangeCodeIsSingleUse(t *testing.T) {
+	p := oauthtest.NewProvider()
+	defer p.Close()
+	c := p.Client("stub", redirectURL)
+	ctx := context.Background()
+
+	code := login(t, p, c, "state-1", "nonce-1")
+	if _, err := c.Exchange(ctx, code, verifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Exchange(ctx, code, verifier); !errors.Is(err, oauth.ErrGrantRejected) {
+		t.Fatalf("second exchange: err = %v, want ErrGrantRejected", err)
+	}
+}
+
+func TestVerifyIDTokenRejectsNonceMismatch(t *testing.T) {
+	p := oauthtest.NewProvider()
+	defer p.Close()
+	c := p.Client("stub", redirectURL)
+	ctx := context.Background()
+
+	p.Nonce = "replayed-nonce"
+	code := login(t, p, c, "state-1", "nonce-1")
+	tokens, err := c.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.VerifyIDToken(ctx, tokens.IDToken, "nonce-1"); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("err = %v, want a nonce mismatch", err)
+	}
+}
+
+func TestVerifyIDTokenRejectsOtherAudience(t *testing.T) {
+	p := oauthtest.NewProvider()
+	defer p.Close()
+	c := p.Client("stub", redirectURL)
+	other := oauth.NewClient(oauth.ProviderConfig{
+		Name: "stub", Issuer: p.Issuer(), ClientID: "other-client", RedirectURL: redirectURL,
+	}, p.Server.Client())
+	ctx := context.Background()
+
+	code := login(t, p, c, "state-1", "nonce-1")
+	tokens, err := c.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.VerifyIDToken(ctx, tokens.IDToken, "nonce-1"); err == nil {
+		t.Fatal("accepted an ID token issued to another client")
+	}
+}
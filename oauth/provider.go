@@ -0,0 +1,84 @@
+package oauth
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"e-commerce/config"
+)
+
+// ProviderConfig describes one OpenID Connect identity provider
+type ProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// well-known issuers so only client credentials are needed for them
+var knownIssuers = map[string]string{
+	"google": "https://accounts.google.com",
+}
+
+var (
+	providersOnce sync.Once
+	providers     map[string]*Client
+	providersMu   sync.RWMutex
+)
+
+// loadProviders reads OAUTH_PROVIDERS=google,okta and, per provider,
+//
+//	OAUTH_<NAME>_CLIENT_ID, OAUTH_<NAME>_CLIENT_SECRET (required)
+//	OAUTH_<NAME>_ISSUER       (required unless the provider is well-known)
+//	OAUTH_<NAME>_REDIRECT_URL (default: APP_BASE_URL/auth/oauth/<name>/callback)
+//	OAUTH_<NAME>_SCOPES       (default: "openid email profile")
+func loadProviders() {
+	providers = map[string]*Client{}
+	for _, name := range strings.Split(os.Getenv("OAUTH_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+
+		cfg := ProviderConfig{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if cfg.Issuer == "" {
+			cfg.Issuer = knownIssuers[name]
+		}
+		if cfg.RedirectURL == "" {
+			cfg.RedirectURL = config.APIURL("/auth/oauth/" + name + "/callback")
+		}
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			continue
+		}
+		providers[name] = NewClient(cfg, nil)
+	}
+}
+
+// Provider returns the configured client for name
+func Provider(name string) (*Client, bool) {
+	providersOnce.Do(loadProviders)
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	c, ok := providers[name]
+	return c, ok
+}
+
+// RegisterProvider adds or replaces a provider at runtime, e.g. a local
+// stub identity provider
+func RegisterProvider(c *Client) {
+	providersOnce.Do(loadProviders)
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[c.Config.Name] = c
+}
@@ -0,0 +1,170 @@
+// Package openapi embeds the API's OpenAPI 3 document (openapi.json) and
+// keeps it honest: Undocumented reports registered routes the document is
+// missing, and ValidateBody checks JSON request bodies against it.
+//
+// The document is maintained by hand. Add the operation there whenever a
+// route is added in routes/*.go; the server refuses to start in debug mode
+// while a route is undocumented.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// Schema is the subset of JSON Schema the document uses
+type Schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Required   []string           `json:"required"`
+	Properties map[string]*Schema `json:"properties"`
+	Items      *Schema            `json:"items"`
+	AllOf      []*Schema          `json:"allOf"`
+	Enum       []interface{}      `json:"enum"`
+	Minimum    *float64           `json:"minimum"`
+	Maximum    *float64           `json:"maximum"`
+	MinLength  *int               `json:"minLength"`
+	MaxLength  *int               `json:"maxLength"`
+	MinItems   *int               `json:"minItems"`
+}
+
+// Operation is one documented method on a path
+type Operation struct {
+	OperationID string `json:"operationId"`
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+// Server is a base path operations are mounted under
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem is a documented path; its own servers replace the global ones
+type PathItem struct {
+	Servers []Server   `json:"servers"`
+	Get     *Operation `json:"get"`
+	Post    *Operation `json:"post"`
+	Put     *Operation `json:"put"`
+	Patch   *Operation `json:"patch"`
+	Delete  *Operation `json:"delete"`
+}
+
+func (p *PathItem) operation(method string) *Operation {
+	switch strings.ToUpper(method) {
+	case "GET":
+		return p.Get
+	case "POST":
+		return p.Post
+	case "PUT":
+		return p.Put
+	case "PATCH":
+		return p.Patch
+	case "DELETE":
+		return p.Delete
+	}
+	return nil
+}
+
+type document struct {
+	Servers    []Server             `json:"servers"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+var (
+	doc        = mustLoad()
+	operations = mountedOperations(doc)
+)
+
+func mustLoad() *document {
+	var d document
+	if err := json.Unmarshal(spec, &d); err != nil {
+		panic("openapi: invalid openapi.json: " + err.Error())
+	}
+	return &d
+}
+
+// mountedOperations keys every operation by method and full path, i.e. the
+// documented path under each server it is served on
+func mountedOperations(d *document) map[string]*Operation {
+	ops := map[string]*Operation{}
+	for path, item := range d.Paths {
+		servers := item.Servers
+		if len(servers) == 0 {
+			servers = d.Servers
+		}
+		for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+			op := item.operation(method)
+			if op == nil {
+				continue
+			}
+			for _, server := range servers {
+				ops[method+" "+strings.TrimSuffix(server.URL, "/")+path] = op
+			}
+		}
+	}
+	return ops
+}
+
+// Spec is the raw document, served at /openapi.json
+func Spec() []byte {
+	return spec
+}
+
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// Path converts a Gin route path ("/cart/:id") to OpenAPI form ("/cart/{id}")
+func Path(ginPath string) string {
+	return ginParam.ReplaceAllString(ginPath, "{$1}")
+}
+
+// Lookup finds the documented operation for a Gin route
+func Lookup(method, ginPath string) (*Operation, bool) {
+	op, ok := operations[strings.ToUpper(method)+" "+Path(ginPath)]
+	return op, ok
+}
+
+// Undocumented lists registered routes ("GET /cart/{id}") that have no
+// operation in the document
+func Undocumented(routes gin.RoutesInfo) []string {
+	missing := []string{}
+	for _, r := range routes {
+		if _, ok := Lookup(r.Method, r.Path); !ok {
+			missing = append(missing, r.Method+" "+Path(r.Path))
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// BodySchema is the JSON request body schema of an operation, if any
+func (op *Operation) BodySchema() *Schema {
+	if op.RequestBody == nil {
+		return nil
+	}
+	return op.RequestBody.Content["application/json"].Schema
+}
+
+// resolve follows a "#/components/schemas/Name" reference
+func resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
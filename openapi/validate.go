@@ -0,0 +1,143 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"e-commerce/services"
+)
+
+// ValidateBody checks a decoded JSON body against the operation's request
+// schema and returns one FieldError per problem. Nulls are treated like
+// absent values, matching how the handlers bind them.
+func ValidateBody(op *Operation, body interface{}) []services.FieldError {
+	schema := op.BodySchema()
+	if schema == nil {
+		return nil
+	}
+	var errs []services.FieldError
+	validate(schema, body, "", &errs)
+	return errs
+}
+
+func validate(s *Schema, v interface{}, field string, errs *[]services.FieldError) {
+	s = resolve(s)
+	if s == nil || v == nil {
+		return
+	}
+	fail := func(msg string) {
+		*errs = append(*errs, services.FieldError{Field: field, Message: msg})
+	}
+
+	for _, part := range s.AllOf {
+		validate(part, v, field, errs)
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if val, ok := obj[name]; !ok || val == nil {
+				*errs = append(*errs, services.FieldError{Field: join(field, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if val, ok := obj[name]; ok {
+				validate(s.Properties[name], val, join(field, name), errs)
+			}
+		}
+	case "array":
+		list, ok := v.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		if s.MinItems != nil && len(list) < *s.MinItems {
+			fail(fmt.Sprintf("must have at least %d items", *s.MinItems))
+		}
+		for i, item := range list {
+			validate(s.Items, item, field+"["+strconv.Itoa(i)+"]", errs)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		n := utf8.RuneCountInString(str)
+		if s.MinLength != nil && n < *s.MinLength {
+			if *s.MinLength == 1 {
+				fail("is required")
+			} else {
+				fail(fmt.Sprintf("must have at least %d characters", *s.MinLength))
+			}
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail(fmt.Sprintf("must have at most %d characters", *s.MaxLength))
+		}
+		if s.Format == "email" && str != "" {
+			if addr, err := mail.ParseAddress(str); err != nil || addr.Address != str {
+				fail("must be a valid email address")
+			}
+		}
+	case "integer", "number":
+		num, ok := v.(float64)
+		if !ok {
+			fail("must be a number")
+			return
+		}
+		if s.Type == "integer" && num != math.Trunc(num) {
+			fail("must be an integer")
+			return
+		}
+		if s.Minimum != nil && num < *s.Minimum {
+			fail("must be at least " + strconv.FormatFloat(*s.Minimum, 'f', -1, 64))
+		}
+		if s.Maximum != nil && num > *s.Maximum {
+			fail("must be at most " + strconv.FormatFloat(*s.Maximum, 'f', -1, 64))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("must be a boolean")
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		options := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			options[i] = fmt.Sprint(e)
+		}
+		fail("must be one of: " + strings.Join(options, ", "))
+	}
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func join(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -6,16 +6,20 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func AdminRoutes(r *gin.Engine) {
+func AdminRoutes(r gin.IRouter) {
 
 	admin := r.Group("/admin")
-	admin.Use(middlewares.AdminAuthMiddleware())
+	admin.Use(middlewares.AdminAuthMiddleware(), middlewares.IdempotencyMiddleware(), middlewares.AuditMiddleware())
 	{
 		admin.GET("/users", controllers.GetAllUsersHandler)
 		admin.GET("/users/:id", controllers.GetUserByIDHandler)
-		admin.PUT("/users/:id", controllers.UpdateUserHandler)
+		admin.PATCH("/users/:id", controllers.UpdateUserHandler)
+		admin.PUT("/users/:id", controllers.UpdateUserHandler) // same partial update as PATCH
 		admin.DELETE("/users/:id", controllers.DeleteUserHandler)
 		admin.POST("/users/:id/block", controllers.BlockUserHandler)
 		admin.POST("/users/:id/unblock", controllers.UnblockUserHandler)
+
+		admin.GET("/analytics", controllers.GetAnalyticsHandler)
+		admin.GET("/audit-log", controllers.GetAuditLogHandler)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,17 @@
+package routes
+
+import "github.com/gin-gonic/gin"
+
+// APIRoutes mounts every JSON endpoint on r. main mounts them under
+// config.APIPrefix and again at the root as deprecated aliases.
+func APIRoutes(r gin.IRouter) {
+	AuthRoutes(r)
+	UserRoutes(r)
+	AdminRoutes(r)
+	ProductRoutes(r)
+	WishlistRoutes(r)
+	CartRoutes(r)
+	OrdeRoutes(r)
+	PaymentRoutes(r)
+	JobRoutes(r)
+}
@@ -5,18 +5,27 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func AuthRoutes(r *gin.Engine) {
+// WellKnownRoutes stay at the root where clients look for them
+func WellKnownRoutes(r *gin.Engine) {
+	r.GET("/.well-known/jwks.json", controllers.JWKSHandler)
+}
 
+func AuthRoutes(r gin.IRouter) {
 	auth := r.Group("/auth")
 	{
 		auth.POST("/signup", controllers.SignupHandler)
 		auth.POST("/login", controllers.LoginHandler)
 		auth.POST("/send-otp", controllers.SendOTPHandler)
 		auth.POST("/verify-otp", controllers.VerifyOTPHandler)
+		auth.GET("/verify", controllers.VerifyLinkHandler)
 		auth.POST("/forgot-password", controllers.ForgotPasswordHandler)
 		auth.POST("/reset-password", controllers.ResetPasswordHandler)
 		auth.POST("/resend-otp", controllers.ResendOTPHandler)
 
+		// OpenID Connect social login
+		auth.GET("/oauth/:provider/start", controllers.OAuthStartHandler)
+		auth.GET("/oauth/:provider/callback", controllers.OAuthCallbackHandler)
+
 		// New refresh token endpoints
 		auth.POST("/refresh", controllers.RefreshTokenHandler)
 		auth.POST("/logout", controllers.LogoutHandler)
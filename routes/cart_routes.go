@@ -7,13 +7,32 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func CartRoutes(r *gin.Engine){
+func CartRoutes(r gin.IRouter){
 	cart:=r.Group("/cart")
-	cart.Use(middlewares.UserAuthMiddleware())
+	cart.Use(middlewares.UserAuthMiddleware(), middlewares.IdempotencyMiddleware())
 	{
 		cart.POST("",controllers.AddToCart)
 		cart.GET("",controllers.GetCartItems)
 		cart.PUT("/:id",controllers.UpdateCartItem)
 		cart.DELETE("/:id",controllers.DeleteCartItem)
+		cart.POST("/bulk", controllers.BulkAddToCart)
+		cart.POST("/bulk-delete", controllers.BulkDeleteCartItems)
+		cart.POST("/:id/save-for-later", controllers.SaveCartItemForLater)
+		cart.POST("/:id/restore", controllers.RestoreCartItem)
+		cart.POST("/:id/move-to-wishlist", controllers.MoveCartItemToWishlist)
 	}
-}
\ No newline at end of file
+
+	// tracked link from abandoned cart reminders
+	r.GET("/cart/resume/:token", controllers.ResumeCart)
+
+	// anonymous carts keyed by a signed cookie
+	guest := r.Group("/guest")
+	guest.Use(middlewares.IdempotencyMiddleware())
+	{
+		guest.GET("/cart", controllers.GetGuestCart)
+		guest.POST("/cart", controllers.AddToGuestCart)
+		guest.PUT("/cart/:id", controllers.UpdateGuestCartItem)
+		guest.DELETE("/cart/:id", controllers.DeleteGuestCartItem)
+		guest.POST("/checkout", controllers.GuestCheckout)
+	}
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"e-commerce/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func DocsRoutes(r *gin.Engine) {
+	r.GET("/openapi.json", controllers.OpenAPISpecHandler)
+	r.GET("/docs", controllers.ShowAPIDocs)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"e-commerce/controllers"
+	"e-commerce/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+func JobRoutes(r gin.IRouter) {
+	adminJobs := r.Group("/admin/jobs")
+	adminJobs.Use(middlewares.AdminAuthMiddleware(), middlewares.IdempotencyMiddleware(), middlewares.AuditMiddleware())
+	{
+		adminJobs.GET("", controllers.GetJobsHandler)
+		adminJobs.POST("/:id/retry", controllers.RetryJobHandler)
+		adminJobs.DELETE("/:id", controllers.DiscardJobHandler)
+	}
+}
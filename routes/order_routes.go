@@ -7,9 +7,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func OrdeRoutes(r *gin.Engine) {
+func OrdeRoutes(r gin.IRouter) {
 	order := r.Group("/order")
-	order.Use(middlewares.UserAuthMiddleware())
+	order.Use(middlewares.UserAuthMiddleware(), middlewares.IdempotencyMiddleware())
 	{
 		order.POST("", controllers.PlaceOrder)
 		order.GET("", controllers.GetUserOrders)
@@ -18,7 +18,7 @@ func OrdeRoutes(r *gin.Engine) {
 	}
 
 	adminOrders := r.Group("/admin/orders")
-	adminOrders.Use(middlewares.AdminAuthMiddleware())
+	adminOrders.Use(middlewares.AdminAuthMiddleware(), middlewares.IdempotencyMiddleware(), middlewares.AuditMiddleware())
 	{
 		adminOrders.GET("", controllers.GetAllOrders) 
 		adminOrders.PUT("/:id", controllers.UpdateOrderStatusAdmin)
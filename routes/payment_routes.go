@@ -7,15 +7,15 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func PaymentRoutes(r *gin.Engine) {
+func PaymentRoutes(r gin.IRouter) {
 	payments := r.Group("/payments")
-	payments.Use(middlewares.UserAuthMiddleware())
+	payments.Use(middlewares.UserAuthMiddleware(), middlewares.IdempotencyMiddleware())
 	{
 		payments.POST("/create", controllers.CreatePaymentIntent)
 	}
 	// Admin routes
 	adminPayments := r.Group("/admin/payments")
-	adminPayments.Use(middlewares.AdminAuthMiddleware())
+	adminPayments.Use(middlewares.AdminAuthMiddleware(), middlewares.IdempotencyMiddleware(), middlewares.AuditMiddleware())
 	{
 		adminPayments.PUT("/:payment_id/update", controllers.UpdatePaymentStatus)
 	}
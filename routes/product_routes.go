@@ -7,10 +7,11 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func ProductRoutes(r *gin.Engine) {
+func ProductRoutes(r gin.IRouter) {
 	admin := r.Group("/admin")
-	admin.Use(middlewares.AdminAuthMiddleware())
+	admin.Use(middlewares.AdminAuthMiddleware(), middlewares.IdempotencyMiddleware(), middlewares.AuditMiddleware())
 	{
+		admin.GET("/products", controllers.GetAdminProductsHandler)
 		admin.POST("/products", controllers.CreateProductHandler)
 	    admin.PUT("/products/:id", controllers.UpdateProductHandler)
 		admin.DELETE("/products/:id", controllers.DeleteProductHandler)
@@ -23,5 +24,6 @@ func ProductRoutes(r *gin.Engine) {
 	{
 		public.GET("", controllers.GetProductsHandler)
 		public.GET("/:id", controllers.GetProductByIDHandler)
+		public.POST("/:id/notify-me", middlewares.OptionalUserAuthMiddleware(), middlewares.IdempotencyMiddleware(), controllers.NotifyMeHandler)
 	}
 }
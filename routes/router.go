@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"net/http"
+
+	"e-commerce/config"
+	"e-commerce/controllers"
+	"e-commerce/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Router builds the engine with every route. Every response carries a
+// request ID and errors (including panics and unknown routes) use the JSON
+// error envelope. Templates are loaded by the caller.
+func Router() *gin.Engine {
+	router := gin.New()
+	router.Use(middlewares.RequestIDMiddleware(), gin.Logger(), gin.CustomRecovery(controllers.RecoveryHandler))
+	// cookie-authenticated changes must carry the CSRF token
+	router.Use(middlewares.CSRFMiddleware())
+	router.NoRoute(controllers.NoRouteHandler)
+
+	router.GET("/", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, "/login")
+	})
+
+	WellKnownRoutes(router)
+	AdminViewRoutes(router)
+	// JSON API; bodies are checked against the OpenAPI document first
+	APIRoutes(router.Group(config.APIPrefix, middlewares.RequestValidationMiddleware()))
+	// pre-versioning paths keep working until the sunset date
+	deprecated := middlewares.DeprecationMiddleware(config.APIPrefix, config.LegacyAPIDeprecated, config.LegacyAPISunset())
+	APIRoutes(router.Group("", deprecated, middlewares.RequestValidationMiddleware()))
+	DocsRoutes(router)
+	return router
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"testing"
+
+	"e-commerce/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// Every route main serves must be in openapi/openapi.json, which main only
+// enforces at startup outside release mode
+func TestEveryRouteDocumented(t *testing.T) {
+	if missing := openapi.Undocumented(Router().Routes()); len(missing) > 0 {
+		t.Errorf("routes missing from openapi/openapi.json: %v", missing)
+	}
+}
+
+func TestTemplatesParse(t *testing.T) {
+	Router().LoadHTMLGlob("../templates/*")
+}
@@ -7,11 +7,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func UserRoutes(r *gin.Engine) {
+func UserRoutes(r gin.IRouter) {
 	user := r.Group("/user")
-	user.Use(middlewares.UserAuthMiddleware())
+	user.Use(middlewares.UserAuthMiddleware(), middlewares.IdempotencyMiddleware())
 	{
 		user.GET("/profile", controllers.GetProfileHandler)
 		user.PUT("/profile", controllers.UpdateProfileHandler)
+		user.GET("/export", controllers.ExportUserDataHandler)
+		user.DELETE("/account", controllers.DeleteAccountHandler)
+		user.POST("/account/cancel-deletion", controllers.CancelAccountDeletionHandler)
 	}
 }
@@ -9,13 +9,18 @@ import (
 
 func AdminViewRoutes(r *gin.Engine) {
 	r.GET("/login", controllers.ShowLoginPage)
+	r.POST("/login", controllers.AdminLoginForm)
+	r.POST("/logout", controllers.AdminLogoutForm)
 	view := r.Group("/view")
-	view.Use(middlewares.AdminAuthMiddleware())
+	view.Use(middlewares.AdminViewAuthMiddleware(), middlewares.AuditMiddleware())
 	{
 		view.GET("/dashboard", controllers.ShowDashboard)
 		view.GET("/users", controllers.ShowUsersPage)
 		view.GET("/products", controllers.ShowProductsPage)
 		view.GET("/orders", controllers.ShowOrdersPage)
+		view.GET("/orders/:id", controllers.ShowOrderDetailPage)
+		view.GET("/jobs", controllers.ShowJobsPage)
+		view.GET("/audit-log", controllers.ShowAuditLogPage)
 
 		//---------USER EDTITE
 		view.GET("/users/edit/:id", controllers.ShowEditUserPage)
@@ -23,6 +28,20 @@ func AdminViewRoutes(r *gin.Engine) {
 		view.GET("/products/create", controllers.ShowCreateProductPage)
 		view.GET("/products/edit/:id", controllers.ShowEditProductPage)
 
+		//---------- ORDER MANAGEMENT (forms, PUT/DELETE via _method)
+		view.PUT("/orders/:id/status", controllers.UpdateOrderStatusForm)
+		view.POST("/orders/:id/shipments", controllers.AddShipmentForm)
+		view.POST("/orders/:id/refunds", controllers.RefundOrderForm)
+		view.POST("/orders/:id/notes", controllers.AddOrderNoteForm)
+		view.DELETE("/orders/:id/notes/:note_id", controllers.DeleteOrderNoteForm)
+
+		//---------- PRODUCTION
+		view.GET("/production", controllers.ShowProductionBoardPage)
+		view.POST("/production", controllers.StartProductionForm)
+		view.GET("/production/:id", controllers.ShowProductionRunPage)
+		view.PUT("/production/:id/status", controllers.UpdateProductionStatusForm)
+		view.GET("/production/products/:id", controllers.ShowProductionHistoryPage)
+
 		// ---------- ADMIN PROFILE ----------
 		view.GET("/profile", controllers.ShowAdminProfilePage)
 		view.GET("/profile/edit", controllers.ShowEditAdminProfilePage)
@@ -7,12 +7,34 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func WishlistRoutes(r *gin.Engine) {
+func WishlistRoutes(r gin.IRouter) {
+	// default list
 	wishlist := r.Group("/wishlist")
-	wishlist.Use(middlewares.UserAuthMiddleware())
+	wishlist.Use(middlewares.UserAuthMiddleware(), middlewares.IdempotencyMiddleware())
 	{
 		wishlist.POST("", controllers.AddToWishlist)
 		wishlist.GET("", controllers.GetWishlist)
 		wishlist.DELETE("/:product_id", controllers.RemoveFromWishlist)
+		wishlist.POST("/:product_id/move-to-cart", controllers.MoveWishlistItemToCart)
 	}
-}
\ No newline at end of file
+
+	// named lists
+	wishlists := r.Group("/wishlists")
+	wishlists.Use(middlewares.UserAuthMiddleware(), middlewares.IdempotencyMiddleware())
+	{
+		wishlists.GET("", controllers.GetWishlists)
+		wishlists.POST("", controllers.CreateWishlist)
+		wishlists.GET("/:id", controllers.GetWishlistByID)
+		wishlists.PUT("/:id", controllers.RenameWishlist)
+		wishlists.DELETE("/:id", controllers.DeleteWishlist)
+		wishlists.POST("/:id/share", controllers.ShareWishlist)
+		wishlists.DELETE("/:id/share", controllers.UnshareWishlist)
+		wishlists.POST("/:id/items", controllers.AddToWishlist)
+		wishlists.PUT("/:id/items/:product_id", controllers.UpdateWishlistItem)
+		wishlists.DELETE("/:id/items/:product_id", controllers.RemoveFromWishlist)
+		wishlists.POST("/:id/items/:product_id/move-to-cart", controllers.MoveWishlistItemToCart)
+	}
+
+	// read-only public link
+	r.GET("/shared/wishlists/:token", controllers.GetSharedWishlist)
+}
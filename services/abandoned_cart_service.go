@@ -0,0 +1,234 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"e-commerce/config"
+	"e-commerce/mailer"
+	"e-commerce/models"
+	"e-commerce/money"
+	"e-commerce/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobAbandonedCarts finds idle carts and sends reminder emails
+const JobAbandonedCarts = "cart.abandoned"
+
+// AbandonedCartSettings control when a cart counts as abandoned and how
+// often its owner is reminded
+type AbandonedCartSettings struct {
+	IdleAfter     time.Duration // ABANDONED_CART_IDLE, default 24h
+	MaxReminders  int           // ABANDONED_CART_MAX_REMINDERS, default 2
+	ReminderEvery time.Duration // ABANDONED_CART_REMINDER_INTERVAL, default 48h
+}
+
+func abandonedCartSettings() AbandonedCartSettings {
+	return AbandonedCartSettings{
+		IdleAfter:     envDuration("ABANDONED_CART_IDLE", 24*time.Hour),
+		MaxReminders:  envInt("ABANDONED_CART_MAX_REMINDERS", 2),
+		ReminderEvery: envDuration("ABANDONED_CART_REMINDER_INTERVAL", 48*time.Hour),
+	}
+}
+
+// ---------- Detection and reminders ----------
+
+// handleAbandonedCarts closes episodes whose cart was emptied, then opens
+// or advances one for every cart idle longer than IdleAfter with no order
+// placed since its last change
+func handleAbandonedCarts(db *gorm.DB, payload []byte) error {
+	settings := abandonedCartSettings()
+	now := time.Now()
+
+	if err := db.Model(&models.AbandonedCart{}).
+		Where("closed_at IS NULL AND NOT EXISTS (SELECT 1 FROM cart_items ci WHERE ci.user_id = abandoned_carts.user_id AND ci.saved_for_later = ?)", false).
+		Update("closed_at", now).Error; err != nil {
+		return err
+	}
+
+	var idle []struct {
+		UserID       uint
+		LastActivity time.Time
+	}
+	if err := db.Table("cart_items ci").
+		Select("ci.user_id, MAX(ci.updated_at) AS last_activity").
+		Joins("JOIN users u ON u.id = ci.user_id AND u.deleted_at IS NULL AND u.deletion_scheduled_at IS NULL").
+		Where("ci.saved_for_later = ? AND u.role = ? AND u.is_blocked = ?", false, "user", false).
+		Group("ci.user_id").
+		Having("MAX(ci.updated_at) < ? AND NOT EXISTS (SELECT 1 FROM orders o WHERE o.user_id = ci.user_id AND o.deleted_at IS NULL AND o.created_at > MAX(ci.updated_at))",
+			now.Add(-settings.IdleAfter)).
+		Scan(&idle).Error; err != nil {
+		return err
+	}
+
+	for _, cart := range idle {
+		if err := remindAbandonedCart(db, cart.UserID, settings, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func remindAbandonedCart(db *gorm.DB, userID uint, settings AbandonedCartSettings, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var episode models.AbandonedCart
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND closed_at IS NULL", userID).First(&episode).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if episode.ID != 0 {
+			if episode.RemindersSent >= settings.MaxReminders {
+				return nil
+			}
+			if episode.LastRemindedAt != nil && now.Sub(*episode.LastRemindedAt) < settings.ReminderEvery {
+				return nil
+			}
+		}
+
+		cart, err := ValidateCart(tx, userID)
+		if err != nil {
+			return err
+		}
+		if episode.ID == 0 {
+			token, err := utils.RandomToken(32)
+			if err != nil {
+				return err
+			}
+			episode = models.AbandonedCart{UserID: userID, Token: token}
+		}
+		episode.CartValue = cart.Totals.Subtotal
+
+		// nothing left that can be ordered: track it, but don't nag
+		if cart.Totals.Lines > 0 && episode.RemindersSent < settings.MaxReminders {
+			var user models.User
+			if err := tx.Select("email", "full_name").First(&user, userID).Error; err != nil {
+				return err
+			}
+			if err := EnqueueEmail(tx, user.Email, mailer.TemplateAbandonedCart, cartReminderData(user.FullName, cart, episode.Token)); err != nil {
+				return err
+			}
+			episode.RemindersSent++
+			episode.LastRemindedAt = &now
+		}
+		return tx.Save(&episode).Error
+	})
+}
+
+func cartReminderData(name string, cart *ValidatedCart, token string) mailer.CartReminderData {
+	lines := make([]mailer.OrderLine, 0, len(cart.Lines))
+	for _, line := range cart.Lines {
+		if line.Flags.ProductDeleted || line.Flags.OutOfStock || line.Flags.ReducedAvailability {
+			continue
+		}
+		lines = append(lines, mailer.OrderLine{
+			Name:     line.Item.Product.Name,
+			Quantity: line.Item.Quantity,
+			Price:    line.Item.Product.Price,
+		})
+	}
+	return mailer.CartReminderData{
+		Name:  name,
+		Items: lines,
+		Total: cart.Totals.Subtotal,
+		Link:  CartResumeURL(token),
+	}
+}
+
+// ---------- Tracking ----------
+
+// CartResumeURL is the tracked link in reminder emails
+func CartResumeURL(token string) string {
+	return config.APIURL("/cart/resume/" + token)
+}
+
+// TrackCartResume records the first click on a reminder link. Unknown or
+// closed tokens are ignored so old emails still lead to the cart.
+func TrackCartResume(db *gorm.DB, token string) error {
+	return db.Model(&models.AbandonedCart{}).
+		Where("token = ? AND clicked_at IS NULL", token).
+		Update("clicked_at", time.Now()).Error
+}
+
+// recordCartConversion closes the user's open episode with the order that
+// ended it; call in the order's transaction
+func recordCartConversion(tx *gorm.DB, userID uint, order models.Order) error {
+	now := time.Now()
+	return tx.Model(&models.AbandonedCart{}).
+		Where("user_id = ? AND closed_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"order_id":             order.ID,
+			"order_total_amount":   order.TotalAmount.Amount,
+			"order_total_currency": order.TotalAmount.Currency,
+			"converted_at":         now,
+			"closed_at":            now,
+		}).Error
+}
+
+// ---------- Reporting ----------
+
+// AbandonedCartStats summarise abandonment over a period. A cart counts as
+// recovered when it was ordered after at least one reminder.
+type AbandonedCartStats struct {
+	Abandoned        int64       `json:"abandoned"`
+	Recovered        int64       `json:"recovered"`
+	Orders           int64       `json:"orders"`
+	AbandonmentRate  float64     `json:"abandonment_rate"`  // percent of carts never ordered
+	RecoveredRevenue money.Money `json:"recovered_revenue"` // orders in the store currency only
+}
+
+// GetAbandonedCartStats reports carts abandoned since the given time. The
+// abandonment rate is abandoned-and-never-ordered carts over all carts
+// that either became an order or were abandoned.
+func GetAbandonedCartStats(db *gorm.DB, since time.Time) (*AbandonedCartStats, error) {
+	var stats AbandonedCartStats
+	var row struct {
+		Abandoned int64
+		Converted int64
+		Recovered int64
+		Revenue   int64
+	}
+	if err := db.Model(&models.AbandonedCart{}).
+		Select(`COUNT(*) AS abandoned,
+			COUNT(converted_at) AS converted,
+			COUNT(*) FILTER (WHERE converted_at IS NOT NULL AND reminders_sent > 0) AS recovered,
+			COALESCE(SUM(order_total_amount) FILTER (WHERE converted_at IS NOT NULL AND reminders_sent > 0 AND order_total_currency = ?), 0) AS revenue`,
+			money.DefaultCurrency()).
+		Where("created_at >= ?", since).Scan(&row).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.Order{}).Where("created_at >= ?", since).Count(&stats.Orders).Error; err != nil {
+		return nil, err
+	}
+
+	stats.Abandoned = row.Abandoned
+	stats.Recovered = row.Recovered
+	stats.RecoveredRevenue = money.New(row.Revenue, money.DefaultCurrency())
+	lost := row.Abandoned - row.Converted
+	if total := stats.Orders + lost; total > 0 {
+		stats.AbandonmentRate = math.Round(float64(lost)/float64(total)*10000) / 100
+	}
+	return &stats, nil
+}
+
+// ---------- helpers ----------
+func envInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || v <= 0 {
+		return fallback
+	}
+	return v
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"e-commerce/mailer"
+	"e-commerce/models"
+	"e-commerce/money"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobEraseAccounts erases the accounts whose deletion grace period is over
+const JobEraseAccounts = "accounts.erase"
+
+var (
+	ErrAdminAccountDeletion = Forbidden("admin_account", "Admin accounts cannot be deleted here; ask another admin to demote you first")
+	ErrOrdersInProgress     = Conflict("orders_in_progress", "Your account can be deleted once your open orders are delivered")
+	ErrNoDeletionScheduled  = Conflict("no_deletion_scheduled", "No account deletion is scheduled")
+	ErrDeletionScheduled    = Conflict("account_deletion_scheduled", "Your account is scheduled for deletion; cancel the deletion to place orders")
+)
+
+// accountDeletionGrace is how long a requested deletion can be cancelled
+// (ACCOUNT_DELETION_GRACE as a Go duration, default 720h = 30 days)
+func accountDeletionGrace() time.Duration {
+	return envDuration("ACCOUNT_DELETION_GRACE", 30*24*time.Hour)
+}
+
+// orders still being fulfilled hold up a deletion
+var openOrderStatuses = []string{"processing", "shipped"}
+
+// countOpenOrders counts the user's orders being fulfilled, and pending ones
+// with a payment in flight that may still succeed
+func countOpenOrders(tx *gorm.DB, userID uint) (int64, error) {
+	var open int64
+	err := tx.Model(&models.Order{}).
+		Where("user_id = ?", userID).
+		Where("status IN ? OR (status = ? AND EXISTS (SELECT 1 FROM payments p WHERE p.order_id = orders.id AND p.status = ? AND p.deleted_at IS NULL))",
+			openOrderStatuses, "pending", "pending").
+		Count(&open).Error
+	return open, err
+}
+
+// CheckOrderingAllowed refuses new orders and payments while the user's
+// account waits to be erased, so nothing is left in flight when it is
+func CheckOrderingAllowed(db *gorm.DB, userID uint) error {
+	var scheduled int64
+	if err := db.Model(&models.User{}).
+		Where("id = ? AND deletion_scheduled_at IS NOT NULL", userID).
+		Count(&scheduled).Error; err != nil {
+		return Internal("Failed to check account", err)
+	}
+	if scheduled > 0 {
+		return ErrDeletionScheduled
+	}
+	return nil
+}
+
+// ---------- Export ----------
+
+// UserExport is everything stored about a user, for GET /user/export.
+// Internal staff notes on orders are not included.
+type UserExport struct {
+	ExportedAt    time.Time                  `json:"exported_at"`
+	Profile       models.User                `json:"profile"`
+	Identities    []models.UserIdentity      `json:"identities"`
+	Orders        []ExportedOrder            `json:"orders"`
+	Cart          []models.CartItem          `json:"cart"`
+	Wishlists     []models.Wishlist          `json:"wishlists"`
+	StockAlerts   []models.StockSubscription `json:"stock_alerts"`
+	CartReminders []models.AbandonedCart     `json:"cart_reminders"`
+}
+
+// ExportedOrder is an order with its payments, refunds and shipments
+type ExportedOrder struct {
+	OrderResponse
+	Payments      []ExportedPayment          `json:"payments"`
+	Refunds       []models.Refund            `json:"refunds"`
+	Shipments     []models.Shipment          `json:"shipments"`
+	StatusHistory []models.OrderStatusChange `json:"status_history"`
+}
+
+type ExportedPayment struct {
+	ID        uint        `json:"id"`
+	Gateway   string      `json:"gateway"`
+	Reference string      `json:"reference"`
+	Amount    money.Money `json:"amount"`
+	Status    string      `json:"status"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// ExportUserData gathers a user's data
+func ExportUserData(db *gorm.DB, userID uint) (*UserExport, error) {
+	export := UserExport{ExportedAt: time.Now()}
+	if err := db.First(&export.Profile, userID).Error; err != nil {
+		return nil, ErrUserNotFound.Wrap(err)
+	}
+	user := export.Profile
+
+	// orders the user deleted are still held, so they are included
+	var orders []models.Order
+	if err := db.Unscoped().Preload("User").Preload("OrderItems.Product").
+		Where("user_id = ?", userID).Order("created_at").Find(&orders).Error; err != nil {
+		return nil, Internal("Failed to export orders", err)
+	}
+	orderIDs := make([]uint, len(orders))
+	for i, o := range orders {
+		orderIDs[i] = o.ID
+	}
+	var payments []models.Payment
+	var refunds []models.Refund
+	var shipments []models.Shipment
+	var history []models.OrderStatusChange
+	for _, q := range []struct {
+		dest interface{}
+		what string
+	}{{&payments, "payments"}, {&refunds, "refunds"}, {&shipments, "shipments"}, {&history, "order history"}} {
+		if err := db.Where("order_id IN ?", orderIDs).Order("created_at").Find(q.dest).Error; err != nil {
+			return nil, Internal("Failed to export "+q.what, err)
+		}
+	}
+
+	export.Orders = []ExportedOrder{}
+	byID := map[uint]*ExportedOrder{}
+	for _, o := range OrderResponses(orders) {
+		export.Orders = append(export.Orders, ExportedOrder{
+			OrderResponse: o,
+			Payments:      []ExportedPayment{},
+			Refunds:       []models.Refund{},
+			Shipments:     []models.Shipment{},
+			StatusHistory: []models.OrderStatusChange{},
+		})
+	}
+	for i := range export.Orders {
+		byID[export.Orders[i].ID] = &export.Orders[i]
+	}
+	for _, p := range payments {
+		o := byID[p.OrderID]
+		o.Payments = append(o.Payments, ExportedPayment{
+			ID: p.ID, Gateway: p.Gateway, Reference: p.PaymentID, Amount: p.Amount, Status: p.Status, CreatedAt: p.CreatedAt,
+		})
+	}
+	for _, r := range refunds {
+		byID[r.OrderID].Refunds = append(byID[r.OrderID].Refunds, r)
+	}
+	for _, s := range shipments {
+		byID[s.OrderID].Shipments = append(byID[s.OrderID].Shipments, s)
+	}
+	for _, h := range history {
+		byID[h.OrderID].StatusHistory = append(byID[h.OrderID].StatusHistory, h)
+	}
+
+	export.Identities = []models.UserIdentity{}
+	export.Cart = []models.CartItem{}
+	export.Wishlists = []models.Wishlist{}
+	export.StockAlerts = []models.StockSubscription{}
+	export.CartReminders = []models.AbandonedCart{}
+	queries := []struct {
+		query *gorm.DB
+		dest  interface{}
+		what  string
+	}{
+		{db.Where("user_id = ?", userID), &export.Identities, "identities"},
+		{db.Preload("Product").Where("user_id = ?", userID), &export.Cart, "cart"},
+		{db.Preload("Items.Product").Where("user_id = ?", userID), &export.Wishlists, "wishlists"},
+		{db.Where("user_id = ? OR email = ?", userID, user.Email), &export.StockAlerts, "stock alerts"},
+		{db.Where("user_id = ?", userID), &export.CartReminders, "cart reminders"},
+	}
+	for _, q := range queries {
+		if err := q.query.Order("created_at").Find(q.dest).Error; err != nil {
+			return nil, Internal("Failed to export "+q.what, err)
+		}
+	}
+	return &export, nil
+}
+
+// WriteZip writes the export as a ZIP with one JSON file per section
+func (e *UserExport) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", e.Profile},
+		{"identities.json", e.Identities},
+		{"orders.json", e.Orders},
+		{"cart.json", e.Cart},
+		{"wishlists.json", e.Wishlists},
+		{"stock_alerts.json", e.StockAlerts},
+		{"cart_reminders.json", e.CartReminders},
+	}
+	for _, file := range files {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: e.ExportedAt})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// ---------- Deletion ----------
+
+// RequestAccountDeletion schedules the user's account to be erased after
+// the grace period and emails them the date. The user confirms with their
+// email address. Asking again keeps the date already set.
+func RequestAccountDeletion(db *gorm.DB, userID uint, confirmEmail string) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return ErrUserNotFound.Wrap(err)
+		}
+		if user.Role == "admin" {
+			return ErrAdminAccountDeletion
+		}
+		if !strings.EqualFold(strings.TrimSpace(confirmEmail), user.Email) {
+			return Invalid("invalid_confirmation", "Confirm the deletion with your account email",
+				FieldError{Field: "confirm_email", Message: "must match your account email"})
+		}
+		if user.DeletionScheduledAt != nil {
+			return nil
+		}
+
+		open, err := countOpenOrders(tx, userID)
+		if err != nil {
+			return Internal("Failed to check orders", err)
+		}
+		if open > 0 {
+			return ErrOrdersInProgress
+		}
+
+		at := time.Now().Add(accountDeletionGrace())
+		user.DeletionScheduledAt = &at
+		if err := tx.Save(&user).Error; err != nil {
+			return Internal("Failed to schedule account deletion", err)
+		}
+		return EnqueueEmail(tx, user.Email, mailer.TemplateAccountDeletion, mailer.AccountDeletionData{
+			Name:     user.FullName,
+			DeleteOn: at.Format("2 January 2006"),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CancelAccountDeletion keeps an account whose deletion is still pending
+func CancelAccountDeletion(db *gorm.DB, userID uint) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return ErrUserNotFound.Wrap(err)
+		}
+		if user.DeletionScheduledAt == nil {
+			return ErrNoDeletionScheduled
+		}
+		user.DeletionScheduledAt = nil
+		if err := tx.Model(&user).Update("deletion_scheduled_at", nil).Error; err != nil {
+			return Internal("Failed to cancel account deletion", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// EraseAccount removes a user's personal data once their deletion is due.
+// Orders, payments and refunds are kept for accounting but no longer name
+// the user; carts, wishlists, alerts, sign-in methods, tokens and queued or
+// sent emails are deleted, and the user row is anonymized and soft-deleted.
+// An order paid or shipped during the grace period postpones the erasure
+// to a later run; erased reports whether the account was erased now.
+// Accounts an admin already soft-deleted are erased too.
+func EraseAccount(db *gorm.DB, userID uint) (erased bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return ErrUserNotFound.Wrap(err)
+		}
+		// cancelled, or not due yet
+		if user.DeletionScheduledAt == nil || user.DeletionScheduledAt.After(time.Now()) {
+			return nil
+		}
+		open, err := countOpenOrders(tx, userID)
+		if err != nil {
+			return err
+		}
+		if open > 0 {
+			log.Printf("⚠️ erasing account %d postponed: %d open orders", userID, open)
+			return nil
+		}
+
+		if err := tx.Unscoped().Model(&models.Order{}).Where("user_id = ?", userID).
+			Update("address", "[erased]").Error; err != nil {
+			return err
+		}
+
+		purges := []struct {
+			model interface{}
+			where string
+			args  []interface{}
+		}{
+			{&models.CartItem{}, "user_id = ?", []interface{}{userID}},
+			{&models.WishlistItem{}, "user_id = ?", []interface{}{userID}},
+			{&models.Wishlist{}, "user_id = ?", []interface{}{userID}},
+			{&models.StockSubscription{}, "user_id = ? OR email = ?", []interface{}{userID, user.Email}},
+			{&models.AbandonedCart{}, "user_id = ?", []interface{}{userID}},
+			// email jobs hold the address, name and codes; OTP jobs go
+			// before the OTPs they point to
+			{&models.Job{}, "type = ? AND LOWER(payload->>'to') = LOWER(?)", []interface{}{JobSendEmail, user.Email}},
+			{&models.Job{}, "type = ? AND (payload->>'otp_id')::bigint IN (SELECT id FROM otps WHERE user_id = ?)", []interface{}{JobSendOTP, userID}},
+			{&models.OTP{}, "user_id = ?", []interface{}{userID}},
+			{&models.ActionToken{}, "user_id = ?", []interface{}{userID}},
+			{&models.RefreshToken{}, "user_id = ?", []interface{}{userID}},
+			{&models.UserIdentity{}, "user_id = ?", []interface{}{userID}},
+			// stored responses can hold the profile
+			{&models.IdempotencyKey{}, "scope = ?", []interface{}{fmt.Sprintf("user:%d", userID)}},
+		}
+		for _, p := range purges {
+			if err := tx.Unscoped().Where(p.where, p.args...).Delete(p.model).Error; err != nil {
+				return err
+			}
+		}
+		// admin edits of the profile recorded its old values
+		if err := tx.Model(&models.AuditLog{}).
+			Where("entity_type = ? AND entity_id = ?", "user", fmt.Sprint(userID)).
+			Update("changes", nil).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Unscoped().Model(&user).Updates(map[string]interface{}{
+			"full_name":             "Deleted user",
+			"email":                 fmt.Sprintf("erased-%d@erased.invalid", userID),
+			"password_hash":         "",
+			"avatar_url":            nil,
+			"address":               "",
+			"is_blocked":            true,
+			"deletion_scheduled_at": nil,
+			"erased_at":             now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		erased = true
+		return RecordAudit(tx, &models.AuditLog{
+			Action:     "user.erase",
+			EntityType: "user",
+			EntityID:   fmt.Sprint(userID),
+			Succeeded:  true,
+		})
+	})
+	return erased && err == nil, err
+}
+
+// handleEraseAccounts erases every account whose grace period is over; a
+// failure is retried with the next run
+func handleEraseAccounts(db *gorm.DB, payload []byte) error {
+	var due []uint
+	// includes accounts an admin deleted, which wait soft-deleted
+	if err := db.Unscoped().Model(&models.User{}).
+		Where("deletion_scheduled_at <= ?", time.Now()).
+		Pluck("id", &due).Error; err != nil {
+		return err
+	}
+	var failed error
+	for _, id := range due {
+		if _, err := EraseAccount(db, id); err != nil {
+			log.Printf("❌ erasing account %d failed: %v", id, err)
+			failed = err
+		}
+	}
+	return failed
+}
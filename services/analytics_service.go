@@ -0,0 +1,281 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"e-commerce/models"
+	"e-commerce/money"
+	"gorm.io/gorm"
+)
+
+// revenueStatuses are the order statuses that count as sales: an order is
+// paid once it reaches "processing"
+var revenueStatuses = []string{"processing", "shipped", "delivered"}
+
+var analyticsIntervals = map[string]bool{"day": true, "week": true, "month": true}
+
+const (
+	analyticsDateFormat  = "2006-01-02"
+	analyticsDefaultDays = 30
+	maxAnalyticsPoints   = 400 // e.g. a bit over a year of days
+	topSellersLimit      = 10
+)
+
+// AnalyticsRange is the period a report covers: From up to, not including, To
+type AnalyticsRange struct {
+	From     time.Time
+	To       time.Time
+	Interval string // day, week or month
+}
+
+// ParseAnalyticsRange reads inclusive YYYY-MM-DD dates and a bucket size.
+// Empty values default to the last 30 days by day.
+func ParseAnalyticsRange(from, to, interval string, now time.Time) (AnalyticsRange, error) {
+	var fields []FieldError
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	r := AnalyticsRange{To: today.AddDate(0, 0, 1), Interval: interval}
+	if to != "" {
+		t, err := time.ParseInLocation(analyticsDateFormat, to, now.Location())
+		if err != nil {
+			fields = append(fields, FieldError{Field: "to", Message: "must be a date (YYYY-MM-DD)"})
+		} else {
+			r.To = t.AddDate(0, 0, 1)
+		}
+	}
+	r.From = r.To.AddDate(0, 0, -analyticsDefaultDays)
+	if from != "" {
+		t, err := time.ParseInLocation(analyticsDateFormat, from, now.Location())
+		if err != nil {
+			fields = append(fields, FieldError{Field: "from", Message: "must be a date (YYYY-MM-DD)"})
+		} else {
+			r.From = t
+		}
+	}
+	if r.Interval == "" {
+		r.Interval = "day"
+	}
+	if !analyticsIntervals[r.Interval] {
+		fields = append(fields, FieldError{Field: "interval", Message: "must be one of: day, week, month"})
+	}
+
+	if len(fields) == 0 {
+		if !r.From.Before(r.To) {
+			fields = append(fields, FieldError{Field: "from", Message: "must not be after to"})
+		} else if len(r.periods()) > maxAnalyticsPoints {
+			fields = append(fields, FieldError{Field: "interval", Message: "too many points for this range; use a longer interval"})
+		}
+	}
+	if len(fields) > 0 {
+		return r, Invalid("invalid_range", "Invalid report range", fields...)
+	}
+	return r, nil
+}
+
+// periods are the starts of every bucket in the range, formatted like the
+// database's date_trunc (weeks start on Monday)
+func (r AnalyticsRange) periods() []string {
+	start := r.From
+	switch r.Interval {
+	case "week":
+		start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+	case "month":
+		start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location())
+	}
+	var periods []string
+	for t := start; t.Before(r.To) && len(periods) <= maxAnalyticsPoints; {
+		periods = append(periods, t.Format(analyticsDateFormat))
+		switch r.Interval {
+		case "week":
+			t = t.AddDate(0, 0, 7)
+		case "month":
+			t = t.AddDate(0, 1, 0)
+		default:
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+	return periods
+}
+
+// Analytics is the sales report for a range. Money figures cover orders in
+// the store currency; order counts by status cover all orders.
+type Analytics struct {
+	From               string           `json:"from"` // inclusive dates
+	To                 string           `json:"to"`
+	Interval           string           `json:"interval"`
+	Currency           string           `json:"currency"`
+	Revenue            money.Money      `json:"revenue"`
+	Orders             int64            `json:"orders"` // paid orders
+	AverageOrderValue  money.Money      `json:"average_order_value"`
+	RevenueSeries      []RevenuePoint   `json:"revenue_series"`
+	OrdersByStatus     map[string]int64 `json:"orders_by_status"`
+	TopProducts        []ProductSales   `json:"top_products"`
+	TopCategories      []CategorySales  `json:"top_categories"`
+	RepeatCustomerRate float64          `json:"repeat_customer_rate"` // percent of buyers with more than one paid order so far
+	PaymentFailureRate float64          `json:"payment_failure_rate"` // percent of settled payments that failed
+}
+
+// RevenuePoint is one bucket of the revenue series
+type RevenuePoint struct {
+	Period  string      `json:"period"` // first day of the bucket
+	Orders  int64       `json:"orders"`
+	Revenue money.Money `json:"revenue"`
+}
+
+type ProductSales struct {
+	ProductID uint        `json:"product_id"`
+	Name      string      `json:"name"`
+	Quantity  int64       `json:"quantity"`
+	Revenue   money.Money `json:"revenue"`
+}
+
+type CategorySales struct {
+	Category string      `json:"category"`
+	Quantity int64       `json:"quantity"`
+	Revenue  money.Money `json:"revenue"`
+}
+
+// GetAnalytics builds the report with aggregate queries only, so its cost
+// follows the indexed range rather than the size of the order tables
+func GetAnalytics(db *gorm.DB, r AnalyticsRange) (*Analytics, error) {
+	currency := money.DefaultCurrency()
+	report := Analytics{
+		From:           r.From.Format(analyticsDateFormat),
+		To:             r.To.AddDate(0, 0, -1).Format(analyticsDateFormat),
+		Interval:       r.Interval,
+		Currency:       currency,
+		Revenue:        money.Zero(currency),
+		OrdersByStatus: map[string]int64{},
+		TopProducts:    []ProductSales{},
+		TopCategories:  []CategorySales{},
+	}
+
+	// revenue over time
+	var buckets []struct {
+		Period  string
+		Orders  int64
+		Revenue int64
+	}
+	if err := db.Model(&models.Order{}).
+		Select("to_char(date_trunc(?, created_at), 'YYYY-MM-DD') AS period, COUNT(*) AS orders, COALESCE(SUM(total_amount), 0) AS revenue", r.Interval).
+		Where("created_at >= ? AND created_at < ? AND status IN ? AND total_currency = ?", r.From, r.To, revenueStatuses, currency).
+		Group("period").Scan(&buckets).Error; err != nil {
+		return nil, err
+	}
+	byPeriod := map[string]RevenuePoint{}
+	for _, b := range buckets {
+		byPeriod[b.Period] = RevenuePoint{Period: b.Period, Orders: b.Orders, Revenue: money.New(b.Revenue, currency)}
+		report.Orders += b.Orders
+		report.Revenue.Amount += b.Revenue
+	}
+	for _, period := range r.periods() {
+		point, ok := byPeriod[period]
+		if !ok {
+			point = RevenuePoint{Period: period, Revenue: money.Zero(currency)}
+		}
+		report.RevenueSeries = append(report.RevenueSeries, point)
+	}
+	report.AverageOrderValue = money.Zero(currency)
+	if report.Orders > 0 {
+		report.AverageOrderValue.Amount = (report.Revenue.Amount + report.Orders/2) / report.Orders
+	}
+
+	// orders by status
+	var statuses []struct {
+		Status string
+		Count  int64
+	}
+	if err := db.Model(&models.Order{}).Select("status, COUNT(*) AS count").
+		Where("created_at >= ? AND created_at < ?", r.From, r.To).
+		Group("status").Scan(&statuses).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range statuses {
+		report.OrdersByStatus[s.Status] = s.Count
+	}
+
+	// best sellers, from the prices the items were sold at
+	sold := func() *gorm.DB {
+		return db.Table("order_items AS oi").
+			Joins("JOIN orders o ON o.id = oi.order_id AND o.deleted_at IS NULL").
+			Joins("LEFT JOIN products p ON p.id = oi.product_id").
+			Where("oi.deleted_at IS NULL AND o.created_at >= ? AND o.created_at < ? AND o.status IN ? AND o.total_currency = ?",
+				r.From, r.To, revenueStatuses, currency)
+	}
+	var products []struct {
+		ProductID uint
+		Name      string
+		Quantity  int64
+		Revenue   int64
+	}
+	if err := sold().
+		Select("oi.product_id, COALESCE(p.name, '') AS name, SUM(oi.quantity) AS quantity, SUM(oi.price_amount * oi.quantity) AS revenue").
+		Group("oi.product_id, p.name").Order("quantity DESC, revenue DESC").Limit(topSellersLimit).
+		Scan(&products).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range products {
+		report.TopProducts = append(report.TopProducts, ProductSales{
+			ProductID: p.ProductID, Name: p.Name, Quantity: p.Quantity, Revenue: money.New(p.Revenue, currency),
+		})
+	}
+
+	var categories []struct {
+		Category string
+		Quantity int64
+		Revenue  int64
+	}
+	if err := sold().
+		Select("COALESCE(NULLIF(p.category, ''), 'Uncategorized') AS category, SUM(oi.quantity) AS quantity, SUM(oi.price_amount * oi.quantity) AS revenue").
+		Group("1").Order("quantity DESC, revenue DESC").Limit(topSellersLimit).
+		Scan(&categories).Error; err != nil {
+		return nil, err
+	}
+	for _, cat := range categories {
+		report.TopCategories = append(report.TopCategories, CategorySales{
+			Category: cat.Category, Quantity: cat.Quantity, Revenue: money.New(cat.Revenue, currency),
+		})
+	}
+
+	// repeat customers: buyers in the range with more than one paid order
+	// up to its end, counting earlier orders too
+	var buyers struct {
+		Customers       int64
+		RepeatCustomers int64
+	}
+	if err := db.Raw(`SELECT COUNT(*) AS customers, COUNT(*) FILTER (WHERE b.paid_orders > 1) AS repeat_customers
+		FROM (
+			SELECT o.user_id, (
+				SELECT COUNT(*) FROM orders prev
+				WHERE prev.user_id = o.user_id AND prev.deleted_at IS NULL AND prev.status IN ? AND prev.created_at < ?
+			) AS paid_orders
+			FROM orders o
+			WHERE o.deleted_at IS NULL AND o.status IN ? AND o.created_at >= ? AND o.created_at < ?
+			GROUP BY o.user_id
+		) b`, revenueStatuses, r.To, revenueStatuses, r.From, r.To).Scan(&buyers).Error; err != nil {
+		return nil, err
+	}
+	report.RepeatCustomerRate = percent(buyers.RepeatCustomers, buyers.Customers)
+
+	var payments struct {
+		Failed  int64
+		Settled int64
+	}
+	if err := db.Model(&models.Payment{}).
+		Select("COUNT(*) FILTER (WHERE status = 'failed') AS failed, COUNT(*) FILTER (WHERE status IN ('succeeded', 'failed')) AS settled").
+		Where("created_at >= ? AND created_at < ?", r.From, r.To).Scan(&payments).Error; err != nil {
+		return nil, err
+	}
+	report.PaymentFailureRate = percent(payments.Failed, payments.Settled)
+
+	return &report, nil
+}
+
+// percent is part of total as a percentage with two decimals
+func percent(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*10000) / 100
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+
+	"e-commerce/models"
+	"gorm.io/gorm"
+)
+
+// ---------- Audit log ----------
+//
+// The audit middleware snapshots the entity an admin request targets before
+// and after the handler runs and stores the difference; handlers do not
+// write audit entries themselves.
+
+// auditEntity says where an audited entity type lives and which column the
+// ID in the route matches
+type auditEntity struct {
+	model  interface{}
+	column string
+}
+
+var auditEntities = map[string]auditEntity{
+	"user":       {&models.User{}, "id"},
+	"product":    {&models.Product{}, "id"},
+	"production": {&models.ProductProduction{}, "id"},
+	"order":      {&models.Order{}, "id"},
+	"order_note": {&models.OrderNote{}, "id"},
+	"payment":    {&models.Payment{}, "payment_id"},
+	"job":        {&models.Job{}, "id"},
+}
+
+// columns never worth a diff, and columns whose values must not be stored
+// (job payloads carry customers' emails and names)
+var (
+	auditIgnored  = map[string]bool{"updated_at": true}
+	auditRedacted = map[string]bool{"password_hash": true, "payload": true}
+)
+
+const auditRedactedValue = "[redacted]"
+
+// AuditSnapshot reads the row of entityType with the given ID as column
+// values, or nil when it does not exist (or is soft-deleted)
+func AuditSnapshot(db *gorm.DB, entityType, id string) (map[string]interface{}, error) {
+	entity, ok := auditEntities[entityType]
+	if !ok || id == "" {
+		return nil, nil
+	}
+	row := map[string]interface{}{}
+	err := db.Model(entity.model).Where(entity.column+" = ?", id).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return normalizeAuditRow(row), nil
+}
+
+// AuditDiff lists the columns that differ between two snapshots. A nil
+// before means the entity was created, a nil after that it was deleted.
+func AuditDiff(before, after map[string]interface{}) map[string]models.AuditChange {
+	if before == nil && after == nil {
+		return nil
+	}
+	changes := map[string]models.AuditChange{}
+	for _, column := range auditColumns(before, after) {
+		if auditIgnored[column] {
+			continue
+		}
+		from, hadFrom := before[column]
+		to, hadTo := after[column]
+		if hadFrom && hadTo && reflect.DeepEqual(from, to) {
+			continue
+		}
+		if auditRedacted[column] {
+			from, to = redactAudit(from, hadFrom), redactAudit(to, hadTo)
+		}
+		changes[column] = models.AuditChange{From: from, To: to}
+	}
+	return changes
+}
+
+// RecordAudit stores an audit entry
+func RecordAudit(db *gorm.DB, entry *models.AuditLog) error {
+	if err := db.Create(entry).Error; err != nil {
+		return Internal("Failed to record audit entry", err)
+	}
+	return nil
+}
+
+var auditSort = listSort{
+	columns: map[string]string{
+		"id": "audit_logs.id", "action": "audit_logs.action", "created_at": "audit_logs.created_at",
+	},
+	defaultKey:  "created_at",
+	defaultDesc: true,
+	tiebreak:    "audit_logs.id",
+}
+
+// ListAuditLogs searches action and path. Filters: actor_id, action,
+// entity_type, entity_id and from/to on the time of the request.
+func ListAuditLogs(db *gorm.DB, q ListQuery) ([]models.AuditLog, *Pagination, error) {
+	f := filters{values: q.Values}
+	query := db.Model(&models.AuditLog{})
+	if q.Search != "" {
+		like := likePattern(q.Search)
+		query = query.Where("audit_logs.action ILIKE ? OR audit_logs.path ILIKE ?", like, like)
+	}
+	if f.has("actor_id") {
+		if actor := f.int("actor_id", 0, 1, 1<<31-1); actor > 0 {
+			query = query.Where("audit_logs.actor_id = ?", actor)
+		}
+	}
+	if action := f.string("action"); action != "" {
+		query = query.Where("audit_logs.action = ?", action)
+	}
+	if entityType := f.string("entity_type"); entityType != "" {
+		if _, ok := auditEntities[entityType]; !ok {
+			f.fail("entity_type", "must be one of: "+strings.Join(AuditEntityTypes(), ", "))
+		}
+		query = query.Where("audit_logs.entity_type = ?", entityType)
+	}
+	if entityID := f.string("entity_id"); entityID != "" {
+		query = query.Where("audit_logs.entity_id = ?", entityID)
+	}
+	query = f.dateRange(query, "audit_logs.created_at")
+	order := auditSort.order(q, &f)
+	if err := f.err(); err != nil {
+		return nil, nil, err
+	}
+
+	logs := []models.AuditLog{}
+	page, err := paginate(query.Preload("Actor"), q, order, &logs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return logs, page, nil
+}
+
+// AuditEntityTypes names the entity types the log can target, for filters
+func AuditEntityTypes() []string {
+	types := make([]string, 0, len(auditEntities))
+	for t := range auditEntities {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// ---------- helpers ----------
+
+// normalizeAuditRow turns driver values into what they read back as from
+// JSON, so snapshots compare the same way they are stored
+func normalizeAuditRow(row map[string]interface{}) map[string]interface{} {
+	for column, v := range row {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+		var out interface{}
+		if json.Unmarshal(raw, &out) == nil {
+			row[column] = out
+		}
+	}
+	return row
+}
+
+func auditColumns(before, after map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var columns []string
+	for _, row := range []map[string]interface{}{before, after} {
+		for column := range row {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func redactAudit(v interface{}, present bool) interface{} {
+	if !present || v == nil {
+		return nil
+	}
+	return auditRedactedValue
+}
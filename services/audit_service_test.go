@@ -0,0 +1,28 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuditDiffRedactsSecrets(t *testing.T) {
+	before := map[string]interface{}{"status": "failed", "payload": `{"to":"ann@example.com"}`, "updated_at": "t1"}
+	after := map[string]interface{}{"status": "pending", "payload": `{"to":"bob@example.com"}`, "updated_at": "t2"}
+
+	changes := AuditDiff(before, after)
+	if _, ok := changes["updated_at"]; ok {
+		t.Error("updated_at is diffed")
+	}
+	if c := changes["status"]; c.From != "failed" || c.To != "pending" {
+		t.Errorf("status change = %+v", c)
+	}
+	c, ok := changes["payload"]
+	if !ok {
+		t.Fatal("payload change missing")
+	}
+	for _, v := range []interface{}{c.From, c.To} {
+		if s, _ := v.(string); strings.Contains(s, "@example.com") {
+			t.Errorf("payload stored in the clear: %v", v)
+		}
+	}
+}
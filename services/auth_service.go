@@ -2,48 +2,50 @@ package services
 
 import (
 	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
-	"net/smtp"
-	"os"
+	"strings"
 	"time"
 
+	"e-commerce/jobs"
+	"e-commerce/mailer"
 	"e-commerce/models"
 	"e-commerce/utils"
 
 	"gorm.io/gorm"
 )
 
-// ---------- helper: send email using SMTP (Gmail) ----------
-func sendEmail(to, subject, body string) error {
-	from := os.Getenv("SMTP_EMAIL")
-	password := os.Getenv("SMTP_PASSWORD")
-	if from == "" || password == "" {
-		fmt.Printf("SMTP not configured - email to %s: %s\n%s\n", to, subject, body)
-		return nil
-	}
-
-	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s",
-		from, to, subject, body)
-
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
-
-	auth := smtp.PlainAuth("", from, password, smtpHost)
-	return smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{to}, []byte(msg))
-}
+var (
+	ErrEmailTaken         = Conflict("email_taken", "Email already registered")
+	ErrUserNotFound       = NotFound("user_not_found", "User not found")
+	ErrAccountBlocked     = Forbidden("account_blocked", "Account is blocked")
+	ErrInvalidCredentials = Unauthorized("invalid_credentials", "Invalid credentials")
+	ErrEmailNotVerified   = Forbidden("email_not_verified", "Email not verified")
+	ErrAdminsOnly         = Forbidden("admins_only", "Access denied: Admins only")
+	ErrInvalidOTP         = Invalid("invalid_otp", "Invalid OTP", FieldError{Field: "otp", Message: "is not valid"})
+	ErrOTPExpired         = Invalid("otp_expired", "OTP expired", FieldError{Field: "otp", Message: "has expired"})
+)
 
 // ---------- Signup ----------
-func SignupService(db *gorm.DB, fullName, email, password string) error {
-	var existing models.User
-	if err := db.Where("email = ?", email).First(&existing).Error; err == nil {
-		return errors.New("email already registered")
+
+// SignupService registers a new, unverified user. Orders from guest checkout
+// with the same email are only attached once the email is verified (see
+// claimGuestOrders), so a signup cannot take over a guest's history. The
+// guest cart identified by guestCart, if any, is merged into the new account.
+func SignupService(db *gorm.DB, fullName, email, password, guestCart string) error {
+	email = normalizeEmail(email)
+	if _, err := findAccount(db, email); err == nil {
+		return ErrEmailTaken
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return err
 	}
 
 	hashedPassword, err := utils.HashPassword(password)
 	if err != nil {
-		return errors.New("failed to hash password")
+		return Internal("Failed to hash password", err)
 	}
 
 	user := models.User{
@@ -54,90 +56,126 @@ func SignupService(db *gorm.DB, fullName, email, password string) error {
 		IsVerified:   false,
 	}
 
-	if err := db.Create(&user).Error; err != nil {
-		return err
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if guestCart != "" {
+			if err := MergeGuestCart(tx, guestCart, user.ID); err != nil {
+				return err
+			}
+		}
+
+		// send signup OTP
+		return SendOTPService(tx, user.ID, PurposeSignup)
+	})
+}
+
+// ---------- Login ----------
+func LoginService(db *gorm.DB, email, password, guestCart string) (string,string, error) {
+	user, err := checkCredentials(db, email, password)
+	if err != nil {
+		return "", "", err
 	}
 
-	// send signup OTP
-	return SendOTPService(db, user.ID, user.Email, "signup")
+	accessToken, err := issueSession(db, user)
+	if err != nil {
+		return "", "", err
+	}
+	mergeGuestCartOnLogin(db, guestCart, user.ID)
+	return accessToken,user.Role, nil
 }
 
-// ---------- Login ----------
-func LoginService(db *gorm.DB, email, password string) (string,string, error) {
-	// cleanup expired refresh tokens (optional housekeeping)
-	db.Where("expires_at < ?", time.Now()).Delete(&models.RefreshToken{})
+// AdminLoginService signs in to the admin panel. Other roles are refused
+// before a session is issued, so their own sessions are left alone.
+func AdminLoginService(db *gorm.DB, email, password string) (string, error) {
+	user, err := checkCredentials(db, email, password)
+	if err != nil {
+		return "", err
+	}
+	if user.Role != "admin" {
+		return "", ErrAdminsOnly
+	}
+	return issueSession(db, user)
+}
 
-	var user models.User
-	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
-		return "", "", errors.New("user not found")
+// checkCredentials finds the verified, unblocked user email and password
+// belong to
+func checkCredentials(db *gorm.DB, email, password string) (models.User, error) {
+	user, err := findAccount(db, email)
+	if err != nil {
+		return user, err
 	}
-    if user.IsBlocked {
-		return "","", errors.New("account is blocked")
+	if user.IsBlocked {
+		return user, ErrAccountBlocked
 	}
 	if !utils.CheckPasswordHash(password, user.PasswordHash) {
-		return  "","", errors.New("invalid credentials")
+		return user, ErrInvalidCredentials
 	}
-
 	if !user.IsVerified {
-		return  "","", errors.New("email not verified")
+		return user, ErrEmailNotVerified
 	}
+	return user, nil
+}
 
+// issueSession creates an access token and stores a fresh refresh token
+func issueSession(db *gorm.DB, user models.User) (string, error) {
 	accessToken, err := utils.GenerateJWT(int(user.ID), user.Role)
 	if err != nil {
-		return  "", "",errors.New("failed to generate access token")
+		return "", Internal("Failed to generate access token", err)
 	}
 
 	refreshPlain, err := utils.GenerateRefreshToken()
 	if err != nil {
-		return  "","", errors.New("failed to generate refresh token")
+		return "", Internal("Failed to generate refresh token", err)
 	}
 
 	if err := utils.SaveRefreshToken(db, user.ID, refreshPlain, time.Now().Add(7*24*time.Hour)); err != nil {
-		return "","", errors.New("failed to save refresh token")
+		return "", Internal("Failed to save refresh token", err)
 	}
-
-	return accessToken,user.Role, nil
+	return accessToken, nil
 }
 
 // ---------- Forgot Password ----------
 func ForgotPasswordService(db *gorm.DB, email string) error {
-	var user models.User
-	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
-		return errors.New("user not found")
+	user, err := findAccount(db, email)
+	if err != nil {
+		return err
 	}
 
-	// create and send OTP for reset_password
-	return SendOTPService(db, user.ID, user.Email, "reset_password")
+	// create and send OTP + reset link
+	return SendOTPService(db, user.ID, PurposeResetPassword)
 }
 
 // ---------- Reset Password ----------
 func ResetPasswordService(db *gorm.DB, email, otpCode, newPassword string) error {
-	var user models.User
-	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
-		return errors.New("user not found")
+	user, err := findAccount(db, email)
+	if err != nil {
+		return err
 	}
 
 	var otp models.OTP
-	if err := db.Where("user_id = ? AND otp_code = ? AND purpose = ? AND is_used = ?", user.ID, otpCode, "reset_password", false).
+	if err := db.Where("user_id = ? AND otp_code = ? AND purpose = ? AND is_used = ?", user.ID, otpCode, PurposeResetPassword, false).
 		First(&otp).Error; err != nil {
-		return errors.New("invalid otp")
+		return ErrInvalidOTP
 	}
 
 	if time.Now().After(otp.ExpiresAt) {
-		return errors.New("otp expired")
+		return ErrOTPExpired
 	}
 
 	hashed, err := utils.HashPassword(newPassword)
 	if err != nil {
-		return errors.New("failed to hash password")
+		return Internal("Failed to hash password", err)
 	}
 	user.PasswordHash = hashed
 	if err := db.Save(&user).Error; err != nil {
-		return errors.New("failed to update password")
+		return Internal("Failed to update password", err)
 	}
 
 	otp.IsUsed = true
 	db.Save(&otp)
+	revokeActionLinks(db, user.ID, PurposeResetPassword)
 
 	return nil
 }
@@ -146,17 +184,17 @@ func ResetPasswordService(db *gorm.DB, email, otpCode, newPassword string) error
 func RefreshService(db *gorm.DB, userID uint) (string, error) {
 	rt, err := utils.GetRefreshTokenByUserID(db, userID)
 	if err != nil {
-		return "", err
+		return "", ErrSessionExpired.Wrap(err)
 	}
 
 	_, err = utils.ValidateRefreshToken(db, rt.Token)
 	if err != nil {
-		return "", err
+		return "", ErrSessionExpired.Wrap(err)
 	}
 
 	var user models.User
 	if err := db.First(&user, userID).Error; err != nil {
-		return "", errors.New("user not found")
+		return "", ErrUserNotFound
 	}
 
 	return utils.GenerateJWT(int(user.ID), user.Role)
@@ -166,7 +204,7 @@ func RefreshService(db *gorm.DB, userID uint) (string, error) {
 func LogoutService(db *gorm.DB, userID uint) error {
 	rt, err := utils.GetRefreshTokenByUserID(db, userID)
 	if err != nil {
-		return err
+		return ErrSessionExpired.Wrap(err)
 	}
 
 	return utils.DeleteRefreshToken(db, rt.Token)
@@ -182,12 +220,15 @@ func generateSecureOTP() (string, error) {
 }
 
 // ---------- OTP functions ----------
-func SendOTPService(db *gorm.DB, userID uint, email, purpose string) error {
-	db.Where("user_id = ? AND purpose = ? AND is_used = ?", userID, purpose, false).Delete(&models.OTP{})
 
+// SendOTPService emails a 6-digit code together with a signed one-time link
+// for the same purpose. The link suits browsers; the code is the fallback
+// for mobile apps. The email job only references the OTP and link rows, so
+// neither the code nor the link ends up in the job queue.
+func SendOTPService(db *gorm.DB, userID uint, purpose string) error {
 	code, err := generateSecureOTP()
 	if err != nil {
-		return errors.New("failed to generate otp")
+		return Internal("Failed to generate OTP", err)
 	}
 
 	otp := models.OTP{
@@ -198,44 +239,163 @@ func SendOTPService(db *gorm.DB, userID uint, email, purpose string) error {
 		IsUsed:    false,
 	}
 
-	if err := db.Create(&otp).Error; err != nil {
+	// the OTP row, link token and email job commit together
+	return db.Transaction(func(tx *gorm.DB) error {
+		tx.Where("user_id = ? AND purpose = ? AND is_used = ?", userID, purpose, false).Delete(&models.OTP{})
+
+		if err := tx.Create(&otp).Error; err != nil {
+			return err
+		}
+		link, err := issueActionLink(tx, userID, purpose)
+		if err != nil {
+			return err
+		}
+		return jobs.Enqueue(tx, JobSendOTP, otpEmailJob{OTPID: otp.ID, LinkID: link.ID})
+	})
+}
+
+type otpEmailJob struct {
+	OTPID  uint `json:"otp_id"`
+	LinkID uint `json:"link_id"`
+}
+
+// handleSendOTP renders the code email when it is sent. Codes that were
+// used, replaced or have expired in the meantime are not sent.
+func handleSendOTP(db *gorm.DB, payload []byte) error {
+	var job otpEmailJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	var otp models.OTP
+	if err := db.First(&otp, job.OTPID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if otp.IsUsed || time.Now().After(otp.ExpiresAt) {
+		return nil
+	}
+	var user models.User
+	if err := db.First(&user, otp.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
 		return err
 	}
 
-	body := fmt.Sprintf("Your OTP code is: %s\nIt expires in 10 minutes.", code)
-	subject := "Your OTP Code"
-	return sendEmail(email, subject, body)
+	data := mailer.OTPData{Name: user.FullName, Code: otp.OTPCode, ExpiresIn: minutesUntil(otp.ExpiresAt)}
+	var link models.ActionToken
+	err := db.Where("id = ? AND used_at IS NULL AND expires_at > ?", job.LinkID, time.Now()).First(&link).Error
+	switch {
+	case err == nil:
+		if data.Link, err = actionLinkURL(&link); err != nil {
+			return err
+		}
+		data.LinkExpiresIn = minutesUntil(link.ExpiresAt)
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+
+	template := mailer.TemplateOTP
+	if otp.Purpose == PurposeResetPassword {
+		template = mailer.TemplatePasswordReset
+	}
+	msg, err := mailer.Render(template, user.Email, data)
+	if err != nil {
+		return err
+	}
+	return mailer.Default().Send(msg)
+}
+
+// minutesUntil rounds up so a code never claims less time than it has
+func minutesUntil(t time.Time) int {
+	return int(math.Ceil(time.Until(t).Minutes()))
 }
 
 func VerifyOTPService(db *gorm.DB, email, otpCode string) error {
-	var user models.User
-	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
-		return errors.New("user not found")
+	user, err := findAccount(db, email)
+	if err != nil {
+		return err
 	}
 
+	// only email verification codes may verify an email
 	var otp models.OTP
-	if err := db.Where("user_id = ? AND otp_code = ? AND is_used = ?", user.ID, otpCode, false).First(&otp).Error; err != nil {
-		return errors.New("invalid otp")
+	if err := db.Where("user_id = ? AND otp_code = ? AND purpose = ? AND is_used = ?", user.ID, otpCode, PurposeSignup, false).
+		First(&otp).Error; err != nil {
+		return ErrInvalidOTP
 	}
 
 	if time.Now().After(otp.ExpiresAt) {
-		return errors.New("otp expired")
+		return ErrOTPExpired
 	}
 
-	otp.IsUsed = true
-	db.Save(&otp)
+	return db.Transaction(func(tx *gorm.DB) error {
+		otp.IsUsed = true
+		if err := tx.Save(&otp).Error; err != nil {
+			return err
+		}
+		user.IsVerified = true
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if err := revokeActionLinks(tx, user.ID, PurposeSignup); err != nil {
+			return err
+		}
+		return claimGuestOrders(tx, user)
+	})
+}
 
-	user.IsVerified = true
-	db.Save(&user)
+func ResendOTPService(db *gorm.DB, email string) error {
+	user, err := findAccount(db, email)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return SendOTPService(db, user.ID, PurposeSignup)
 }
 
-func ResendOTPService(db *gorm.DB, email string) error {
+// ---------- Account lookup ----------
+
+// normalizeEmail is the form emails are stored and compared in
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// findAccount finds the account registered with email, ignoring case.
+// Guest checkout records are not accounts and never match.
+func findAccount(db *gorm.DB, email string) (models.User, error) {
 	var user models.User
-	if err := db.Where("email = ?", email).First(&user).Error; err != nil {
-		return errors.New("user not found")
+	err := db.Where("LOWER(email) = ? AND role <> ?", normalizeEmail(email), RoleGuest).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, ErrUserNotFound
+	}
+	if err != nil {
+		return user, Internal("Failed to find user", err)
+	}
+	return user, nil
+}
+
+// claimGuestOrders moves the orders of the guest checkout record with the
+// user's email onto the account and removes the record. Call it only once
+// the user has proven they own the email.
+func claimGuestOrders(tx *gorm.DB, user models.User) error {
+	var guest models.User
+	err := tx.Where("LOWER(email) = ? AND role = ?", normalizeEmail(user.Email), RoleGuest).First(&guest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
-	return SendOTPService(db, user.ID, user.Email, "signup")
-}
\ No newline at end of file
+	if err := tx.Unscoped().Model(&models.Order{}).Where("user_id = ?", guest.ID).Update("user_id", user.ID).Error; err != nil {
+		return err
+	}
+	// lines an unpaid guest checkout left behind
+	if err := tx.Where("user_id = ?", guest.ID).Delete(&models.CartItem{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&guest).Error
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"e-commerce/models"
+	"e-commerce/money"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CartLineFlags explain why a cart line cannot be ordered as it stands
+type CartLineFlags struct {
+	PriceChanged        bool `json:"price_changed"`
+	OutOfStock          bool `json:"out_of_stock"`
+	ReducedAvailability bool `json:"reduced_availability"`
+	ProductDeleted      bool `json:"product_deleted"`
+}
+
+// ValidatedCartLine is a cart item checked against the live product
+type ValidatedCartLine struct {
+	Item      models.CartItem
+	Flags     CartLineFlags
+	Available int
+	LineTotal money.Money
+}
+
+// CartTotals are in the store currency, which all base prices use
+type CartTotals struct {
+	Lines    int         `json:"lines"`
+	Quantity int         `json:"quantity"`
+	Subtotal money.Money `json:"subtotal"`
+	// difference between the subtotal at current and at added-time prices
+	PriceDifference money.Money `json:"price_difference"`
+}
+
+// ValidatedCart is the user's cart with per-line flags. Totals only count
+// lines that can be ordered, at current prices; saved-for-later lines are
+// flagged too but never counted or ordered.
+type ValidatedCart struct {
+	Lines         []ValidatedCartLine
+	SavedForLater []ValidatedCartLine
+	Totals        CartTotals
+	PriceChanged  bool
+	Unavailable   bool
+	// Version fingerprints the orderable lines as shown; checkout compares
+	// it with the version the client reviewed
+	Version string
+}
+
+var (
+	ErrCartUnavailable = Conflict("cart_unavailable", "Some items are no longer available, please review your cart")
+	ErrPricesChanged   = Conflict("prices_changed", "Prices have changed, please review your cart and confirm its version")
+	ErrCartChanged     = Conflict("cart_changed", "Your cart has changed since you reviewed it, please review it again")
+)
+
+// CartStaleError refuses a checkout and carries the cart that caused it
+type CartStaleError struct {
+	Err  *Error
+	Cart *ValidatedCart
+}
+
+func (e *CartStaleError) Error() string { return e.Err.Error() }
+
+func (e *CartStaleError) Unwrap() error { return e.Err }
+
+// ValidateCart loads the user's cart and flags price changes, stock
+// problems and deleted products
+func ValidateCart(db *gorm.DB, userID uint) (*ValidatedCart, error) {
+	var items []models.CartItem
+	if err := db.Preload("Product", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Where("user_id = ?", userID).Order("created_at").Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	cart := &ValidatedCart{Lines: []ValidatedCartLine{}, SavedForLater: []ValidatedCartLine{}}
+	cart.Totals.Subtotal = money.Zero(money.DefaultCurrency())
+	cart.Totals.PriceDifference = money.Zero(money.DefaultCurrency())
+	var err error
+	var keys []cartLineKey
+	for _, item := range items {
+		line := validateCartLine(item)
+		if item.SavedForLater {
+			cart.SavedForLater = append(cart.SavedForLater, line)
+			continue
+		}
+
+		product := item.Product
+		keys = append(keys, cartLineKey{productID: item.ProductID, quantity: item.Quantity, price: product.Price})
+		if line.Flags.PriceChanged {
+			cart.PriceChanged = true
+		}
+
+		if line.Flags.ProductDeleted || line.Flags.OutOfStock || line.Flags.ReducedAvailability {
+			cart.Unavailable = true
+		} else {
+			line.LineTotal = product.Price.Times(item.Quantity)
+			cart.Totals.Lines++
+			cart.Totals.Quantity += item.Quantity
+			if cart.Totals.Subtotal, err = cart.Totals.Subtotal.Add(line.LineTotal); err != nil {
+				return nil, err
+			}
+			if line.Flags.PriceChanged {
+				diff, err := product.Price.Sub(item.PriceAtAdd)
+				if err != nil {
+					return nil, err
+				}
+				if cart.Totals.PriceDifference, err = cart.Totals.PriceDifference.Add(diff.Times(item.Quantity)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		cart.Lines = append(cart.Lines, line)
+	}
+	cart.Version = cartVersion(keys)
+	return cart, nil
+}
+
+// cartLineKey is what a cart version covers for one line
+type cartLineKey struct {
+	productID uint
+	quantity  int
+	price     money.Money
+}
+
+// cartVersion hashes products, quantities and current prices, so any change
+// to what the customer would pay gives a new version
+func cartVersion(keys []cartLineKey) string {
+	sort.Slice(keys, func(i, j int) bool { return keys[i].productID < keys[j].productID })
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%d:%d:%d:%s;", k.productID, k.quantity, k.price.Amount, k.price.Currency)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func validateCartLine(item models.CartItem) ValidatedCartLine {
+	line := ValidatedCartLine{Item: item, Available: item.Product.StockQuantity}
+	product := item.Product
+
+	switch {
+	case product.ID == 0 || product.DeletedAt.Valid:
+		line.Flags.ProductDeleted = true
+		line.Available = 0
+	case product.StockQuantity <= 0:
+		line.Flags.OutOfStock = true
+	case product.StockQuantity < item.Quantity:
+		line.Flags.ReducedAvailability = true
+	}
+	if !line.Flags.ProductDeleted && !item.PriceAtAdd.IsZero() && item.PriceAtAdd != product.Price {
+		line.Flags.PriceChanged = true
+	}
+	return line
+}
+
+// CheckoutError reports whether the cart may be ordered. Unavailable lines
+// always block. version is the cart version the client reviewed: when given
+// it must still match, and price changes block until the client sends one.
+func (cart *ValidatedCart) CheckoutError(version string) error {
+	switch {
+	case cart.Unavailable:
+		return &CartStaleError{Err: ErrCartUnavailable, Cart: cart}
+	case version != "" && version != cart.Version:
+		return &CartStaleError{Err: ErrCartChanged, Cart: cart}
+	case version == "" && cart.PriceChanged:
+		return &CartStaleError{Err: ErrPricesChanged, Cart: cart}
+	}
+	return nil
+}
+
+// ---------- Cart changes ----------
+
+var (
+	ErrCartItemNotFound = NotFound("cart_item_not_found", "Cart item not found")
+	ErrProductNotFound  = NotFound("product_not_found", "Product not found")
+	ErrCartEmpty        = Invalid("cart_empty", "Cart is empty")
+)
+
+// CartAddLine is one product to put in the cart
+type CartAddLine struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,min=1"`
+}
+
+// AddToCart upserts a cart line: a product already in the cart gets the
+// quantity added, one saved for later comes back into the cart. created
+// reports whether a new line was inserted.
+func AddToCart(db *gorm.DB, userID, productID uint, quantity int) (item *models.CartItem, created bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.First(&product, productID).Error; err != nil {
+			return ErrProductNotFound
+		}
+
+		line := models.CartItem{UserID: userID, ProductID: productID, Quantity: quantity, PriceAtAdd: product.Price}
+
+		// a line saved for later moves back with its quantity and the price
+		// it was added at, so a change since then is still flagged
+		var saved []models.CartItem
+		if err := tx.Clauses(clause.Returning{}).
+			Where("user_id = ? AND product_id = ? AND saved_for_later", userID, productID).
+			Delete(&saved).Error; err != nil {
+			return err
+		}
+		for _, s := range saved {
+			line.Quantity += s.Quantity
+			if !s.PriceAtAdd.IsZero() {
+				line.PriceAtAdd = s.PriceAtAdd
+			}
+		}
+		added := line.Quantity
+
+		// concurrent adds of the same product land on one line
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}, {Name: "product_id"}, {Name: "saved_for_later"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"quantity":   gorm.Expr("cart_items.quantity + EXCLUDED.quantity"),
+				"updated_at": gorm.Expr("EXCLUDED.updated_at"),
+			}),
+		}, clause.Returning{}).Create(&line).Error; err != nil {
+			return err
+		}
+		if line.Quantity > product.StockQuantity {
+			return ErrNotEnoughStock
+		}
+		created = line.Quantity == added && len(saved) == 0
+
+		line.Product = product
+		item = &line
+		return nil
+	})
+	return item, created, err
+}
+
+// BulkAddToCart adds several products at once; any failure adds none
+func BulkAddToCart(db *gorm.DB, userID uint, lines []CartAddLine) ([]models.CartItem, error) {
+	items := make([]models.CartItem, 0, len(lines))
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, l := range lines {
+			item, _, err := AddToCart(tx, userID, l.ProductID, l.Quantity)
+			if err != nil {
+				return fmt.Errorf("product %d: %w", l.ProductID, err)
+			}
+			items = append(items, *item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// RemoveCartItems deletes the given lines of the user's cart
+func RemoveCartItems(db *gorm.DB, userID uint, itemIDs []uint) (int64, error) {
+	result := db.Where("user_id = ? AND id IN ?", userID, itemIDs).Delete(&models.CartItem{})
+	return result.RowsAffected, result.Error
+}
+
+// SetSavedForLater moves a line between the cart and its saved-for-later section
+func SetSavedForLater(db *gorm.DB, userID, itemID uint, saved bool) (*models.CartItem, error) {
+	var item models.CartItem
+	if err := db.Preload("Product").Where("id = ? AND user_id = ?", itemID, userID).First(&item).Error; err != nil {
+		return nil, ErrCartItemNotFound
+	}
+	item.SavedForLater = saved
+	if err := db.Model(&item).Update("saved_for_later", saved).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ---------- Cart <-> wishlist ----------
+
+// MoveCartItemToWishlist removes a cart line and puts its product on the
+// user's default wishlist in one transaction; a product already on the list
+// just leaves the cart
+func MoveCartItemToWishlist(db *gorm.DB, userID, itemID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var item models.CartItem
+		if err := tx.Preload("Product").Where("id = ? AND user_id = ?", itemID, userID).First(&item).Error; err != nil {
+			return ErrCartItemNotFound
+		}
+		list, err := DefaultWishlist(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		wish := models.WishlistItem{
+			WishlistID:    list.ID,
+			UserID:        userID,
+			ProductID:     item.ProductID,
+			PriceAtAdd:    item.Product.Price,
+			WasOutOfStock: item.Product.StockQuantity <= 0,
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&wish).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&item).Error
+	})
+}
+
+// MoveWishlistItemToCart takes a product off a wishlist and adds it to the
+// cart in one transaction
+func MoveWishlistItemToCart(db *gorm.DB, userID, wishlistID, productID uint, quantity int) (*models.CartItem, error) {
+	var item *models.CartItem
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := RemoveWishlistItem(tx, userID, wishlistID, productID); err != nil {
+			return err
+		}
+
+		var err error
+		item, _, err = AddToCart(tx, userID, productID, quantity)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"e-commerce/money"
+)
+
+func TestCartVersionIgnoresLineOrder(t *testing.T) {
+	a := cartLineKey{productID: 1, quantity: 2, price: money.Money{Amount: 1000, Currency: "USD"}}
+	b := cartLineKey{productID: 2, quantity: 1, price: money.Money{Amount: 500, Currency: "USD"}}
+	if cartVersion([]cartLineKey{a, b}) != cartVersion([]cartLineKey{b, a}) {
+		t.Error("version depends on line order")
+	}
+}
+
+func TestCartVersionChanges(t *testing.T) {
+	base := cartLineKey{productID: 1, quantity: 2, price: money.Money{Amount: 1000, Currency: "USD"}}
+	version := cartVersion([]cartLineKey{base})
+
+	changes := map[string]cartLineKey{
+		"price":    {productID: 1, quantity: 2, price: money.Money{Amount: 1100, Currency: "USD"}},
+		"currency": {productID: 1, quantity: 2, price: money.Money{Amount: 1000, Currency: "EUR"}},
+		"quantity": {productID: 1, quantity: 3, price: money.Money{Amount: 1000, Currency: "USD"}},
+		"product":  {productID: 2, quantity: 2, price: money.Money{Amount: 1000, Currency: "USD"}},
+	}
+	for name, key := range changes {
+		if cartVersion([]cartLineKey{key}) == version {
+			t.Errorf("%s change kept the version", name)
+		}
+	}
+	if cartVersion([]cartLineKey{base, changes["product"]}) == version {
+		t.Error("added line kept the version")
+	}
+}
+
+func TestCheckoutError(t *testing.T) {
+	tests := []struct {
+		name    string
+		cart    ValidatedCart
+		version string
+		want    *Error
+	}{
+		{"unchanged without version", ValidatedCart{Version: "v1"}, "", nil},
+		{"reviewed version", ValidatedCart{Version: "v1", PriceChanged: true}, "v1", nil},
+		{"price changed without version", ValidatedCart{Version: "v1", PriceChanged: true}, "", ErrPricesChanged},
+		{"stale version", ValidatedCart{Version: "v2"}, "v1", ErrCartChanged},
+		{"stale version with price change", ValidatedCart{Version: "v2", PriceChanged: true}, "v1", ErrCartChanged},
+		{"unavailable", ValidatedCart{Version: "v1", Unavailable: true}, "v1", ErrCartUnavailable},
+	}
+	for _, tt := range tests {
+		err := tt.cart.CheckoutError(tt.version)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected %v", tt.name, err)
+			}
+			continue
+		}
+		var stale *CartStaleError
+		if !errors.As(err, &stale) || !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import "errors"
+
+// Kind classifies an Error; the HTTP layer maps each kind to one status code
+type Kind int
+
+const (
+	KindInternal        Kind = iota // 500
+	KindBadRequest                  // 400, malformed request
+	KindUnauthenticated             // 401
+	KindForbidden                   // 403
+	KindNotFound                    // 404
+	KindConflict                    // 409, clashes with current state
+	KindValidation                  // 422, well-formed but not acceptable
+	KindUpstream                    // 502, a provider we depend on failed
+)
+
+// FieldError explains why one input field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is a domain error with a stable, machine-readable code. Message is
+// safe to show to clients; Err keeps the underlying cause for logs.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Details interface{}
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is matches on Code, so copies made by Wrap or WithDetails still match
+// their sentinel with errors.Is
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of e that records cause
+func (e *Error) Wrap(cause error) *Error {
+	cp := *e
+	cp.Err = cause
+	return &cp
+}
+
+// WithDetails returns a copy of e carrying extra data for the client
+func (e *Error) WithDetails(details interface{}) *Error {
+	cp := *e
+	cp.Details = details
+	return &cp
+}
+
+func newError(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func BadRequest(code, message string) *Error   { return newError(KindBadRequest, code, message) }
+func NotFound(code, message string) *Error     { return newError(KindNotFound, code, message) }
+func Conflict(code, message string) *Error     { return newError(KindConflict, code, message) }
+func Forbidden(code, message string) *Error    { return newError(KindForbidden, code, message) }
+func Unauthorized(code, message string) *Error { return newError(KindUnauthenticated, code, message) }
+func Upstream(code, message string) *Error     { return newError(KindUpstream, code, message) }
+
+// Invalid rejects input, optionally pointing at the offending fields
+func Invalid(code, message string, fields ...FieldError) *Error {
+	e := newError(KindValidation, code, message)
+	e.Fields = fields
+	return e
+}
+
+// Internal hides cause from clients behind a generic message
+func Internal(message string, cause error) *Error {
+	e := newError(KindInternal, "internal_error", message)
+	e.Err = cause
+	return e
+}
+
+// AsError returns err as an *Error, treating anything untyped as internal
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal("Something went wrong", err)
+}
+
+// ---------- Shared errors ----------
+
+var (
+	ErrUnauthenticated = Unauthorized("unauthenticated", "Missing access token")
+	ErrSessionExpired  = Unauthorized("session_expired", "Please login again")
+	ErrForbidden       = Forbidden("forbidden", "You do not have access to this resource")
+	ErrInvalidID       = BadRequest("invalid_id", "Invalid ID")
+	ErrNotFound        = NotFound("not_found", "Resource not found")
+)
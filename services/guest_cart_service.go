@@ -0,0 +1,325 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"e-commerce/models"
+	"e-commerce/money"
+	"e-commerce/utils"
+
+	"gorm.io/gorm"
+)
+
+// GuestCartTTL is how long an untouched guest cart is kept
+const GuestCartTTL = 30 * 24 * time.Hour
+
+// RoleGuest marks records created by guest checkout; an account that
+// verifies the same email takes their orders over (claimGuestOrders)
+const RoleGuest = "guest"
+
+var (
+	ErrGuestCartNotFound  = NotFound("cart_not_found", "Cart not found")
+	ErrNotEnoughStock     = Conflict("insufficient_stock", "Not enough stock available")
+	ErrGuestAccountExists = Conflict("account_exists", "An account exists for this email, please log in to check out")
+)
+
+// ---------- Guest cart ----------
+
+// CreateGuestCart starts a new anonymous cart
+func CreateGuestCart(db *gorm.DB) (*models.GuestCart, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	cart := models.GuestCart{Token: token, ExpiresAt: time.Now().Add(GuestCartTTL)}
+	if err := db.Create(&cart).Error; err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// GetGuestCart loads an unexpired guest cart with its items
+func GetGuestCart(db *gorm.DB, token string) (*models.GuestCart, error) {
+	if token == "" {
+		return nil, ErrGuestCartNotFound
+	}
+	var cart models.GuestCart
+	err := db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		Preload("Items.Product").
+		Where("token = ? AND expires_at > ?", token, time.Now()).
+		First(&cart).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrGuestCartNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// GuestCartVersion is the cart version of a guest cart, matching the
+// version of the order cart it becomes at checkout
+func GuestCartVersion(cart *models.GuestCart) string {
+	var keys []cartLineKey
+	for _, item := range cart.Items {
+		keys = append(keys, cartLineKey{productID: item.ProductID, quantity: item.Quantity, price: item.Product.Price})
+	}
+	return cartVersion(keys)
+}
+
+// AddGuestCartItem adds a product, or raises the quantity when it is already in the cart
+func AddGuestCartItem(db *gorm.DB, cart *models.GuestCart, productID uint, quantity int) (*models.GuestCartItem, error) {
+	var product models.Product
+	if err := db.First(&product, productID).Error; err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	var item models.GuestCartItem
+	err := db.Where("guest_cart_id = ? AND product_id = ?", cart.ID, productID).First(&item).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if item.Quantity+quantity > product.StockQuantity {
+		return nil, ErrNotEnoughStock
+	}
+
+	item.GuestCartID = cart.ID
+	item.ProductID = productID
+	item.Quantity += quantity
+	if item.PriceAtAdd.IsZero() {
+		item.PriceAtAdd = product.Price
+	}
+	if err := db.Save(&item).Error; err != nil {
+		return nil, err
+	}
+	touchGuestCart(db, cart)
+
+	item.Product = product
+	return &item, nil
+}
+
+// UpdateGuestCartItem sets the quantity of one line
+func UpdateGuestCartItem(db *gorm.DB, cart *models.GuestCart, itemID uint, quantity int) (*models.GuestCartItem, error) {
+	var item models.GuestCartItem
+	if err := db.Preload("Product").Where("id = ? AND guest_cart_id = ?", itemID, cart.ID).First(&item).Error; err != nil {
+		return nil, ErrCartItemNotFound
+	}
+	if quantity > item.Product.StockQuantity {
+		return nil, ErrNotEnoughStock
+	}
+
+	item.Quantity = quantity
+	if err := db.Save(&item).Error; err != nil {
+		return nil, err
+	}
+	touchGuestCart(db, cart)
+	return &item, nil
+}
+
+// RemoveGuestCartItem deletes one line
+func RemoveGuestCartItem(db *gorm.DB, cart *models.GuestCart, itemID uint) error {
+	result := db.Where("id = ? AND guest_cart_id = ?", itemID, cart.ID).Delete(&models.GuestCartItem{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCartItemNotFound
+	}
+	touchGuestCart(db, cart)
+	return nil
+}
+
+// touchGuestCart slides the expiry forward on every change
+func touchGuestCart(db *gorm.DB, cart *models.GuestCart) {
+	cart.ExpiresAt = time.Now().Add(GuestCartTTL)
+	db.Model(cart).Update("expires_at", cart.ExpiresAt)
+}
+
+// ---------- Merge ----------
+
+// MergeGuestCart moves a guest cart into the user's cart. Quantities of
+// products already in the user's cart are summed and clamped to stock;
+// the guest cart is deleted afterwards. A missing or expired cart is a no-op.
+func MergeGuestCart(db *gorm.DB, token string, userID uint) error {
+	cart, err := GetGuestCart(db, token)
+	if errors.Is(err, ErrGuestCartNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range cart.Items {
+			var lines []models.CartItem
+			if err := tx.Where("user_id = ? AND product_id = ?", userID, item.ProductID).Find(&lines).Error; err != nil {
+				return err
+			}
+			var active, saved models.CartItem
+			for _, l := range lines {
+				if l.SavedForLater {
+					saved = l
+				} else {
+					active = l
+				}
+			}
+
+			line, ok := mergeGuestLine(userID, active, saved, item)
+			if !ok {
+				continue
+			}
+			if active.ID != 0 && saved.ID != 0 {
+				if err := tx.Delete(&saved).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Save(&line).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("guest_cart_id = ?", cart.ID).Delete(&models.GuestCartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(cart).Error
+	})
+}
+
+// mergeGuestLine folds a guest cart item into the user's active and saved
+// lines for its product (either may be empty). The result is the active
+// line to save, with quantities summed and clamped to stock; a saved line
+// comes back into the cart and, when there is an active line too, is
+// folded into it and must be deleted. ok is false when the guest item adds
+// nothing and the user's lines stay as they are.
+func mergeGuestLine(userID uint, active, saved models.CartItem, item models.GuestCartItem) (line models.CartItem, ok bool) {
+	have := active.Quantity + saved.Quantity
+	quantity := have + item.Quantity
+	if quantity > item.Product.StockQuantity {
+		quantity = item.Product.StockQuantity
+	}
+	if quantity <= have {
+		// out of stock or nothing to add
+		return line, false
+	}
+
+	line = active
+	if line.ID == 0 {
+		line = saved
+	}
+	line.SavedForLater = false
+	line.UserID = userID
+	line.ProductID = item.ProductID
+	line.Quantity = quantity
+	// keep the user's price, else the guest's, so a change since is still flagged
+	for _, price := range []money.Money{active.PriceAtAdd, saved.PriceAtAdd, item.PriceAtAdd} {
+		if line.PriceAtAdd = price; !price.IsZero() {
+			break
+		}
+	}
+	return line, true
+}
+
+// mergeGuestCartOnLogin merges after a successful sign in; a failed merge
+// must not block the login itself
+func mergeGuestCartOnLogin(db *gorm.DB, token string, userID uint) {
+	if token == "" {
+		return
+	}
+	if err := MergeGuestCart(db, token, userID); err != nil {
+		log.Printf("❌ merge guest cart for user %d: %v", userID, err)
+	}
+}
+
+// ---------- Guest checkout ----------
+
+// GuestCheckout places an order for an anonymous visitor. The order belongs
+// to a "guest" record keyed by email; an account that verifies the same
+// email later takes the guest's orders over.
+func GuestCheckout(db *gorm.DB, token, email, fullName, address, currency, cartVersion string) (*OrderResponse, error) {
+	email = normalizeEmail(email)
+
+	cart, err := GetGuestCart(db, token)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, ErrCartEmpty
+	}
+
+	var order *OrderResponse
+	err = db.Transaction(func(tx *gorm.DB) error {
+		user, err := findOrCreateGuestUser(tx, email, fullName, address)
+		if err != nil {
+			return err
+		}
+
+		// an earlier unpaid guest checkout may have left lines behind
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		if err := MergeGuestCart(tx, token, user.ID); err != nil {
+			return err
+		}
+
+		order, err = CreateOrder(tx, user.ID, address, currency, cartVersion)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// findOrCreateGuestUser returns the guest record for email. Verified
+// accounts must log in instead; an unverified signup does not block guest
+// checkout, it claims the guest's orders once it verifies the email.
+func findOrCreateGuestUser(tx *gorm.DB, email, fullName, address string) (*models.User, error) {
+	account, err := findAccount(tx, email)
+	if err == nil && account.IsVerified {
+		return nil, ErrGuestAccountExists
+	}
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	}
+
+	var user models.User
+	err = tx.Where("LOWER(email) = ? AND role = ?", email, RoleGuest).First(&user).Error
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// guests cannot sign in
+	random, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashed, err := utils.HashPassword(random)
+	if err != nil {
+		return nil, Internal("Failed to hash password", err)
+	}
+	if fullName == "" {
+		fullName = strings.Split(email, "@")[0]
+	}
+	user = models.User{
+		FullName:     fullName,
+		Email:        email,
+		PasswordHash: hashed,
+		Role:         RoleGuest,
+		Address:      address,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ---------- Cleanup ----------
+func handleCleanupGuestCarts(db *gorm.DB, payload []byte) error {
+	return db.Where("expires_at < ?", time.Now()).Delete(&models.GuestCart{}).Error
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"testing"
+
+	"e-commerce/models"
+	"e-commerce/money"
+)
+
+func guestItem(quantity, stock int) models.GuestCartItem {
+	return models.GuestCartItem{
+		ProductID:  7,
+		Quantity:   quantity,
+		PriceAtAdd: money.Money{Amount: 900, Currency: "USD"},
+		Product:    models.Product{ID: 7, StockQuantity: stock},
+	}
+}
+
+func TestMergeGuestLineNew(t *testing.T) {
+	line, ok := mergeGuestLine(1, models.CartItem{}, models.CartItem{}, guestItem(2, 10))
+	if !ok || line.ID != 0 || line.UserID != 1 || line.ProductID != 7 || line.Quantity != 2 || line.SavedForLater {
+		t.Fatalf("line = %+v, ok = %v", line, ok)
+	}
+	if line.PriceAtAdd.Amount != 900 {
+		t.Errorf("price = %v, want the guest's", line.PriceAtAdd)
+	}
+}
+
+func TestMergeGuestLineFoldsSavedIntoActive(t *testing.T) {
+	active := models.CartItem{ID: 10, UserID: 1, ProductID: 7, Quantity: 1, PriceAtAdd: money.Money{Amount: 1000, Currency: "USD"}}
+	saved := models.CartItem{ID: 11, UserID: 1, ProductID: 7, Quantity: 2, SavedForLater: true, PriceAtAdd: money.Money{Amount: 800, Currency: "USD"}}
+
+	line, ok := mergeGuestLine(1, active, saved, guestItem(3, 10))
+	if !ok {
+		t.Fatal("nothing merged")
+	}
+	// the active line is kept, so the saved one is the one to delete and
+	// (user, product, saved_for_later) stays unique
+	if line.ID != active.ID || line.SavedForLater {
+		t.Errorf("line = %+v, want the active line", line)
+	}
+	if line.Quantity != 6 {
+		t.Errorf("quantity = %d, want 6", line.Quantity)
+	}
+	if line.PriceAtAdd.Amount != 1000 {
+		t.Errorf("price = %v, want the active line's", line.PriceAtAdd)
+	}
+}
+
+func TestMergeGuestLineRestoresSaved(t *testing.T) {
+	saved := models.CartItem{ID: 11, UserID: 1, ProductID: 7, Quantity: 2, SavedForLater: true, PriceAtAdd: money.Money{Amount: 800, Currency: "USD"}}
+
+	line, ok := mergeGuestLine(1, models.CartItem{}, saved, guestItem(1, 10))
+	if !ok || line.ID != saved.ID || line.SavedForLater || line.Quantity != 3 || line.PriceAtAdd.Amount != 800 {
+		t.Errorf("line = %+v, ok = %v", line, ok)
+	}
+}
+
+func TestMergeGuestLineClampsToStock(t *testing.T) {
+	active := models.CartItem{ID: 10, UserID: 1, ProductID: 7, Quantity: 2}
+	saved := models.CartItem{ID: 11, UserID: 1, ProductID: 7, Quantity: 1, SavedForLater: true}
+
+	line, ok := mergeGuestLine(1, active, saved, guestItem(5, 4))
+	if !ok || line.Quantity != 4 {
+		t.Errorf("line = %+v, ok = %v; want quantity 4", line, ok)
+	}
+	if _, ok := mergeGuestLine(1, active, saved, guestItem(5, 3)); ok {
+		t.Error("merged with no stock left to add")
+	}
+}
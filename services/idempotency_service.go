@@ -0,0 +1,102 @@
+package services
+
+import (
+	"time"
+
+	"e-commerce/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyTTL is how long a stored response can be replayed
+const IdempotencyTTL = 24 * time.Hour
+
+// IdempotencyLease is how long a claimed key waits for its request to
+// finish before a retry may claim it again
+const IdempotencyLease = 5 * time.Minute
+
+// JobCleanupIdempotencyKeys purges expired keys
+const JobCleanupIdempotencyKeys = "idempotency_keys.cleanup"
+
+var (
+	ErrIdempotencyKeyInvalid = BadRequest("invalid_idempotency_key", "Idempotency-Key must be 1 to 255 visible ASCII characters")
+	ErrIdempotencyKeyReused  = Invalid("idempotency_key_reused", "Idempotency-Key was already used for a different request")
+	ErrIdempotencyInProgress = Conflict("idempotency_in_progress", "A request with this Idempotency-Key is still being processed")
+)
+
+// BeginIdempotentRequest claims key within scope for a request with the given
+// fingerprint. A fresh claim returns the new record with replay false; the
+// caller completes or releases it. A key seen before returns its stored
+// record with replay true, or an error when the request differs or the
+// original is still running. An unfinished key whose lease ran out is
+// claimed again.
+func BeginIdempotentRequest(db *gorm.DB, scope, key, fingerprint string) (*models.IdempotencyKey, bool, error) {
+	now := time.Now()
+
+	// an expired key is free to be used again
+	if err := db.Where("scope = ? AND key = ? AND expires_at <= ?", scope, key, now).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	rec := models.IdempotencyKey{
+		Scope:       scope,
+		Key:         key,
+		Fingerprint: fingerprint,
+		LockedUntil: now.Add(IdempotencyLease),
+		ExpiresAt:   now.Add(IdempotencyTTL),
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rec)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return &rec, false, nil
+	}
+
+	// the request that claimed the key died before finishing
+	result = db.Model(&models.IdempotencyKey{}).
+		Where("scope = ? AND key = ? AND fingerprint = ? AND completed_at IS NULL AND locked_until <= ?", scope, key, fingerprint, now).
+		Update("locked_until", now.Add(IdempotencyLease))
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	reclaimed := result.RowsAffected == 1
+
+	var existing models.IdempotencyKey
+	if err := db.Where("scope = ? AND key = ?", scope, key).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	if reclaimed {
+		return &existing, false, nil
+	}
+	if existing.Fingerprint != fingerprint {
+		return nil, false, ErrIdempotencyKeyReused
+	}
+	if existing.CompletedAt == nil {
+		return nil, false, ErrIdempotencyInProgress
+	}
+	return &existing, true, nil
+}
+
+// CompleteIdempotentRequest stores the response to replay for rec's key
+func CompleteIdempotentRequest(db *gorm.DB, rec *models.IdempotencyKey, status int, contentType, body string) error {
+	now := time.Now()
+	return db.Model(rec).Updates(map[string]interface{}{
+		"status_code":   status,
+		"content_type":  contentType,
+		"response_body": body,
+		"completed_at":  &now,
+	}).Error
+}
+
+// ReleaseIdempotentRequest forgets rec's key so the request can be retried,
+// e.g. after a server error
+func ReleaseIdempotentRequest(db *gorm.DB, rec *models.IdempotencyKey) error {
+	return db.Delete(rec).Error
+}
+
+func handleCleanupIdempotencyKeys(db *gorm.DB, payload []byte) error {
+	return db.Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyKey{}).Error
+}
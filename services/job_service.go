@@ -0,0 +1,127 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"e-commerce/jobs"
+	"e-commerce/mailer"
+	"e-commerce/models"
+	"e-commerce/utils"
+
+	"gorm.io/gorm"
+)
+
+// Job types
+const (
+	JobSendEmail            = "email.send"
+	JobSendOTP              = "otp.send"
+	JobClearCart            = "cart.clear"
+	JobCleanupRefreshTokens = "refresh_tokens.cleanup"
+	JobRotateSigningKeys    = "jwt.rotate_keys"
+	JobCleanupGuestCarts    = "guest_carts.cleanup"
+	JobWishlistAlerts       = "wishlist.alerts"
+	JobPurgeCompletedJobs   = "jobs.purge_completed"
+)
+
+type emailJob struct {
+	To       string          `json:"to"`
+	Template string          `json:"template"`
+	Data     json.RawMessage `json:"data"`
+}
+
+type clearCartJob struct {
+	UserID uint `json:"user_id"`
+}
+
+// RegisterJobs wires job handlers and recurring schedules; call before jobs.Start
+func RegisterJobs() {
+	jobs.Register(JobSendEmail, handleSendEmail)
+	jobs.Register(JobSendOTP, handleSendOTP)
+	jobs.Register(JobClearCart, handleClearCart)
+	jobs.Register(JobCleanupRefreshTokens, handleCleanupRefreshTokens)
+	jobs.Register(JobRotateSigningKeys, handleRotateSigningKeys)
+	jobs.Register(JobCleanupGuestCarts, handleCleanupGuestCarts)
+	jobs.Register(JobWishlistAlerts, handleWishlistAlerts)
+	jobs.Register(JobNotifyBackInStock, handleNotifyBackInStock)
+	jobs.Register(JobAbandonedCarts, handleAbandonedCarts)
+	jobs.Register(JobCleanupIdempotencyKeys, handleCleanupIdempotencyKeys)
+	jobs.Register(JobEraseAccounts, handleEraseAccounts)
+	jobs.Register(JobPurgeCompletedJobs, handlePurgeCompletedJobs)
+
+	schedule("refresh-token-cleanup", "0 * * * *", JobCleanupRefreshTokens)
+	schedule("jwt-key-rotation", "15 * * * *", JobRotateSigningKeys)
+	schedule("guest-cart-cleanup", "30 4 * * *", JobCleanupGuestCarts)
+	schedule("wishlist-alerts", "40 * * * *", JobWishlistAlerts)
+	schedule("abandoned-carts", "*/30 * * * *", JobAbandonedCarts)
+	schedule("idempotency-key-cleanup", "45 * * * *", JobCleanupIdempotencyKeys)
+	schedule("account-erasure", "50 * * * *", JobEraseAccounts)
+	schedule("completed-job-purge", "20 3 * * *", JobPurgeCompletedJobs)
+}
+
+func schedule(name, spec, jobType string) {
+	if err := jobs.Schedule(name, spec, jobType); err != nil {
+		log.Println("❌", err)
+	}
+}
+
+// ---------- Enqueue helpers ----------
+
+// EnqueueEmail queues a templated email in the caller's transaction
+func EnqueueEmail(tx *gorm.DB, to, template string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return jobs.Enqueue(tx, JobSendEmail, emailJob{To: to, Template: template, Data: raw})
+}
+
+// EnqueueClearCart empties the user's cart once the surrounding transaction commits
+func EnqueueClearCart(tx *gorm.DB, userID uint) error {
+	return jobs.Enqueue(tx, JobClearCart, clearCartJob{UserID: userID})
+}
+
+// ---------- Handlers ----------
+func handleSendEmail(db *gorm.DB, payload []byte) error {
+	var job emailJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	msg, err := mailer.RenderJSON(job.Template, job.To, job.Data)
+	if err != nil {
+		return err
+	}
+	// delivered synchronously: the job runner owns retries
+	return mailer.Default().Send(msg)
+}
+
+func handleClearCart(db *gorm.DB, payload []byte) error {
+	var job clearCartJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	// saved-for-later lines stay for the next order
+	return db.Where("user_id = ? AND saved_for_later = ?", job.UserID, false).Delete(&models.CartItem{}).Error
+}
+
+func handleCleanupRefreshTokens(db *gorm.DB, payload []byte) error {
+	return db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.RefreshToken{}).Error
+}
+
+// completed jobs are kept this long for the admin view
+// (JOB_RETENTION as a Go duration, default 168h = 7 days)
+func handlePurgeCompletedJobs(db *gorm.DB, payload []byte) error {
+	purged, err := jobs.PurgeCompleted(db, time.Now().Add(-envDuration("JOB_RETENTION", 7*24*time.Hour)))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("✅ purged %d completed jobs", purged)
+	}
+	return nil
+}
+
+func handleRotateSigningKeys(db *gorm.DB, payload []byte) error {
+	return utils.RotateSigningKeys(db)
+}
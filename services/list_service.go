@@ -0,0 +1,360 @@
+package services
+
+import (
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"e-commerce/models"
+	"e-commerce/money"
+	"gorm.io/gorm"
+)
+
+// ---------- Admin lists ----------
+//
+// The admin list pages and their JSON twins share one query string:
+//
+//	?q=ann&page=2&per_page=50&sort=-created_at&blocked=false&from=2026-01-01
+//
+// q is free-text search, sort names a column (a leading "-" sorts
+// descending) and the rest are filters specific to each list.
+
+const (
+	DefaultPerPage = 25
+	MaxPerPage     = 200
+)
+
+// ListQuery is a parsed admin list query string
+type ListQuery struct {
+	Page    int
+	PerPage int
+	Search  string
+	Sort    string // "" for the list's default
+	Desc    bool
+	Values  url.Values // the whole query, for the list's filters
+}
+
+// Pagination describes the page a list returned
+type Pagination struct {
+	Page       int   `json:"page"`
+	PerPage    int   `json:"per_page"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// ParseListQuery reads paging, search and sort from a query string
+func ParseListQuery(values url.Values) (ListQuery, error) {
+	f := filters{values: values}
+	q := ListQuery{
+		Page:    f.int("page", 1, 1, math.MaxInt32),
+		PerPage: f.int("per_page", DefaultPerPage, 1, MaxPerPage),
+		Search:  strings.TrimSpace(values.Get("q")),
+		Values:  values,
+	}
+	q.Sort = values.Get("sort")
+	if strings.HasPrefix(q.Sort, "-") {
+		q.Sort, q.Desc = q.Sort[1:], true
+	}
+	return q, f.err()
+}
+
+// listSort maps a list's sort keys to columns
+type listSort struct {
+	columns     map[string]string
+	defaultKey  string
+	defaultDesc bool
+	tiebreak    string // unique column that keeps pages stable
+}
+
+func (s listSort) order(q ListQuery, f *filters) string {
+	key, desc := q.Sort, q.Desc
+	if key == "" {
+		key, desc = s.defaultKey, s.defaultDesc
+	}
+	column, ok := s.columns[key]
+	if !ok {
+		keys := make([]string, 0, len(s.columns))
+		for k := range s.columns {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		f.fail("sort", "must be one of: "+strings.Join(keys, ", ")+" (prefix - for descending)")
+		return ""
+	}
+	dir := " ASC"
+	if desc {
+		dir = " DESC"
+	}
+	order := column + dir
+	if column != s.tiebreak {
+		order += ", " + s.tiebreak + dir
+	}
+	return order
+}
+
+// paginate counts the filtered rows and loads the requested page into dest
+func paginate(query *gorm.DB, q ListQuery, order string, dest interface{}) (*Pagination, error) {
+	query = query.Session(&gorm.Session{})
+	page := Pagination{Page: q.Page, PerPage: q.PerPage}
+	if err := query.Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+	page.TotalPages = int((page.Total + int64(q.PerPage) - 1) / int64(q.PerPage))
+	if err := query.Order(order).Limit(q.PerPage).Offset((q.Page - 1) * q.PerPage).Find(dest).Error; err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// ---------- Users ----------
+
+var userSort = listSort{
+	columns: map[string]string{
+		"id": "id", "name": "full_name", "email": "email", "role": "role", "created_at": "created_at",
+	},
+	defaultKey: "id",
+	tiebreak:   "id",
+}
+
+// ListUsers searches name and email. Filters: role, blocked, verified and
+// from/to on the signup date. Guest checkout records are only listed when
+// asked for with role=guest.
+func ListUsers(db *gorm.DB, q ListQuery) ([]models.User, *Pagination, error) {
+	f := filters{values: q.Values}
+	query := db.Model(&models.User{})
+	if q.Search != "" {
+		like := likePattern(q.Search)
+		query = query.Where("full_name ILIKE ? OR email ILIKE ?", like, like)
+	}
+	if role := f.string("role"); role != "" {
+		query = query.Where("role = ?", role)
+	} else {
+		query = query.Where("role <> ?", RoleGuest)
+	}
+	if blocked := f.bool("blocked"); blocked != nil {
+		query = query.Where("is_blocked = ?", *blocked)
+	}
+	if verified := f.bool("verified"); verified != nil {
+		query = query.Where("is_verified = ?", *verified)
+	}
+	query = f.dateRange(query, "created_at")
+	order := userSort.order(q, &f)
+	if err := f.err(); err != nil {
+		return nil, nil, err
+	}
+
+	users := []models.User{}
+	page, err := paginate(query, q, order, &users)
+	if err != nil {
+		return nil, nil, err
+	}
+	return users, page, nil
+}
+
+// ---------- Products ----------
+
+var productSort = listSort{
+	columns: map[string]string{
+		"id": "id", "name": "name", "price": "price_amount", "stock": "stock_quantity",
+		"category": "category", "created_at": "created_at",
+	},
+	defaultKey: "id",
+	tiebreak:   "id",
+}
+
+// ListProducts searches name, description and category. Filters: category,
+// in_stock, min_price/max_price (decimal, store currency) and from/to.
+func ListProducts(db *gorm.DB, q ListQuery) ([]models.Product, *Pagination, error) {
+	f := filters{values: q.Values}
+	query := db.Model(&models.Product{})
+	if q.Search != "" {
+		like := likePattern(q.Search)
+		query = query.Where("name ILIKE ? OR description ILIKE ? OR category ILIKE ?", like, like, like)
+	}
+	if category := f.string("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if inStock := f.bool("in_stock"); inStock != nil {
+		if *inStock {
+			query = query.Where("stock_quantity > 0")
+		} else {
+			query = query.Where("stock_quantity <= 0")
+		}
+	}
+	currency := money.DefaultCurrency()
+	if min := f.amount("min_price", currency); min != nil {
+		query = query.Where("price_amount >= ?", *min)
+	}
+	if max := f.amount("max_price", currency); max != nil {
+		query = query.Where("price_amount <= ?", *max)
+	}
+	query = f.dateRange(query, "created_at")
+	order := productSort.order(q, &f)
+	if err := f.err(); err != nil {
+		return nil, nil, err
+	}
+
+	products := []models.Product{}
+	page, err := paginate(query.Preload("Prices"), q, order, &products)
+	if err != nil {
+		return nil, nil, err
+	}
+	return products, page, nil
+}
+
+// ---------- Orders ----------
+
+var orderSort = listSort{
+	columns: map[string]string{
+		"id": "orders.id", "total": "orders.total_amount", "status": "orders.status",
+		"customer": "users.full_name", "created_at": "orders.created_at",
+	},
+	defaultKey:  "created_at",
+	defaultDesc: true,
+	tiebreak:    "orders.id",
+}
+
+// ListOrders searches the order ID and the customer's name and email.
+// Filters: status, from/to on the order date, and min_total/max_total
+// (decimal) in currency, the store currency by default.
+func ListOrders(db *gorm.DB, q ListQuery) ([]models.Order, *Pagination, error) {
+	f := filters{values: q.Values}
+	query := db.Model(&models.Order{}).Joins("JOIN users ON users.id = orders.user_id")
+	if q.Search != "" {
+		like := likePattern(strings.TrimPrefix(q.Search, "#"))
+		if id, err := strconv.ParseUint(strings.TrimPrefix(q.Search, "#"), 10, 64); err == nil {
+			query = query.Where("orders.id = ? OR users.full_name ILIKE ? OR users.email ILIKE ?", id, like, like)
+		} else {
+			query = query.Where("users.full_name ILIKE ? OR users.email ILIKE ?", like, like)
+		}
+	}
+	if status := f.string("status"); status != "" && status != "all" {
+		query = query.Where("orders.status = ?", status)
+	}
+	query = f.dateRange(query, "orders.created_at")
+	if f.has("min_total") || f.has("max_total") || f.has("currency") {
+		currency := money.DefaultCurrency()
+		if code := f.string("currency"); code != "" {
+			if parsed, err := money.ParseCurrency(code); err != nil {
+				f.fail("currency", "is not a supported currency")
+			} else {
+				currency = parsed
+			}
+		}
+		query = query.Where("orders.total_currency = ?", currency)
+		if min := f.amount("min_total", currency); min != nil {
+			query = query.Where("orders.total_amount >= ?", *min)
+		}
+		if max := f.amount("max_total", currency); max != nil {
+			query = query.Where("orders.total_amount <= ?", *max)
+		}
+	}
+	order := orderSort.order(q, &f)
+	if err := f.err(); err != nil {
+		return nil, nil, err
+	}
+
+	orders := []models.Order{}
+	page, err := paginate(query.Preload("User").Preload("OrderItems.Product"), q, order, &orders)
+	if err != nil {
+		return nil, nil, err
+	}
+	return orders, page, nil
+}
+
+// ---------- helpers ----------
+
+// filters reads typed query parameters and collects what is malformed
+type filters struct {
+	values url.Values
+	fields []FieldError
+}
+
+func (f *filters) fail(field, message string) {
+	f.fields = append(f.fields, FieldError{Field: field, Message: message})
+}
+
+func (f *filters) err() error {
+	if len(f.fields) == 0 {
+		return nil
+	}
+	return Invalid("invalid_query", "Invalid list query", f.fields...)
+}
+
+func (f *filters) has(name string) bool {
+	return strings.TrimSpace(f.values.Get(name)) != ""
+}
+
+func (f *filters) string(name string) string {
+	return strings.TrimSpace(f.values.Get(name))
+}
+
+func (f *filters) int(name string, fallback, min, max int) int {
+	if !f.has(name) {
+		return fallback
+	}
+	n, err := strconv.Atoi(f.string(name))
+	if err != nil || n < min || n > max {
+		f.fail(name, "must be a whole number from "+strconv.Itoa(min)+" to "+strconv.Itoa(max))
+		return fallback
+	}
+	return n
+}
+
+// bool is nil when the filter is absent
+func (f *filters) bool(name string) *bool {
+	if !f.has(name) {
+		return nil
+	}
+	b, err := strconv.ParseBool(f.string(name))
+	if err != nil {
+		f.fail(name, "must be true or false")
+		return nil
+	}
+	return &b
+}
+
+// amount reads a decimal such as 1299.50 as minor units of currency
+func (f *filters) amount(name, currency string) *int64 {
+	if !f.has(name) {
+		return nil
+	}
+	major, err := strconv.ParseFloat(f.string(name), 64)
+	if err != nil || major < 0 {
+		f.fail(name, "must be a non-negative amount")
+		return nil
+	}
+	minor := money.FromMajor(major, currency).Amount
+	return &minor
+}
+
+// dateRange applies from/to (YYYY-MM-DD, both inclusive) to column
+func (f *filters) dateRange(query *gorm.DB, column string) *gorm.DB {
+	if from := f.date("from"); from != nil {
+		query = query.Where(column+" >= ?", *from)
+	}
+	if to := f.date("to"); to != nil {
+		query = query.Where(column+" < ?", to.AddDate(0, 0, 1))
+	}
+	return query
+}
+
+func (f *filters) date(name string) *time.Time {
+	if !f.has(name) {
+		return nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", f.string(name), time.Local)
+	if err != nil {
+		f.fail(name, "must be a date (YYYY-MM-DD)")
+		return nil
+	}
+	return &t
+}
+
+// likePattern matches s anywhere, with LIKE wildcards in s taken literally
+func likePattern(s string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
+}
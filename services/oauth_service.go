@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"e-commerce/models"
+	"e-commerce/oauth"
+	"e-commerce/utils"
+
+	"gorm.io/gorm"
+)
+
+// ErrProviderEmailUnverified refuses sign-in when the provider does not
+// vouch for the email it returned
+var ErrProviderEmailUnverified = Forbidden("provider_email_unverified", "Email not verified by provider")
+
+// ---------- OAuth / OIDC Login ----------
+
+// OAuthLoginService signs in the owner of a verified provider identity.
+// Known identities log straight in; otherwise the identity is linked to the
+// account with the same email, or a new verified user is created. An account
+// whose email was never verified is claimed with claimUnprovenAccount, and
+// orders from guest checkout with the email are attached.
+func OAuthLoginService(db *gorm.DB, provider string, ident *oauth.Identity, guestCart string) (string, string, error) {
+	var user models.User
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var link models.UserIdentity
+		if err := tx.Where("provider = ? AND subject = ?", provider, ident.Subject).First(&link).Error; err == nil {
+			return tx.First(&user, link.UserID).Error
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// linking by email is only safe when the provider vouches for it
+		email := normalizeEmail(ident.Email)
+		if email == "" || !ident.EmailVerified {
+			return ErrProviderEmailUnverified
+		}
+
+		var err error
+		user, err = findAccount(tx, email)
+		switch {
+		case err == nil:
+			if !user.IsVerified {
+				if err := claimUnprovenAccount(tx, &user, ident); err != nil {
+					return err
+				}
+			}
+		case errors.Is(err, ErrUserNotFound):
+			hashed, err := unusablePasswordHash()
+			if err != nil {
+				return err
+			}
+			user = models.User{
+				FullName:     ident.Name,
+				Email:        email,
+				PasswordHash: hashed,
+				Role:         "user",
+				IsVerified:   true,
+			}
+			if user.FullName == "" {
+				user.FullName = strings.Split(email, "@")[0]
+			}
+			if ident.Picture != "" {
+				user.AvatarURL = &ident.Picture
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		// the provider has verified the email, so guest orders carry over
+		if err := claimGuestOrders(tx, user); err != nil {
+			return err
+		}
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  ident.Subject,
+			Email:    email,
+		}).Error
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	if user.IsBlocked {
+		return "", "", ErrAccountBlocked
+	}
+
+	accessToken, err := issueSession(db, user)
+	if err != nil {
+		return "", "", err
+	}
+	mergeGuestCartOnLogin(db, guestCart, user.ID)
+	return accessToken, user.Role, nil
+}
+
+// claimUnprovenAccount hands an unverified signup to the provider's verified
+// owner of the email. Whoever created the row may have been someone else,
+// so its password, sessions, codes and links are discarded, not inherited.
+func claimUnprovenAccount(tx *gorm.DB, user *models.User, ident *oauth.Identity) error {
+	hashed, err := unusablePasswordHash()
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hashed
+	user.IsVerified = true
+	if ident.Name != "" {
+		user.FullName = ident.Name
+	}
+	user.Address = ""
+	user.AvatarURL = nil
+	if ident.Picture != "" {
+		user.AvatarURL = &ident.Picture
+	}
+	if err := tx.Save(user).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RefreshToken{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.OTP{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.ActionToken{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Update("used_at", time.Now()).Error
+}
+
+// unusablePasswordHash hashes a random secret nobody knows: the account
+// signs in through the provider until the user runs the forgot-password flow
+func unusablePasswordHash() (string, error) {
+	random, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	hashed, err := utils.HashPassword(random)
+	if err != nil {
+		return "", Internal("Failed to hash password", err)
+	}
+	return hashed, nil
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"e-commerce/mailer"
+	"e-commerce/models"
+	"e-commerce/money"
+	"github.com/stripe/stripe-go/v74"
+	"github.com/stripe/stripe-go/v74/refund"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AdminOrderStatuses are the statuses an admin can set directly. "failed"
+// comes from the payment and "refunded" from a full refund.
+var AdminOrderStatuses = []string{"pending", "processing", "shipped", "delivered"}
+
+// orderStatusTransitions are the statuses an admin can move an order to
+// from each status. Orders only move forward; delivered, failed and
+// refunded orders are final.
+var orderStatusTransitions = map[string][]string{
+	"pending":    {"processing"},
+	"processing": {"shipped", "delivered"},
+	"shipped":    {"delivered"},
+}
+
+// NextOrderStatuses lists the statuses an admin can move an order in
+// status to
+func NextOrderStatuses(status string) []string {
+	return orderStatusTransitions[status]
+}
+
+// checkOrderTransition refuses a status change the order's lifecycle does
+// not allow; keeping the current status is a no-op and always allowed
+func checkOrderTransition(from, to string) error {
+	valid := false
+	for _, s := range AdminOrderStatuses {
+		valid = valid || s == to
+	}
+	if !valid {
+		return ErrInvalidStatus
+	}
+	if from == to {
+		return nil
+	}
+	for _, s := range orderStatusTransitions[from] {
+		if s == to {
+			return nil
+		}
+	}
+	return ErrStatusTransition.WithDetails(map[string]interface{}{
+		"from":    from,
+		"to":      to,
+		"allowed": NextOrderStatuses(from),
+	})
+}
+
+var (
+	ErrStatusTransition = Conflict("invalid_status_transition", "The order cannot move to that status")
+	ErrNotShippable     = Conflict("order_not_shippable", "Only processing or shipped orders can get a shipment")
+	ErrNotRefundable    = Conflict("order_not_refundable", "The order has no successful payment left to refund")
+	ErrRefundFailed     = Upstream("refund_failed", "The payment gateway refused the refund")
+	ErrOrderNoteEmpty   = Invalid("note_empty", "Note is empty", FieldError{Field: "body", Message: "is required"})
+	ErrOrderNoteAbsent  = NotFound("note_not_found", "Note not found")
+)
+
+// ---------- Status history ----------
+
+// RecordOrderStatus adds a history entry in tx; actorID is nil for system
+// changes
+func RecordOrderStatus(tx *gorm.DB, orderID uint, from, to string, actorID *uint, note string) error {
+	if from == to {
+		return nil
+	}
+	return tx.Create(&models.OrderStatusChange{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actorID,
+		Note:       strings.TrimSpace(note),
+	}).Error
+}
+
+// ---------- Detail ----------
+
+// OrderDetail is everything the admin order page shows
+type OrderDetail struct {
+	Order      models.Order               `json:"order"`
+	Payments   []models.Payment           `json:"payments"`
+	History    []models.OrderStatusChange `json:"history"`
+	Shipments  []models.Shipment          `json:"shipments"`
+	Refunds    []models.Refund            `json:"refunds"`
+	Notes      []models.OrderNote         `json:"notes"`
+	Refundable money.Money                `json:"refundable"` // left to refund on the successful payment
+}
+
+func GetOrderDetail(db *gorm.DB, orderID uint) (*OrderDetail, error) {
+	var detail OrderDetail
+	if err := db.Preload("User").Preload("OrderItems.Product").First(&detail.Order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+
+	if err := db.Where("order_id = ?", orderID).Order("created_at").Find(&detail.Payments).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Preload("Actor").Where("order_id = ?", orderID).Order("created_at, id").Find(&detail.History).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("order_id = ?", orderID).Order("created_at").Find(&detail.Shipments).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("order_id = ?", orderID).Order("created_at").Find(&detail.Refunds).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Preload("Author").Where("order_id = ?", orderID).Order("created_at DESC").Find(&detail.Notes).Error; err != nil {
+		return nil, err
+	}
+
+	detail.Refundable = money.Zero(detail.Order.TotalAmount.Currency)
+	if _, left, err := refundablePayment(db, orderID); err == nil {
+		detail.Refundable = left
+	} else if !errors.Is(err, ErrNotRefundable) {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// ---------- Status ----------
+
+// UpdateOrderStatusAdmin moves an order to newStatus when its lifecycle
+// allows it, and records the change in the history
+func UpdateOrderStatusAdmin(db *gorm.DB, orderID uint, newStatus string, actorID *uint, note string) (*OrderResponse, error) {
+	var order models.Order
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("User").Preload("OrderItems.Product").
+			First(&order, orderID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+		previousStatus := order.Status
+		if err := checkOrderTransition(previousStatus, newStatus); err != nil {
+			return err
+		}
+		if previousStatus == newStatus {
+			return nil
+		}
+
+		order.Status = newStatus
+		if err := tx.Save(&order).Error; err != nil {
+			return err
+		}
+		if err := RecordOrderStatus(tx, order.ID, previousStatus, newStatus, actorID, note); err != nil {
+			return err
+		}
+		if newStatus == "shipped" {
+			return SendOrderShippedEmail(tx, order)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrderResponses([]models.Order{order})[0], nil
+}
+
+// ---------- Shipments ----------
+
+type ShipmentInput struct {
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+	TrackingURL    string `json:"tracking_url"`
+}
+
+// AddShipment records a parcel and emails its tracking details. The first
+// shipment of a processing order marks it shipped.
+func AddShipment(db *gorm.DB, orderID uint, input ShipmentInput, actorID *uint) (*models.Shipment, error) {
+	input.Carrier = strings.TrimSpace(input.Carrier)
+	input.TrackingNumber = strings.TrimSpace(input.TrackingNumber)
+	input.TrackingURL = strings.TrimSpace(input.TrackingURL)
+	var fields []FieldError
+	if input.Carrier == "" {
+		fields = append(fields, FieldError{Field: "carrier", Message: "is required"})
+	}
+	if input.TrackingNumber == "" {
+		fields = append(fields, FieldError{Field: "tracking_number", Message: "is required"})
+	}
+	if input.TrackingURL != "" && !strings.HasPrefix(input.TrackingURL, "https://") && !strings.HasPrefix(input.TrackingURL, "http://") {
+		fields = append(fields, FieldError{Field: "tracking_url", Message: "must be an http(s) URL"})
+	}
+	if len(fields) > 0 {
+		return nil, Invalid("invalid_shipment", "Invalid shipment", fields...)
+	}
+
+	var shipment models.Shipment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("User").Preload("OrderItems.Product").
+			First(&order, orderID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+		if order.Status != "processing" && order.Status != "shipped" {
+			return ErrNotShippable
+		}
+
+		shipment = models.Shipment{
+			OrderID:        order.ID,
+			Carrier:        input.Carrier,
+			TrackingNumber: input.TrackingNumber,
+			TrackingURL:    input.TrackingURL,
+			CreatedByID:    actorID,
+		}
+		if err := tx.Create(&shipment).Error; err != nil {
+			return err
+		}
+		if order.Status == "processing" {
+			if err := tx.Model(&order).Update("status", "shipped").Error; err != nil {
+				return err
+			}
+			if err := RecordOrderStatus(tx, order.ID, "processing", "shipped", actorID,
+				"Shipped with "+input.Carrier+" "+input.TrackingNumber); err != nil {
+				return err
+			}
+		}
+
+		data := orderEmailData(order)
+		data.Carrier = input.Carrier
+		data.TrackingNumber = input.TrackingNumber
+		return EnqueueEmail(tx, order.User.Email, mailer.TemplateOrderShipped, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+// ---------- Refunds ----------
+
+type RefundInput struct {
+	Amount  money.Money // zero refunds everything left
+	Reason  string
+	Restock bool // put the items back in stock; full refunds only
+}
+
+// refundablePayment is the order's successful payment and what is left to
+// refund on it
+func refundablePayment(db *gorm.DB, orderID uint) (*models.Payment, money.Money, error) {
+	var payment models.Payment
+	if err := db.Where("order_id = ? AND status IN ?", orderID, []string{"succeeded", "refunded"}).
+		Order("created_at DESC").First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, money.Money{}, ErrNotRefundable
+		}
+		return nil, money.Money{}, err
+	}
+
+	var refunded int64
+	if err := db.Model(&models.Refund{}).Where("payment_id = ? AND status <> ?", payment.ID, "failed").
+		Select("COALESCE(SUM(amount), 0)").Scan(&refunded).Error; err != nil {
+		return nil, money.Money{}, err
+	}
+	left := money.New(payment.Amount.Amount-refunded, payment.Amount.Currency)
+	if left.Amount <= 0 {
+		return nil, left, ErrNotRefundable
+	}
+	return &payment, left, nil
+}
+
+// RefundOrder returns money on the order's payment through Stripe. The
+// refund is saved as pending first, so a crash after the gateway call
+// leaves a visible record instead of a silent refund. A refund that leaves
+// nothing to refund marks the order "refunded" and may restock its items.
+func RefundOrder(db *gorm.DB, orderID uint, input RefundInput, actorID *uint) (*models.Refund, error) {
+	var order models.Order
+	if err := db.Preload("User").Preload("OrderItems").First(&order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	payment, left, err := refundablePayment(db, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := input.Amount
+	if amount.IsZero() {
+		amount = left
+	}
+	var fields []FieldError
+	if cmp, err := amount.Cmp(left); err != nil {
+		fields = append(fields, FieldError{Field: "amount", Message: "must be in " + left.Currency})
+	} else if amount.Amount < 0 {
+		fields = append(fields, FieldError{Field: "amount", Message: "must be positive"})
+	} else if cmp > 0 {
+		fields = append(fields, FieldError{Field: "amount", Message: "must be at most " + left.String()})
+	}
+	full := amount.Amount == left.Amount
+	if input.Restock && !full {
+		fields = append(fields, FieldError{Field: "restock", Message: "is only possible when refunding everything left"})
+	}
+	if len(fields) > 0 {
+		return nil, Invalid("invalid_refund", "Invalid refund", fields...)
+	}
+
+	rec := models.Refund{
+		OrderID:     order.ID,
+		PaymentID:   payment.ID,
+		Amount:      amount,
+		Reason:      strings.TrimSpace(input.Reason),
+		Status:      "pending",
+		CreatedByID: actorID,
+	}
+	if err := db.Create(&rec).Error; err != nil {
+		return nil, err
+	}
+
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(payment.PaymentID),
+		Amount:        stripe.Int64(amount.Amount),
+	}
+	params.SetIdempotencyKey("refund-" + strconv.Itoa(int(rec.ID)))
+	params.AddMetadata("order_id", strconv.Itoa(int(order.ID)))
+	gatewayRefund, err := refund.New(params)
+	if err != nil || gatewayRefund.Status == stripe.RefundStatusFailed || gatewayRefund.Status == stripe.RefundStatusCanceled {
+		// best effort; if this fails too the pending record is left for
+		// reconciliation
+		db.Model(&rec).Update("status", "failed")
+		return nil, ErrRefundFailed.Wrap(err)
+	}
+
+	previousStatus := order.Status
+	err = db.Transaction(func(tx *gorm.DB) error {
+		rec.GatewayRefundID = gatewayRefund.ID
+		rec.Status = "succeeded"
+		rec.Restocked = input.Restock
+		if err := tx.Save(&rec).Error; err != nil {
+			return err
+		}
+
+		if full {
+			if err := tx.Model(payment).Update("status", "refunded").Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&order).Update("status", "refunded").Error; err != nil {
+				return err
+			}
+			note := "Refunded " + amount.String()
+			if rec.Reason != "" {
+				note += ": " + rec.Reason
+			}
+			if err := RecordOrderStatus(tx, order.ID, previousStatus, "refunded", actorID, note); err != nil {
+				return err
+			}
+		}
+
+		if input.Restock {
+			for _, item := range order.OrderItems {
+				if err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).
+					Update("stock_quantity", gorm.Expr("stock_quantity + ?", item.Quantity)).Error; err != nil {
+					return err
+				}
+				if err := ProductRestocked(tx, item.ProductID); err != nil {
+					return err
+				}
+			}
+		}
+
+		return EnqueueEmail(tx, order.User.Email, mailer.TemplateRefund, mailer.RefundData{
+			Name:    order.User.FullName,
+			OrderID: order.ID,
+			Amount:  amount,
+			Reason:  rec.Reason,
+		})
+	})
+	if err != nil {
+		// the gateway has refunded; the pending record shows what to reconcile
+		return nil, Internal("Refund issued but not recorded", err)
+	}
+	return &rec, nil
+}
+
+// ---------- Notes ----------
+
+func AddOrderNote(db *gorm.DB, orderID uint, authorID *uint, body string) (*models.OrderNote, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, ErrOrderNoteEmpty
+	}
+	var count int64
+	if err := db.Model(&models.Order{}).Where("id = ?", orderID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrOrderNotFound
+	}
+
+	note := models.OrderNote{OrderID: orderID, AuthorID: authorID, Body: body}
+	if err := db.Create(&note).Error; err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+func DeleteOrderNote(db *gorm.DB, orderID, noteID uint) error {
+	res := db.Where("order_id = ?", orderID).Delete(&models.OrderNote{}, noteID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrOrderNoteAbsent
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckOrderTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     error
+	}{
+		{"pending", "processing", nil},
+		{"processing", "shipped", nil},
+		{"processing", "delivered", nil},
+		{"shipped", "delivered", nil},
+		{"shipped", "shipped", nil},
+		{"processing", "pending", ErrStatusTransition},
+		{"shipped", "processing", ErrStatusTransition},
+		{"delivered", "shipped", ErrStatusTransition},
+		{"pending", "shipped", ErrStatusTransition},
+		{"refunded", "processing", ErrStatusTransition},
+		{"refunded", "delivered", ErrStatusTransition},
+		{"failed", "processing", ErrStatusTransition},
+		{"failed", "shipped", ErrStatusTransition},
+		{"processing", "refunded", ErrInvalidStatus},
+		{"pending", "lost", ErrInvalidStatus},
+	}
+	for _, tt := range tests {
+		err := checkOrderTransition(tt.from, tt.to)
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s -> %s: got %v, want %v", tt.from, tt.to, err, tt.want)
+		}
+	}
+}
+
+func TestStatusTransitionDetails(t *testing.T) {
+	var e *Error
+	if !errors.As(checkOrderTransition("shipped", "pending"), &e) {
+		t.Fatal("expected a services error")
+	}
+	details, _ := e.Details.(map[string]interface{})
+	if allowed, _ := details["allowed"].([]string); len(allowed) != 1 || allowed[0] != "delivered" {
+		t.Errorf("details = %v", e.Details)
+	}
+}
@@ -4,20 +4,29 @@ import (
 	"errors"
 	"time"
 
+	"e-commerce/mailer"
 	"e-commerce/models"
+	"e-commerce/money"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrOrderNotFound  = NotFound("order_not_found", "Order not found")
+	ErrOrderForbidden = Forbidden("forbidden", "This order belongs to another user")
+	ErrInvalidStatus  = Invalid("invalid_status", "Invalid status", FieldError{Field: "status", Message: "is not a valid order status"})
+	ErrNotSoldIn      = Conflict("currency_unavailable", "Some items are not sold in this currency")
+)
+
 type OrderItemResponse struct {
 	ProductID uint    `json:"product_id"`
 	Name      string  `json:"name"`
 	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	Price     money.Money `json:"price"`
 }
 
 type OrderResponse struct {
 	ID          uint                `json:"id"`
-	TotalAmount float64             `json:"total_amount"`
+	TotalAmount money.Money         `json:"total_amount"`
 	Address     string              `json:"address"`
 	Status      string              `json:"status"`
 	CreatedAt   time.Time           `json:"created_at"`
@@ -25,51 +34,72 @@ type OrderResponse struct {
 	Items       []OrderItemResponse `json:"items"`
 }
 
-func CreateOrder(db *gorm.DB, userID uint, address string) (*OrderResponse, error) {
-	var cartItems []models.CartItem
-	if err := db.Where("user_id = ?", userID).Preload("Product").Find(&cartItems).Error; err != nil {
+// CreateOrder turns the user's cart into a pending order at current prices
+// in currency ("" for the store currency). Carts with unavailable items, that
+// changed since the client reviewed cartVersion, or with changed prices and
+// no reviewed version are refused with a *CartStaleError.
+func CreateOrder(db *gorm.DB, userID uint, address, currency, cartVersion string) (*OrderResponse, error) {
+	if currency == "" {
+		currency = money.DefaultCurrency()
+	}
+	if err := CheckOrderingAllowed(db, userID); err != nil {
 		return nil, err
 	}
-	if len(cartItems) == 0 {
-		return nil, errors.New("cart is empty")
+	cart, err := ValidateCart(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Lines) == 0 {
+		return nil, ErrCartEmpty
+	}
+	if err := cart.CheckoutError(cartVersion); err != nil {
+		return nil, err
+	}
+	prices, err := unitPrices(db, cart.Lines, currency)
+	if err != nil {
+		return nil, err
 	}
 
-	tx := db.Begin()
 	order := models.Order{
 		UserID:      userID,
 		Address:     address,
 		Status:      "pending",
-		TotalAmount: 0,
+		TotalAmount: money.Zero(currency),
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	if err := tx.Create(&order).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
 
-	totalAmount := 0.0
-	for _, item := range cartItems {
-		itemTotal := float64(item.Quantity) * item.Product.Price
-		totalAmount += itemTotal
-		orderItem := models.OrderItem{
-			OrderID:   order.ID,
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     item.Product.Price,
-			CreatedAt: time.Now(),
+		totalAmount := money.Zero(currency)
+		for _, line := range cart.Lines {
+			item := line.Item
+			price := prices[item.ProductID]
+			var err error
+			if totalAmount, err = totalAmount.Add(price.Times(item.Quantity)); err != nil {
+				return err
+			}
+			orderItem := models.OrderItem{
+				OrderID:   order.ID,
+				ProductID: item.ProductID,
+				Quantity:  item.Quantity,
+				Price:     price,
+				CreatedAt: time.Now(),
+			}
+			if err := tx.Create(&orderItem).Error; err != nil {
+				return err
+			}
 		}
-		if err := tx.Create(&orderItem).Error; err != nil {
-			tx.Rollback()
-			return nil, err
+		order.TotalAmount = totalAmount
+		if err := tx.Save(&order).Error; err != nil {
+			return err
 		}
-	}
-	order.TotalAmount = totalAmount
-	if err := tx.Save(&order).Error; err != nil {
-		tx.Rollback()
+		return recordCartConversion(tx, userID, order)
+	}); err != nil {
 		return nil, err
 	}
-	tx.Commit()
 
 	var fullOrder models.Order
 	if err := db.Preload("User").Preload("OrderItems.Product").First(&fullOrder, order.ID).Error; err != nil {
@@ -98,47 +128,52 @@ func CreateOrder(db *gorm.DB, userID uint, address string) (*OrderResponse, erro
 	return resp, nil
 }
 
-func GetUserOrders(db *gorm.DB, userID uint) ([]OrderResponse, error) {
-	var orders []models.Order
-	if err := db.Preload("User").Preload("OrderItems.Product").
-		Where("user_id = ?", userID).Order("created_at desc").Find(&orders).Error; err != nil {
-		return nil, err
+// unitPrices is each ordered product's price in currency, by product ID
+func unitPrices(db *gorm.DB, lines []ValidatedCartLine, currency string) (map[uint]money.Money, error) {
+	prices := map[uint]money.Money{}
+	missing := []uint{}
+	for _, line := range lines {
+		if price, ok := line.Item.Product.PriceIn(currency); ok {
+			prices[line.Item.ProductID] = price
+		} else {
+			missing = append(missing, line.Item.ProductID)
+		}
+	}
+	if len(missing) == 0 {
+		return prices, nil
 	}
 
-	resp := []OrderResponse{}
-	for _, order := range orders {
-		items := []OrderItemResponse{}
-		for _, oi := range order.OrderItems {
-			items = append(items, OrderItemResponse{
-				ProductID: oi.ProductID,
-				Name:      oi.Product.Name,
-				Quantity:  oi.Quantity,
-				Price:     oi.Price,
-			})
+	var extra []models.ProductPrice
+	if err := db.Where("product_id IN ? AND price_currency = ?", missing, currency).Find(&extra).Error; err != nil {
+		return nil, err
+	}
+	for _, pp := range extra {
+		prices[pp.ProductID] = pp.Price
+	}
+	if len(prices) < len(lines) {
+		unsold := []uint{}
+		for _, id := range missing {
+			if _, ok := prices[id]; !ok {
+				unsold = append(unsold, id)
+			}
 		}
-		resp = append(resp, OrderResponse{
-			ID:          order.ID,
-			TotalAmount: order.TotalAmount,
-			Address:     order.Address,
-			Status:      order.Status,
-			CreatedAt:   order.CreatedAt,
-			UserName:    order.User.FullName,
-			Items:       items,
-		})
+		return nil, ErrNotSoldIn.WithDetails(map[string]interface{}{"currency": currency, "product_ids": unsold})
 	}
-	return resp, nil
+	return prices, nil
 }
 
-func GetAllOrders(db *gorm.DB, status string) ([]OrderResponse, error) {
+func GetUserOrders(db *gorm.DB, userID uint) ([]OrderResponse, error) {
 	var orders []models.Order
-	query := db.Preload("User").Preload("OrderItems.Product").Order("created_at desc")
-	if status != "" && status != "all" {
-		query = query.Where("status = ?", status)
-	}
-	if err := query.Find(&orders).Error; err != nil {
+	if err := db.Preload("User").Preload("OrderItems.Product").
+		Where("user_id = ?", userID).Order("created_at desc").Find(&orders).Error; err != nil {
 		return nil, err
 	}
 
+	return OrderResponses(orders), nil
+}
+
+// OrderResponses maps orders loaded with their User and OrderItems.Product
+func OrderResponses(orders []models.Order) []OrderResponse {
 	resp := []OrderResponse{}
 	for _, order := range orders {
 		items := []OrderItemResponse{}
@@ -160,93 +195,36 @@ func GetAllOrders(db *gorm.DB, status string) ([]OrderResponse, error) {
 			Items:       items,
 		})
 	}
-	return resp, nil
-}
-
-func UpdateOrderStatusAdmin(db *gorm.DB, orderID uint, newStatus string) (*OrderResponse, error) {
-	var order models.Order
-	if err := db.Preload("User").Preload("OrderItems.Product").First(&order, orderID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("order not found")
-		}
-		return nil, err
-	}
-	validStatuses := map[string]bool{"pending": true, "processing": true, "shipped": true, "delivered": true}
-	if !validStatuses[newStatus] {
-		return nil, errors.New("invalid status")
-	}
-	order.Status = newStatus
-	order.UpdatedAt = time.Now()
-	if err := db.Save(&order).Error; err != nil {
-		return nil, err
-	}
-
-	items := []OrderItemResponse{}
-	for _, oi := range order.OrderItems {
-		items = append(items, OrderItemResponse{
-			ProductID: oi.ProductID,
-			Name:      oi.Product.Name,
-			Quantity:  oi.Quantity,
-			Price:     oi.Price,
-		})
-	}
-
-	return &OrderResponse{
-		ID:          order.ID,
-		TotalAmount: order.TotalAmount,
-		Address:     order.Address,
-		Status:      order.Status,
-		CreatedAt:   order.CreatedAt,
-		UserName:    order.User.FullName,
-		Items:       items,
-	}, nil
+	return resp
 }
 
 func GetOrderByID(db *gorm.DB, orderID uint, userID uint) (*OrderResponse, error) {
 	var order models.Order
 	if err := db.Preload("User").Preload("OrderItems.Product").First(&order, orderID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("order not found")
+			return nil, ErrOrderNotFound
 		}
 		return nil, err
 	}
 
 	if order.UserID != userID {
-		return nil, errors.New("unauthorized access")
-	}
-
-	items := []OrderItemResponse{}
-	for _, oi := range order.OrderItems {
-		items = append(items, OrderItemResponse{
-			ProductID: oi.ProductID,
-			Name:      oi.Product.Name,
-			Quantity:  oi.Quantity,
-			Price:     oi.Price,
-		})
+		return nil, ErrOrderForbidden
 	}
 
-	return &OrderResponse{
-		ID:          order.ID,
-		TotalAmount: order.TotalAmount,
-		Address:     order.Address,
-		Status:      order.Status,
-		CreatedAt:   order.CreatedAt,
-		UserName:    order.User.FullName,
-		Items:       items,
-	}, nil
+	return &OrderResponses([]models.Order{order})[0], nil
 }
 
 func DeleteOrder(db *gorm.DB, orderID uint, userID uint) error {
 	var order models.Order
 	if err := db.Preload("OrderItems").First(&order, orderID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("order not found")
+			return ErrOrderNotFound
 		}
 		return err
 	}
 
 	if order.UserID != userID {
-		return errors.New("unauthorized access")
+		return ErrOrderForbidden
 	}
 
 	if err := db.Transaction(func(tx *gorm.DB) error {
@@ -262,3 +240,35 @@ func DeleteOrder(db *gorm.DB, orderID uint, userID uint) error {
 	}
 	return nil
 }
+
+
+// ---------- Order emails ----------
+func orderEmailData(order models.Order) mailer.OrderData {
+	lines := make([]mailer.OrderLine, 0, len(order.OrderItems))
+	for _, oi := range order.OrderItems {
+		lines = append(lines, mailer.OrderLine{
+			Name:     oi.Product.Name,
+			Quantity: oi.Quantity,
+			Price:    oi.Price,
+		})
+	}
+	return mailer.OrderData{
+		Name:    order.User.FullName,
+		OrderID: order.ID,
+		Items:   lines,
+		Total:   order.TotalAmount,
+		Address: order.Address,
+	}
+}
+
+// SendOrderConfirmationEmail queues the confirmation in tx; expects User
+// and OrderItems.Product preloaded
+func SendOrderConfirmationEmail(tx *gorm.DB, order models.Order) error {
+	return EnqueueEmail(tx, order.User.Email, mailer.TemplateOrderConfirmation, orderEmailData(order))
+}
+
+// SendOrderShippedEmail queues the shipping notice in tx; expects User and
+// OrderItems.Product preloaded
+func SendOrderShippedEmail(tx *gorm.DB, order models.Order) error {
+	return EnqueueEmail(tx, order.User.Email, mailer.TemplateOrderShipped, orderEmailData(order))
+}
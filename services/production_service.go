@@ -0,0 +1,199 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"e-commerce/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProductionStatuses are the states of a production run, in order
+var ProductionStatuses = []string{"started", "in_progress", "completed"}
+
+// productionStatusTransitions are the statuses a run can move to from each
+// status. Runs only move forward; a completed run is final, so its units
+// go into stock once.
+var productionStatusTransitions = map[string][]string{
+	"started":     {"in_progress", "completed"},
+	"in_progress": {"completed"},
+}
+
+// NextProductionStatuses lists the statuses a run in status can move to
+func NextProductionStatuses(status string) []string {
+	return productionStatusTransitions[status]
+}
+
+var (
+	ErrProductionNotFound   = NotFound("production_not_found", "Production not found")
+	ErrProductionInProgress = Conflict("production_in_progress", "Production already in progress for this product")
+	ErrProductionStatus     = Invalid("invalid_status", "Invalid status value",
+		FieldError{Field: "status", Message: "must be one of: started, in_progress, completed"})
+	ErrProductionTransition = Conflict("invalid_production_transition", "The production run cannot move to that status")
+	ErrProductionQuantity   = Invalid("invalid_quantity", "Invalid quantity",
+		FieldError{Field: "quantity", Message: "must be 0 or more"})
+)
+
+func validProductionStatus(status string) bool {
+	for _, s := range ProductionStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// checkProductionTransition refuses a status change the run's lifecycle
+// does not allow; keeping the current status is a no-op and always allowed
+func checkProductionTransition(from, to string) error {
+	if !validProductionStatus(to) {
+		return ErrProductionStatus
+	}
+	if from == to {
+		return nil
+	}
+	for _, s := range productionStatusTransitions[from] {
+		if s == to {
+			return nil
+		}
+	}
+	return ErrProductionTransition.WithDetails(map[string]interface{}{
+		"from":    from,
+		"to":      to,
+		"allowed": NextProductionStatuses(from),
+	})
+}
+
+// StartProduction opens a run for the product; quantity units go into
+// stock when it completes. A product has at most one unfinished run.
+func StartProduction(db *gorm.DB, productID uint, quantity int) (*models.ProductProduction, error) {
+	if quantity < 0 {
+		return nil, ErrProductionQuantity
+	}
+	var product models.Product
+	if err := db.First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+
+	if _, err := ActiveProduction(db, productID); err == nil {
+		return nil, ErrProductionInProgress
+	} else if !errors.Is(err, ErrProductionNotFound) {
+		return nil, err
+	}
+
+	production := models.ProductProduction{
+		ProductID: productID,
+		Status:    "started",
+		Quantity:  quantity,
+	}
+	if err := db.Create(&production).Error; err != nil {
+		return nil, err
+	}
+	return GetProduction(db, production.ID)
+}
+
+// UpdateProductionStatus moves a run forward to status. Completing a run
+// puts its units into stock and notifies back-in-stock subscribers.
+func UpdateProductionStatus(db *gorm.DB, productionID uint, status string) (*models.ProductProduction, error) {
+	if !validProductionStatus(status) {
+		return nil, ErrProductionStatus
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var production models.ProductProduction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&production, productionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrProductionNotFound
+			}
+			return err
+		}
+		if err := checkProductionTransition(production.Status, status); err != nil {
+			return err
+		}
+		if production.Status == status {
+			return nil
+		}
+
+		production.Status = status
+		if status == "completed" {
+			now := time.Now()
+			production.CompletedAt = &now
+		}
+		if err := tx.Save(&production).Error; err != nil {
+			return err
+		}
+		if status != "completed" || production.Quantity <= 0 {
+			return nil
+		}
+		if err := tx.Model(&models.Product{}).Where("id = ?", production.ProductID).
+			Update("stock_quantity", gorm.Expr("stock_quantity + ?", production.Quantity)).Error; err != nil {
+			return err
+		}
+		return ProductRestocked(tx, production.ProductID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetProduction(db, productionID)
+}
+
+// GetProduction loads a run with its product
+func GetProduction(db *gorm.DB, productionID uint) (*models.ProductProduction, error) {
+	var production models.ProductProduction
+	if err := db.Preload("Product").First(&production, productionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductionNotFound
+		}
+		return nil, err
+	}
+	return &production, nil
+}
+
+// ActiveProduction is the product's unfinished run, or ErrProductionNotFound
+func ActiveProduction(db *gorm.DB, productID uint) (*models.ProductProduction, error) {
+	var production models.ProductProduction
+	if err := db.Where("product_id = ? AND status != ?", productID, "completed").First(&production).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductionNotFound
+		}
+		return nil, err
+	}
+	return &production, nil
+}
+
+func GetAllProductions(db *gorm.DB) ([]models.ProductProduction, error) {
+	var productions []models.ProductProduction
+	if err := db.Preload("Product").Find(&productions).Error; err != nil {
+		return nil, err
+	}
+	return productions, nil
+}
+
+// ProductionBoard groups the unfinished runs by status, oldest first
+func ProductionBoard(db *gorm.DB) (map[string][]models.ProductProduction, error) {
+	var productions []models.ProductProduction
+	if err := db.Preload("Product").Where("status != ?", "completed").
+		Order("started_at, id").Find(&productions).Error; err != nil {
+		return nil, err
+	}
+	board := map[string][]models.ProductProduction{}
+	for _, p := range productions {
+		board[p.Status] = append(board[p.Status], p)
+	}
+	return board, nil
+}
+
+// CompletedProductions is the product's finished runs, newest first
+func CompletedProductions(db *gorm.DB, productID uint) ([]models.ProductProduction, error) {
+	var productions []models.ProductProduction
+	if err := db.Where("product_id = ? AND status = ?", productID, "completed").
+		Order("completed_at DESC, id DESC").Find(&productions).Error; err != nil {
+		return nil, err
+	}
+	return productions, nil
+}
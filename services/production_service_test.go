@@ -0,0 +1,29 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckProductionTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     error
+	}{
+		{"started", "in_progress", nil},
+		{"started", "completed", nil},
+		{"in_progress", "completed", nil},
+		{"in_progress", "in_progress", nil},
+		{"completed", "completed", nil},
+		{"in_progress", "started", ErrProductionTransition},
+		{"completed", "in_progress", ErrProductionTransition},
+		{"completed", "started", ErrProductionTransition},
+		{"started", "cancelled", ErrProductionStatus},
+	}
+	for _, tt := range tests {
+		err := checkProductionTransition(tt.from, tt.to)
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s -> %s: got %v, want %v", tt.from, tt.to, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"e-commerce/config"
+	"e-commerce/jobs"
+	"e-commerce/mailer"
+	"e-commerce/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobNotifyBackInStock emails a product's open stock subscriptions
+const JobNotifyBackInStock = "stock.notify"
+
+var ErrEmailRequired = Invalid("email_required", "Email is required", FieldError{Field: "email", Message: "is required"})
+
+type notifyBackInStockJob struct {
+	ProductID uint `json:"product_id"`
+}
+
+// ---------- Subscriptions ----------
+
+// SubscribeBackInStock records a back-in-stock request. Subscribing twice
+// before the product is restocked keeps the first subscription; created
+// reports whether a new one was made.
+func SubscribeBackInStock(db *gorm.DB, productID uint, userID *uint, email string) (*models.StockSubscription, bool, error) {
+	var product models.Product
+	if err := db.First(&product, productID).Error; err != nil {
+		return nil, false, ErrProductNotFound
+	}
+
+	if userID != nil {
+		var user models.User
+		if err := db.Select("email").First(&user, *userID).Error; err != nil {
+			return nil, false, ErrUserNotFound
+		}
+		email = user.Email
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, false, ErrEmailRequired
+	}
+
+	sub := models.StockSubscription{ProductID: product.ID, UserID: userID, Email: email}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&sub)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if err := db.Where("product_id = ? AND email = ? AND notified_at IS NULL", product.ID, email).
+			First(&sub).Error; err != nil {
+			return nil, false, err
+		}
+		return &sub, false, nil
+	}
+	return &sub, true, nil
+}
+
+// SubscriberCounts returns open subscriptions per product
+func SubscriberCounts(db *gorm.DB) (map[uint]int64, error) {
+	var rows []struct {
+		ProductID uint
+		Count     int64
+	}
+	if err := db.Model(&models.StockSubscription{}).Select("product_id, COUNT(*) AS count").
+		Where("notified_at IS NULL").Group("product_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, r := range rows {
+		counts[r.ProductID] = r.Count
+	}
+	return counts, nil
+}
+
+// ---------- Restock hook ----------
+
+// ProductRestocked must be called in the transaction of anything that puts
+// stock back: admin updates, completed production, refund restocks. When
+// the product is in stock and has subscribers, a job emails them.
+func ProductRestocked(tx *gorm.DB, productID uint) error {
+	var open int64
+	if err := tx.Model(&models.StockSubscription{}).
+		Joins("JOIN products p ON p.id = stock_subscriptions.product_id AND p.deleted_at IS NULL").
+		Where("stock_subscriptions.product_id = ? AND stock_subscriptions.notified_at IS NULL AND p.stock_quantity > 0", productID).
+		Count(&open).Error; err != nil {
+		return err
+	}
+	if open == 0 {
+		return nil
+	}
+	return jobs.Enqueue(tx, JobNotifyBackInStock, notifyBackInStockJob{ProductID: productID})
+}
+
+// handleNotifyBackInStock sends one email per open subscription and closes
+// it in the same transaction, so a retried job never emails twice
+func handleNotifyBackInStock(db *gorm.DB, payload []byte) error {
+	var job notifyBackInStockJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.First(&product, job.ProductID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if product.StockQuantity <= 0 {
+			// sold out again before the job ran; wait for the next restock
+			return nil
+		}
+
+		var subs []models.StockSubscription
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("product_id = ? AND notified_at IS NULL", product.ID).Find(&subs).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, sub := range subs {
+			name := "there"
+			if sub.UserID != nil {
+				var user models.User
+				if err := tx.Select("full_name").First(&user, *sub.UserID).Error; err == nil && user.FullName != "" {
+					name = user.FullName
+				}
+			}
+			if err := EnqueueEmail(tx, sub.Email, mailer.TemplateBackInStock, mailer.ProductAlertData{
+				Name:        name,
+				ProductName: product.Name,
+				NewPrice:    product.Price,
+				Link:        config.APIURL(fmt.Sprintf("/products/%d", product.ID)),
+			}); err != nil {
+				return err
+			}
+			if err := tx.Model(&sub).Update("notified_at", now).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
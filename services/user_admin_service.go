@@ -0,0 +1,233 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"e-commerce/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserRoles are the roles an admin can give a user
+var UserRoles = []string{"user", "admin"}
+
+const maxReasonLength = 500
+
+var (
+	ErrLastAdmin   = Conflict("last_admin", "The last active admin cannot be demoted, blocked or deleted")
+	ErrSelfChange  = Forbidden("self_change", "Admins cannot demote, block or delete their own account")
+	ErrGuestRecord = Conflict("guest_record", "Guest checkout records are not accounts; their role and block status cannot be changed")
+)
+
+// UserPatch holds the fields an admin sent; nil fields are left as they
+// are. AvatarURL is only applied when AvatarSet, and a nil AvatarURL then
+// removes the avatar. Reason is required when the role changes.
+type UserPatch struct {
+	FullName  *string
+	Role      *string
+	Address   *string
+	AvatarURL *string
+	AvatarSet bool
+	Reason    string
+}
+
+// PatchUser applies an admin's partial update. An admin cannot change
+// their own role, the last active admin cannot be demoted, and guest
+// checkout records cannot be given a role.
+func PatchUser(db *gorm.DB, userID uint, patch UserPatch, actorID *uint) (*models.User, error) {
+	var fields []FieldError
+	if patch.FullName != nil && strings.TrimSpace(*patch.FullName) == "" {
+		fields = append(fields, FieldError{Field: "full_name", Message: "cannot be empty"})
+	}
+	if patch.Role != nil && !validRole(*patch.Role) {
+		fields = append(fields, FieldError{Field: "role", Message: "must be one of: " + strings.Join(UserRoles, ", ")})
+	}
+	if patch.AvatarSet && patch.AvatarURL != nil && strings.TrimSpace(*patch.AvatarURL) == "" {
+		patch.AvatarURL = nil
+	}
+
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return ErrUserNotFound.Wrap(err)
+		}
+		roleChanged := patch.Role != nil && *patch.Role != user.Role
+		if roleChanged && user.Role == RoleGuest {
+			return ErrGuestRecord
+		}
+		if roleChanged {
+			if f := reasonField(patch.Reason, "changing the role"); f != nil {
+				fields = append(fields, *f)
+			}
+		}
+		if len(fields) > 0 {
+			return Invalid("invalid_user", "Invalid user update", fields...)
+		}
+
+		if roleChanged && user.Role == "admin" {
+			if err := guardAdminRemoval(tx, user, actorID); err != nil {
+				return err
+			}
+		}
+
+		if patch.FullName != nil {
+			user.FullName = strings.TrimSpace(*patch.FullName)
+		}
+		if patch.Role != nil {
+			user.Role = *patch.Role
+		}
+		if patch.Address != nil {
+			user.Address = *patch.Address
+		}
+		if patch.AvatarSet {
+			user.AvatarURL = patch.AvatarURL
+		}
+		if err := tx.Save(&user).Error; err != nil {
+			return Internal("Failed to update user", err)
+		}
+		// sessions carry the role, so a new one must be issued
+		if roleChanged {
+			return revokeSessions(tx, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetUserBlocked blocks or unblocks a user. Blocking needs a reason, signs
+// the user out, and is refused for the caller, the last active admin and
+// guest checkout records.
+func SetUserBlocked(db *gorm.DB, userID uint, blocked bool, reason string, actorID *uint) (*models.User, error) {
+	if blocked {
+		if f := reasonField(reason, "blocking"); f != nil {
+			return nil, Invalid("invalid_block", "Invalid block", *f)
+		}
+	}
+
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return ErrUserNotFound.Wrap(err)
+		}
+		if user.Role == RoleGuest {
+			return ErrGuestRecord
+		}
+		if user.IsBlocked == blocked {
+			return nil
+		}
+		if blocked && user.Role == "admin" {
+			if err := guardAdminRemoval(tx, user, actorID); err != nil {
+				return err
+			}
+		}
+
+		user.IsBlocked = blocked
+		if err := tx.Save(&user).Error; err != nil {
+			return Internal("Failed to update user", err)
+		}
+		if blocked {
+			return revokeSessions(tx, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DeleteUser signs a user out and erases their account at once, like a
+// self-service deletion whose grace period is over. While the user has
+// open orders the account is blocked, soft-deleted and left scheduled, and
+// the erasure job finishes it once the orders are done; erased reports
+// which happened. Admins cannot delete themselves or the last active admin.
+func DeleteUser(db *gorm.DB, userID uint, actorID *uint) (erased bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return ErrUserNotFound.Wrap(err)
+		}
+		if actorID != nil && *actorID == user.ID {
+			return ErrSelfChange
+		}
+		if user.Role == "admin" {
+			if err := guardAdminRemoval(tx, user, actorID); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"is_blocked":            true,
+			"deletion_scheduled_at": now,
+		}).Error; err != nil {
+			return Internal("Failed to delete user", err)
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return Internal("Failed to delete user", err)
+		}
+		return revokeSessions(tx, user.ID)
+	})
+	if err != nil {
+		return false, err
+	}
+	erased, err = EraseAccount(db, userID)
+	if err != nil {
+		return false, Internal("Failed to erase user", err)
+	}
+	return erased, nil
+}
+
+// ---------- helpers ----------
+
+func validRole(role string) bool {
+	for _, r := range UserRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func reasonField(reason, action string) *FieldError {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return &FieldError{Field: "reason", Message: "is required when " + action}
+	}
+	if len(reason) > maxReasonLength {
+		return &FieldError{Field: "reason", Message: "must be at most " + strconv.Itoa(maxReasonLength) + " characters"}
+	}
+	return nil
+}
+
+// guardAdminRemoval refuses to take admin access from the caller or from
+// the last active admin. The active admins are locked so two admins cannot
+// remove each other at the same time.
+func guardAdminRemoval(tx *gorm.DB, admin models.User, actorID *uint) error {
+	if actorID != nil && *actorID == admin.ID {
+		return ErrSelfChange
+	}
+	var active []models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ? AND is_blocked = ?", "admin", false).
+		Find(&active).Error; err != nil {
+		return Internal("Failed to check admins", err)
+	}
+	for _, a := range active {
+		if a.ID != admin.ID {
+			return nil
+		}
+	}
+	return ErrLastAdmin
+}
+
+func revokeSessions(tx *gorm.DB, userID uint) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RefreshToken{}).Error; err != nil {
+		return Internal("Failed to sign the user out", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"e-commerce/config"
+	"e-commerce/models"
+	"e-commerce/utils"
+
+	"gorm.io/gorm"
+)
+
+// Verification purposes shared by OTP codes and signed links
+const (
+	PurposeSignup        = "signup"
+	PurposeResetPassword = "reset_password"
+)
+
+const linkTTL = time.Hour
+
+var (
+	ErrLinkInvalid = Invalid("link_invalid", "Invalid link")
+	ErrLinkExpired = Invalid("link_expired", "Link expired")
+	ErrLinkUsed    = Conflict("link_used", "Link already used")
+)
+
+// linkError turns a token parsing failure into a typed error
+func linkError(err error) error {
+	if errors.Is(err, utils.ErrLinkExpired) {
+		return ErrLinkExpired.Wrap(err)
+	}
+	return ErrLinkInvalid.Wrap(err)
+}
+
+// ---------- Issue ----------
+
+// issueActionLink stores a one-time token for the user. Older unused links
+// for the same purpose are revoked. The signed URL is built with
+// actionLinkURL when the email is sent, so it is never stored.
+func issueActionLink(tx *gorm.DB, userID uint, purpose string) (*models.ActionToken, error) {
+	jti, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, Internal("Failed to generate link", err)
+	}
+
+	if err := revokeActionLinks(tx, userID, purpose); err != nil {
+		return nil, err
+	}
+
+	record := models.ActionToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		JTI:       jti,
+		ExpiresAt: time.Now().Add(linkTTL),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// actionLinkURL signs the /auth/verify URL for a stored link
+func actionLinkURL(record *models.ActionToken) (string, error) {
+	token, err := utils.SignLinkToken(utils.LinkClaims{
+		UserID:  record.UserID,
+		Purpose: record.Purpose,
+		JTI:     record.JTI,
+		Expiry:  record.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return config.APIURL("/auth/verify?token=") + url.QueryEscape(token), nil
+}
+
+// revokeActionLinks invalidates every outstanding link for the purpose
+func revokeActionLinks(tx *gorm.DB, userID uint, purpose string) error {
+	return tx.Model(&models.ActionToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
+
+// ---------- Validate ----------
+
+// PeekActionLink checks a link without consuming it, e.g. before showing
+// the reset password form
+func PeekActionLink(db *gorm.DB, token string) (*utils.LinkClaims, error) {
+	claims, err := utils.ParseLinkToken(token)
+	if err != nil {
+		return nil, linkError(err)
+	}
+
+	var record models.ActionToken
+	if err := db.Where("jti = ? AND user_id = ? AND purpose = ?", claims.JTI, claims.UserID, claims.Purpose).
+		First(&record).Error; err != nil {
+		return nil, ErrLinkInvalid
+	}
+	if record.UsedAt != nil {
+		return nil, ErrLinkUsed
+	}
+	return claims, nil
+}
+
+// consumeActionLink atomically marks the link used; a second click fails
+func consumeActionLink(tx *gorm.DB, token, purpose string) (*utils.LinkClaims, error) {
+	claims, err := utils.ParseLinkToken(token)
+	if err != nil {
+		return nil, linkError(err)
+	}
+	if claims.Purpose != purpose {
+		return nil, ErrLinkInvalid
+	}
+
+	result := tx.Model(&models.ActionToken{}).
+		Where("jti = ? AND user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?",
+			claims.JTI, claims.UserID, purpose, time.Now()).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrLinkUsed
+	}
+	return claims, nil
+}
+
+// ---------- Link flows ----------
+
+// VerifyEmailLinkService verifies the user's email from a signup link
+func VerifyEmailLinkService(db *gorm.DB, token string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		claims, err := consumeActionLink(tx, token, PurposeSignup)
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, claims.UserID).Error; err != nil {
+			return ErrUserNotFound
+		}
+		user.IsVerified = true
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+
+		// the OTP fallback for the same purpose is no longer needed
+		if err := tx.Model(&models.OTP{}).
+			Where("user_id = ? AND purpose = ? AND is_used = ?", claims.UserID, PurposeSignup, false).
+			Update("is_used", true).Error; err != nil {
+			return err
+		}
+		return claimGuestOrders(tx, user)
+	})
+}
+
+// ResetPasswordWithLinkService sets a new password from a reset link
+func ResetPasswordWithLinkService(db *gorm.DB, token, newPassword string) error {
+	hashed, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return Internal("Failed to hash password", err)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		claims, err := consumeActionLink(tx, token, PurposeResetPassword)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.User{}).Where("id = ?", claims.UserID).Update("password_hash", hashed)
+		if result.Error != nil {
+			return Internal("Failed to update password", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+
+		return tx.Model(&models.OTP{}).
+			Where("user_id = ? AND purpose = ? AND is_used = ?", claims.UserID, PurposeResetPassword, false).
+			Update("is_used", true).Error
+	})
+}
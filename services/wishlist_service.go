@@ -0,0 +1,382 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"e-commerce/config"
+	"e-commerce/mailer"
+	"e-commerce/models"
+	"e-commerce/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultWishlistName = "My Wishlist"
+
+var (
+	ErrWishlistNotFound     = NotFound("wishlist_not_found", "Wishlist not found")
+	ErrWishlistItemNotFound = NotFound("wishlist_item_not_found", "Product not found in wishlist")
+	ErrWishlistItemExists   = Conflict("wishlist_item_exists", "Product already in wishlist")
+	ErrWishlistNameRequired = Invalid("name_required", "Name is required", FieldError{Field: "name", Message: "is required"})
+	ErrDefaultWishlist      = Conflict("default_wishlist", "The default wishlist cannot be deleted")
+)
+
+// WishlistSummary is a list without its items
+type WishlistSummary struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	IsDefault bool      `json:"is_default"`
+	ItemCount int64     `json:"item_count"`
+	Shared    bool      `json:"shared"`
+	ShareURL  string    `json:"share_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WishlistItemOptions are the per-item alert opt-ins
+type WishlistItemOptions struct {
+	NotifyPriceDrop   *bool `json:"notify_price_drop"`
+	NotifyBackInStock *bool `json:"notify_back_in_stock"`
+}
+
+// ---------- Lists ----------
+
+// DefaultWishlist returns the user's default list, creating it on first use.
+// Concurrent first uses race on idx_wishlists_default; the loser re-reads
+// the winner's list.
+func DefaultWishlist(db *gorm.DB, userID uint) (*models.Wishlist, error) {
+	var list models.Wishlist
+	err := db.Where("user_id = ? AND is_default = ?", userID, true).First(&list).Error
+	if err == nil {
+		return &list, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	list = models.Wishlist{UserID: userID, Name: defaultWishlistName, IsDefault: true}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&list)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		list = models.Wishlist{}
+		if err := db.Where("user_id = ? AND is_default = ?", userID, true).First(&list).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &list, nil
+}
+
+// GetWishlist loads one of the user's lists with its items
+func GetWishlist(db *gorm.DB, userID, wishlistID uint) (*models.Wishlist, error) {
+	var list models.Wishlist
+	err := db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("created_at desc") }).
+		Preload("Items.Product").
+		Where("id = ? AND user_id = ?", wishlistID, userID).First(&list).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrWishlistNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// ListWishlists returns the user's lists, default first
+func ListWishlists(db *gorm.DB, userID uint) ([]WishlistSummary, error) {
+	if _, err := DefaultWishlist(db, userID); err != nil {
+		return nil, err
+	}
+
+	var lists []models.Wishlist
+	if err := db.Where("user_id = ?", userID).Order("is_default desc, created_at").Find(&lists).Error; err != nil {
+		return nil, err
+	}
+
+	type count struct {
+		WishlistID uint
+		Count      int64
+	}
+	var counts []count
+	if err := db.Model(&models.WishlistItem{}).Select("wishlist_id, COUNT(*) AS count").
+		Where("user_id = ?", userID).Group("wishlist_id").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	byList := map[uint]int64{}
+	for _, c := range counts {
+		byList[c.WishlistID] = c.Count
+	}
+
+	resp := make([]WishlistSummary, 0, len(lists))
+	for _, l := range lists {
+		s := summarizeWishlist(l)
+		s.ItemCount = byList[l.ID]
+		resp = append(resp, s)
+	}
+	return resp, nil
+}
+
+func CreateWishlist(db *gorm.DB, userID uint, name string) (*WishlistSummary, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrWishlistNameRequired
+	}
+	list := models.Wishlist{UserID: userID, Name: name}
+	if err := db.Create(&list).Error; err != nil {
+		return nil, err
+	}
+	s := summarizeWishlist(list)
+	return &s, nil
+}
+
+func RenameWishlist(db *gorm.DB, userID, wishlistID uint, name string) (*WishlistSummary, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrWishlistNameRequired
+	}
+	var list models.Wishlist
+	if err := db.Where("id = ? AND user_id = ?", wishlistID, userID).First(&list).Error; err != nil {
+		return nil, ErrWishlistNotFound
+	}
+	list.Name = name
+	if err := db.Save(&list).Error; err != nil {
+		return nil, err
+	}
+	s := summarizeWishlist(list)
+	return &s, nil
+}
+
+// DeleteWishlist removes a list and its items; the default list stays
+func DeleteWishlist(db *gorm.DB, userID, wishlistID uint) error {
+	var list models.Wishlist
+	if err := db.Where("id = ? AND user_id = ?", wishlistID, userID).First(&list).Error; err != nil {
+		return ErrWishlistNotFound
+	}
+	if list.IsDefault {
+		return ErrDefaultWishlist
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("wishlist_id = ?", list.ID).Delete(&models.WishlistItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&list).Error
+	})
+}
+
+// ---------- Sharing ----------
+
+// ShareWishlist gives the list an unguessable public token; sharing again
+// keeps the existing link
+func ShareWishlist(db *gorm.DB, userID, wishlistID uint) (*WishlistSummary, error) {
+	var list models.Wishlist
+	if err := db.Where("id = ? AND user_id = ?", wishlistID, userID).First(&list).Error; err != nil {
+		return nil, ErrWishlistNotFound
+	}
+	if list.ShareToken == nil {
+		token, err := utils.RandomToken(24)
+		if err != nil {
+			return nil, err
+		}
+		list.ShareToken = &token
+		if err := db.Model(&list).Update("share_token", token).Error; err != nil {
+			return nil, err
+		}
+	}
+	s := summarizeWishlist(list)
+	return &s, nil
+}
+
+// UnshareWishlist revokes the public link
+func UnshareWishlist(db *gorm.DB, userID, wishlistID uint) error {
+	result := db.Model(&models.Wishlist{}).Where("id = ? AND user_id = ?", wishlistID, userID).
+		Update("share_token", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWishlistNotFound
+	}
+	return nil
+}
+
+// GetSharedWishlist loads a list by its public token
+func GetSharedWishlist(db *gorm.DB, token string) (*models.Wishlist, error) {
+	if token == "" {
+		return nil, ErrWishlistNotFound
+	}
+	var list models.Wishlist
+	err := db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("created_at desc") }).
+		Preload("Items.Product").
+		Where("share_token = ?", token).First(&list).Error
+	if err != nil {
+		return nil, ErrWishlistNotFound
+	}
+	return &list, nil
+}
+
+func summarizeWishlist(list models.Wishlist) WishlistSummary {
+	s := WishlistSummary{
+		ID:        list.ID,
+		Name:      list.Name,
+		IsDefault: list.IsDefault,
+		CreatedAt: list.CreatedAt,
+	}
+	if list.ShareToken != nil {
+		s.Shared = true
+		s.ShareURL = config.APIURL("/shared/wishlists/" + *list.ShareToken)
+	}
+	return s
+}
+
+// ---------- Items ----------
+
+// AddWishlistItem puts a product on one of the user's lists, capturing the
+// current price for price-drop alerts
+func AddWishlistItem(db *gorm.DB, userID, wishlistID, productID uint, opts WishlistItemOptions) (*models.WishlistItem, error) {
+	var list models.Wishlist
+	if err := db.Where("id = ? AND user_id = ?", wishlistID, userID).First(&list).Error; err != nil {
+		return nil, ErrWishlistNotFound
+	}
+	var product models.Product
+	if err := db.First(&product, productID).Error; err != nil {
+		return nil, ErrProductNotFound
+	}
+
+	item := models.WishlistItem{
+		WishlistID:    list.ID,
+		UserID:        userID,
+		ProductID:     product.ID,
+		PriceAtAdd:    product.Price,
+		WasOutOfStock: product.StockQuantity <= 0,
+	}
+	applyWishlistItemOptions(&item, opts)
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&item)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrWishlistItemExists
+	}
+	item.Product = product
+	return &item, nil
+}
+
+// UpdateWishlistItem changes the alert opt-ins of an item
+func UpdateWishlistItem(db *gorm.DB, userID, wishlistID, productID uint, opts WishlistItemOptions) (*models.WishlistItem, error) {
+	var item models.WishlistItem
+	if err := db.Preload("Product").
+		Where("wishlist_id = ? AND user_id = ? AND product_id = ?", wishlistID, userID, productID).
+		First(&item).Error; err != nil {
+		return nil, ErrWishlistItemNotFound
+	}
+	applyWishlistItemOptions(&item, opts)
+	if err := db.Save(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// RemoveWishlistItem takes a product off one of the user's lists
+func RemoveWishlistItem(db *gorm.DB, userID, wishlistID, productID uint) error {
+	result := db.Where("wishlist_id = ? AND user_id = ? AND product_id = ?", wishlistID, userID, productID).
+		Delete(&models.WishlistItem{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWishlistItemNotFound
+	}
+	return nil
+}
+
+func applyWishlistItemOptions(item *models.WishlistItem, opts WishlistItemOptions) {
+	if opts.NotifyPriceDrop != nil {
+		item.NotifyPriceDrop = *opts.NotifyPriceDrop
+	}
+	if opts.NotifyBackInStock != nil {
+		item.NotifyBackInStock = *opts.NotifyBackInStock
+	}
+}
+
+// ---------- Alerts ----------
+
+// handleWishlistAlerts emails opted-in users when a wishlisted product
+// dropped below the price captured when it was added, or came back in stock.
+// Each price level and each restock is alerted once.
+func handleWishlistAlerts(db *gorm.DB, payload []byte) error {
+	// remember what is out of stock now so a later restock can be spotted
+	if err := db.Exec(`UPDATE wishlist_items SET was_out_of_stock = true
+		FROM products p WHERE p.id = wishlist_items.product_id
+		AND p.stock_quantity <= 0 AND NOT wishlist_items.was_out_of_stock`).Error; err != nil {
+		return err
+	}
+
+	var items []models.WishlistItem
+	if err := db.Preload("Product").
+		Joins("JOIN products p ON p.id = wishlist_items.product_id AND p.deleted_at IS NULL").
+		Where(`(wishlist_items.notify_price_drop AND p.price_currency = wishlist_items.price_at_add_currency
+				AND p.price_amount < LEAST(wishlist_items.price_at_add_amount, COALESCE(wishlist_items.alerted_price, wishlist_items.price_at_add_amount)))
+			OR (wishlist_items.notify_back_in_stock AND wishlist_items.was_out_of_stock AND p.stock_quantity > 0)`).
+		Find(&items).Error; err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return sendWishlistAlert(tx, item)
+		}); err != nil {
+			return err
+		}
+	}
+
+	// restocks nobody asked about are not news later on
+	return db.Exec(`UPDATE wishlist_items SET was_out_of_stock = false
+		FROM products p WHERE p.id = wishlist_items.product_id
+		AND p.stock_quantity > 0 AND wishlist_items.was_out_of_stock
+		AND NOT wishlist_items.notify_back_in_stock`).Error
+}
+
+func sendWishlistAlert(tx *gorm.DB, item models.WishlistItem) error {
+	var user models.User
+	if err := tx.First(&user, item.UserID).Error; err != nil {
+		// account gone: nothing to send
+		return nil
+	}
+	var list models.Wishlist
+	tx.Select("name").First(&list, item.WishlistID)
+
+	product := item.Product
+	data := mailer.ProductAlertData{
+		Name:        user.FullName,
+		ProductName: product.Name,
+		ListName:    list.Name,
+		OldPrice:    item.PriceAtAdd,
+		NewPrice:    product.Price,
+		Link:        config.APIURL(fmt.Sprintf("/products/%d", product.ID)),
+	}
+
+	updates := map[string]interface{}{}
+	if item.WasOutOfStock && product.StockQuantity > 0 {
+		updates["was_out_of_stock"] = false
+		if item.NotifyBackInStock {
+			if err := EnqueueEmail(tx, user.Email, mailer.TemplateBackInStock, data); err != nil {
+				return err
+			}
+		}
+	}
+	if item.NotifyPriceDrop && product.Price.Currency == item.PriceAtAdd.Currency && product.Price.Amount < item.PriceAtAdd.Amount &&
+		(item.AlertedPrice == nil || product.Price.Amount < *item.AlertedPrice) {
+		updates["alerted_price"] = product.Price.Amount
+		if err := EnqueueEmail(tx, user.Email, mailer.TemplatePriceDrop, data); err != nil {
+			return err
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return tx.Model(&models.WishlistItem{}).Where("id = ?", item.ID).Updates(updates).Error
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt"
@@ -26,28 +27,77 @@ func CheckPasswordHash(password, hash string) bool {
 }
 
 // ------------------ JWT Functions ------------------
+
+func jwtIssuer() string {
+	if iss := os.Getenv("JWT_ISSUER"); iss != "" {
+		return iss
+	}
+	return "e-commerce"
+}
+
+func jwtAudience() string {
+	if aud := os.Getenv("JWT_AUDIENCE"); aud != "" {
+		return aud
+	}
+	return "e-commerce-api"
+}
+
+// GenerateJWT signs an access token with the current key ring key; the
+// key id goes in the "kid" header so verifiers can pick it from the JWKS
 func GenerateJWT(userID int, role string) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
+	key, err := currentSigningKey()
+	if err != nil {
+		return "", err
+	}
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"userId": userID,
 		"role":   role,
-		"exp":    time.Now().Add(time.Minute * 30).Unix(),
+		"sub":    strconv.Itoa(userID),
+		"iss":    jwtIssuer(),
+		"aud":    jwtAudience(),
+		"jti":    jti,
+		"iat":    now.Unix(),
+		"nbf":    now.Unix(),
+		"exp":    now.Add(time.Minute * 30).Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
 }
 
-// ValidateJWT validates token and returns userId + role
+// ValidateJWT validates token and returns userId + role. For a correctly
+// signed but expired token it still returns userId + role together with a
+// "token expired" error so callers can fall back to the refresh token.
 func ValidateJWT(tokenStr string) (int, string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		return 0, "", fmt.Errorf("JWT_SECRET not set")
-	}
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return legacyHMACKey(token)
+		}
+		key, err := verificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.alg {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return key.public, nil
+	})
 
-	token, _, err := new(jwt.Parser).ParseUnverified(tokenStr, jwt.MapClaims{})
+	expired := false
 	if err != nil {
-		return 0, "", err
+		ve, ok := err.(*jwt.ValidationError)
+		if !ok || ve.Errors != jwt.ValidationErrorExpired {
+			return 0, "", err
+		}
+		expired = true
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
@@ -55,6 +105,19 @@ func ValidateJWT(tokenStr string) (int, string, error) {
 		return 0, "", fmt.Errorf("invalid token claims")
 	}
 
+	// legacy HS256 tokens predate iss/aud/jti
+	if _, legacy := token.Method.(*jwt.SigningMethodHMAC); !legacy {
+		if !claims.VerifyIssuer(jwtIssuer(), true) {
+			return 0, "", fmt.Errorf("invalid token issuer")
+		}
+		if !claims.VerifyAudience(jwtAudience(), true) {
+			return 0, "", fmt.Errorf("invalid token audience")
+		}
+		if jti, _ := claims["jti"].(string); jti == "" {
+			return 0, "", fmt.Errorf("missing token id")
+		}
+	}
+
 	userIDFloat, ok := claims["userId"].(float64)
 	if !ok {
 		return 0, "", fmt.Errorf("invalid userId in token")
@@ -65,38 +128,43 @@ func ValidateJWT(tokenStr string) (int, string, error) {
 		return 0, "", fmt.Errorf("invalid role in token")
 	}
 
-	parsedToken, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method")
-		}
-		return []byte(secret), nil
-	})
-
-	if err != nil {
-		if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors == jwt.ValidationErrorExpired {
-			return int(userIDFloat), role, fmt.Errorf("token expired")
-		}
-		return 0, "", err
+	if expired {
+		return int(userIDFloat), role, fmt.Errorf("token expired")
 	}
-
-	if !parsedToken.Valid {
+	if !token.Valid {
 		return 0, "", fmt.Errorf("invalid token")
 	}
 
 	return int(userIDFloat), role, nil
 }
 
+// legacyHMACKey keeps HS256 tokens issued before the switch to asymmetric
+// keys valid while JWT_LEGACY_HS256=true, so the rollout logs nobody out
+func legacyHMACKey(token *jwt.Token) (interface{}, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if os.Getenv("JWT_LEGACY_HS256") != "true" || secret == "" {
+		return nil, fmt.Errorf("missing key id")
+	}
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method")
+	}
+	return []byte(secret), nil
+}
+
 // ------------------ Refresh Token Functions ------------------
 
 // Generate a random refresh token ( hashed)
 func GenerateRefreshToken() (string,  error) {
-	b := make([]byte, 32)
-	_, err := rand.Read(b)
-	if err != nil {
+	return RandomToken(32)
+}
+
+// RandomToken returns n random bytes hex-encoded
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
-	token := hex.EncodeToString(b)
-	return token, nil
+	return hex.EncodeToString(b), nil
 }
 
 // Save refresh token to DB
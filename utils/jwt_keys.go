@@ -0,0 +1,347 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"e-commerce/models"
+
+	"github.com/golang-jwt/jwt"
+	"gorm.io/gorm"
+)
+
+// Supported JWT signing algorithms
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// signingKey is a parsed models.SigningKey
+type signingKey struct {
+	kid     string
+	alg     string
+	private crypto.Signer
+	public  crypto.PublicKey
+	// signs from activatesAt until a newer key activates or retiresAt
+	activatesAt time.Time
+	retiresAt   time.Time
+	expiresAt   time.Time
+	createdAt   time.Time
+}
+
+// keyRing holds every key that may still verify tokens, newest first
+type keyRing struct {
+	mu   sync.RWMutex
+	keys []*signingKey
+}
+
+var ring = &keyRing{}
+
+// JWKSMaxAge is how long verifiers may cache /.well-known/jwks.json
+const JWKSMaxAge = 5 * time.Minute
+
+// the rotation job's schedule; a successor is minted early enough for two runs
+const rotationCheckInterval = time.Hour
+
+// how often instances reload the ring, set by InitSigningKeys
+var keyReloadInterval = time.Minute
+
+// ------------------ Configuration ------------------
+
+func jwtAlgorithm() string {
+	if os.Getenv("JWT_ALGORITHM") == AlgEdDSA {
+		return AlgEdDSA
+	}
+	return AlgRS256
+}
+
+// how long a key signs new tokens (default 30 days)
+func keyRotationInterval() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("JWT_KEY_ROTATION")); err == nil && d > 0 {
+		return d
+	}
+	return 30 * 24 * time.Hour
+}
+
+// how long a retired key keeps verifying tokens (default 24h, must
+// exceed the access token lifetime)
+func keyVerifyGrace() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("JWT_KEY_GRACE")); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// how long a new key is published in the JWKS before it signs (default
+// 1h). Verifiers that cached the JWKS must have refetched it by then, so
+// it never drops below the cache lifetime plus the ring reload interval.
+func keyPublishLead() time.Duration {
+	lead := time.Hour
+	if d, err := time.ParseDuration(os.Getenv("JWT_KEY_PUBLISH_LEAD")); err == nil && d > 0 {
+		lead = d
+	}
+	if min := JWKSMaxAge + keyReloadInterval; lead < min {
+		lead = min
+	}
+	return lead
+}
+
+// ------------------ Loading & rotation ------------------
+
+// InitSigningKeys makes sure a signing key exists, loads the key ring and
+// reloads it periodically so keys rotated by another instance are picked up.
+func InitSigningKeys(db *gorm.DB, reload time.Duration) error {
+	keyReloadInterval = reload
+	if err := RotateSigningKeys(db); err != nil {
+		return err
+	}
+	go func() {
+		for range time.Tick(reload) {
+			if err := LoadSigningKeys(db); err != nil {
+				log.Println("❌ reload signing keys:", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// LoadSigningKeys replaces the in-memory key ring with the keys in the database
+func LoadSigningKeys(db *gorm.DB) error {
+	var rows []models.SigningKey
+	if err := db.Where("expires_at > ?", time.Now()).Order("created_at DESC").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	keys := make([]*signingKey, 0, len(rows))
+	for _, row := range rows {
+		k, err := parseSigningKey(row)
+		if err != nil {
+			log.Printf("❌ skipping signing key %s: %v", row.KID, err)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].createdAt.After(keys[j].createdAt) })
+
+	ring.mu.Lock()
+	ring.keys = keys
+	ring.mu.Unlock()
+	return nil
+}
+
+// RotateSigningKeys mints the successor of the current key well before it
+// retires: the new key is published in the JWKS at once but only signs
+// after keyPublishLead, and the current key signs until then. Keys past
+// their verification window are dropped and the ring is reloaded. An
+// advisory lock keeps concurrent instances from minting duplicates.
+func RotateSigningKeys(db *gorm.DB) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('jwt_signing_keys'))").Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Where("expires_at <= ?", now).Delete(&models.SigningKey{}).Error; err != nil {
+			return err
+		}
+
+		// the key signing last; with no key at all the first one signs at once
+		activatesAt := now
+		var latest models.SigningKey
+		err := tx.Where("algorithm = ? AND expires_at > ?", jwtAlgorithm(), now).
+			Order("retires_at DESC").First(&latest).Error
+		switch {
+		case err == nil:
+			lead := keyPublishLead()
+			if latest.RetiresAt.After(now.Add(lead + 2*rotationCheckInterval)) {
+				return nil
+			}
+			activatesAt = now.Add(lead)
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		row, err := generateSigningKey(jwtAlgorithm(), activatesAt)
+		if err != nil {
+			return err
+		}
+		log.Printf("🔑 new JWT signing key %s (%s), signing from %s", row.KID, row.Algorithm, activatesAt.Format(time.RFC3339))
+		return tx.Create(row).Error
+	})
+	if err != nil {
+		return err
+	}
+	return LoadSigningKeys(db)
+}
+
+func generateSigningKey(alg string, activatesAt time.Time) (*models.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch alg {
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	kid, err := RandomToken(8)
+	if err != nil {
+		return nil, err
+	}
+
+	retiresAt := activatesAt.Add(keyRotationInterval())
+	return &models.SigningKey{
+		KID:         kid,
+		Algorithm:   alg,
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})),
+		PublicKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})),
+		ActivatesAt: activatesAt,
+		RetiresAt:   retiresAt,
+		ExpiresAt:   retiresAt.Add(keyVerifyGrace()),
+	}, nil
+}
+
+func parseSigningKey(row models.SigningKey) (*signingKey, error) {
+	block, _ := pem.Decode([]byte(row.PrivateKey))
+	if block == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported private key")
+	}
+	return &signingKey{
+		kid:         row.KID,
+		alg:         row.Algorithm,
+		private:     private,
+		public:      private.Public(),
+		activatesAt: row.ActivatesAt,
+		retiresAt:   row.RetiresAt,
+		expiresAt:   row.ExpiresAt,
+		createdAt:   row.CreatedAt,
+	}, nil
+}
+
+// ------------------ Lookup ------------------
+
+// currentSigningKey is the most recently activated key that has not
+// retired. Keys still waiting out their publish lead are skipped. Should
+// rotation fall behind, the last activated key that still verifies keeps
+// signing rather than failing every login until the next run.
+func currentSigningKey() (*signingKey, error) {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	now := time.Now()
+	var current, fallback *signingKey
+	for _, k := range ring.keys {
+		if now.Before(k.activatesAt) || !now.Before(k.expiresAt) {
+			continue
+		}
+		if now.Before(k.retiresAt) && (current == nil || k.activatesAt.After(current.activatesAt)) {
+			current = k
+		}
+		if fallback == nil || k.activatesAt.After(fallback.activatesAt) {
+			fallback = k
+		}
+	}
+	if current != nil {
+		return current, nil
+	}
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, errors.New("no active JWT signing key")
+}
+
+func verificationKey(kid string) (*signingKey, error) {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	now := time.Now()
+	for _, k := range ring.keys {
+		if k.kid == kid && now.Before(k.expiresAt) {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (k *signingKey) method() jwt.SigningMethod {
+	if k.alg == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// ------------------ JWKS ------------------
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// PublicJWKS lists every key that may still verify tokens, including keys
+// published ahead of signing
+func PublicJWKS() []JWK {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	keys := make([]JWK, 0, len(ring.keys))
+	for _, k := range ring.keys {
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "RSA",
+				Kid: k.kid,
+				Use: "sig",
+				Alg: AlgRS256,
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "OKP",
+				Kid: k.kid,
+				Use: "sig",
+				Alg: AlgEdDSA,
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return keys
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// testKey builds a parsed key that signs from activatesAt
+func testKey(t *testing.T, activatesAt time.Time) *signingKey {
+	t.Helper()
+	row, err := generateSigningKey(AlgEdDSA, activatesAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row.CreatedAt = activatesAt
+	k, err := parseSigningKey(*row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func useRing(t *testing.T, keys ...*signingKey) {
+	t.Helper()
+	ring.mu.Lock()
+	old := ring.keys
+	ring.keys = keys
+	ring.mu.Unlock()
+	t.Cleanup(func() {
+		ring.mu.Lock()
+		ring.keys = old
+		ring.mu.Unlock()
+	})
+}
+
+func TestCurrentSigningKeyWaitsForPublishLead(t *testing.T) {
+	now := time.Now()
+	current := testKey(t, now.Add(-24*time.Hour))
+	next := testKey(t, now.Add(time.Hour))
+	useRing(t, next, current)
+
+	k, err := currentSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.kid != current.kid {
+		t.Error("a key still in its publish lead signed tokens")
+	}
+
+	next.activatesAt = now.Add(-time.Second)
+	if k, _ := currentSigningKey(); k.kid != next.kid {
+		t.Error("the activated successor does not sign")
+	}
+}
+
+func TestCurrentSigningKeyFallsBackAfterRetirement(t *testing.T) {
+	old := testKey(t, time.Now().Add(-48*time.Hour))
+	old.retiresAt = time.Now().Add(-time.Minute)
+	useRing(t, old)
+
+	k, err := currentSigningKey()
+	if err != nil || k.kid != old.kid {
+		t.Fatalf("got %v, %v; want the retired key while it still verifies", k, err)
+	}
+
+	old.expiresAt = time.Now().Add(-time.Second)
+	if _, err := currentSigningKey(); err == nil {
+		t.Error("an expired key signed tokens")
+	}
+}
+
+func TestPendingKeyIsPublishedAndVerifies(t *testing.T) {
+	now := time.Now()
+	current := testKey(t, now.Add(-time.Hour))
+	next := testKey(t, now.Add(time.Hour))
+	useRing(t, next, current)
+
+	published := map[string]bool{}
+	for _, k := range PublicJWKS() {
+		published[k.Kid] = true
+	}
+	if !published[current.kid] || !published[next.kid] {
+		t.Fatalf("JWKS = %v, want both keys", published)
+	}
+
+	token, err := GenerateJWT(7, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+	if err != nil || parsed.Header["kid"] != current.kid {
+		t.Fatalf("token signed with %v, want %s", parsed.Header["kid"], current.kid)
+	}
+	if id, role, err := ValidateJWT(token); err != nil || id != 7 || role != "user" {
+		t.Fatalf("ValidateJWT = %d, %q, %v", id, role, err)
+	}
+}
+
+func TestKeyPublishLeadCoversJWKSCache(t *testing.T) {
+	t.Setenv("JWT_KEY_PUBLISH_LEAD", "1s")
+	if lead := keyPublishLead(); lead < JWKSMaxAge+keyReloadInterval {
+		t.Errorf("lead %v is shorter than the JWKS cache and reload interval", lead)
+	}
+}